@@ -9,6 +9,12 @@ type Neo4jBackupValues struct {
 	ConsistencyCheck   ConsistencyCheck       `yaml:"consistencyCheck"`
 	ServiceAccountName string                 `yaml:"serviceAccountName"`
 	TempVolume         map[string]interface{} `yaml:"tempVolume"`
+	DestinationVolume  map[string]interface{} `yaml:"destinationVolume,omitempty"`
+	Drill              BackupDrill            `yaml:"drill,omitempty"`
+	Restore            BackupRestore          `yaml:"restore,omitempty"`
+	Metrics            BackupMetrics          `yaml:"metrics,omitempty"`
+	Notifications      BackupNotifications    `yaml:"notifications,omitempty"`
+	Hooks              BackupHooks            `yaml:"hooks,omitempty"`
 	SecurityContext    SecurityContext        `yaml:"securityContext"`
 	NodeSelector       map[string]string      `yaml:"nodeSelector,omitempty"`
 	Resources          Neo4jBackupResources   `yaml:"resources,omitempty"`
@@ -65,27 +71,139 @@ type Neo4jBackupNeo4j struct {
 }
 
 type Backup struct {
-	BucketName               string `yaml:"bucketName,omitempty"`
-	DatabaseAdminServiceName string `yaml:"databaseAdminServiceName,omitempty"`
-	DatabaseAdminServiceIP   string `yaml:"databaseAdminServiceIP,omitempty"`
-	DatabaseNamespace        string `yaml:"databaseNamespace,omitempty" default:"default"`
-	DatabaseBackupPort       string `yaml:"databaseBackupPort,omitempty" default:"6362"`
-	DatabaseClusterDomain    string `yaml:"databaseClusterDomain,omitempty" default:"cluster.local"`
-	Database                 string `yaml:"database,omitempty"`
-	AzureStorageAccountName  string `yaml:"azureStorageAccountName,omitempty"`
-	CloudProvider            string `yaml:"cloudProvider,omitempty"`
-	MinioEndpoint            string `yaml:"minioEndpoint,omitempty"`
-	SecretName               string `yaml:"secretName,omitempty"`
-	SecretKeyName            string `yaml:"secretKeyName,omitempty"`
-	PageCache                string `yaml:"pageCache,omitempty"`
-	HeapSize                 string `yaml:"heapSize,omitempty"`
-	FallbackToFull           bool   `yaml:"fallbackToFull" default:"true"`
-	IncludeMetadata          string `yaml:"includeMetadata,omitempty"`
-	Type                     string `yaml:"type,omitempty"`
-	KeepFailed               bool   `yaml:"keepFailed" default:"false"`
-	ParallelRecovery         bool   `yaml:"parallelRecovery" default:"false"`
-	KeepBackupFiles          bool   `yaml:"keepBackupFiles" default:"true"`
-	Verbose                  bool   `yaml:"verbose" default:"true"`
+	BucketName               string             `yaml:"bucketName,omitempty"`
+	DatabaseAdminServiceName string             `yaml:"databaseAdminServiceName,omitempty"`
+	DatabaseAdminServiceIP   string             `yaml:"databaseAdminServiceIP,omitempty"`
+	DatabaseBackupEndpoints  string             `yaml:"databaseBackupEndpoints,omitempty"`
+	DatabaseNamespace        string             `yaml:"databaseNamespace,omitempty" default:"default"`
+	DatabaseBackupPort       string             `yaml:"databaseBackupPort,omitempty" default:"6362"`
+	DatabaseClusterDomain    string             `yaml:"databaseClusterDomain,omitempty" default:"cluster.local"`
+	Database                 string             `yaml:"database,omitempty"`
+	Concurrency              string             `yaml:"concurrency,omitempty"`
+	AzureStorageAccountName  string             `yaml:"azureStorageAccountName,omitempty"`
+	CloudProvider            string             `yaml:"cloudProvider,omitempty"`
+	MinioEndpoint            string             `yaml:"minioEndpoint,omitempty"`
+	StorageClass             string             `yaml:"storageClass,omitempty"`
+	KmsKeyName               string             `yaml:"kmsKeyName,omitempty"`
+	AzureEncryptionScope     string             `yaml:"azureEncryptionScope,omitempty"`
+	SecretName               string             `yaml:"secretName,omitempty"`
+	SecretKeyName            string             `yaml:"secretKeyName,omitempty"`
+	PageCache                string             `yaml:"pageCache,omitempty"`
+	HeapSize                 string             `yaml:"heapSize,omitempty"`
+	FallbackToFull           bool               `yaml:"fallbackToFull" default:"true"`
+	IncludeMetadata          string             `yaml:"includeMetadata,omitempty"`
+	Type                     string             `yaml:"type,omitempty"`
+	KeepFailed               bool               `yaml:"keepFailed" default:"false"`
+	ParallelRecovery         bool               `yaml:"parallelRecovery" default:"false"`
+	KeepBackupFiles          bool               `yaml:"keepBackupFiles" default:"true"`
+	Retention                BackupRetention    `yaml:"retention,omitempty"`
+	Encryption               BackupEncryption   `yaml:"encryption,omitempty"`
+	LogFormat                string             `yaml:"logFormat,omitempty"`
+	LogLevel                 string             `yaml:"logLevel,omitempty"`
+	UploadPerDatabase        bool               `yaml:"uploadPerDatabase,omitempty"`
+	ChainCache               string             `yaml:"chainCache,omitempty"`
+	Replicas                 []BackupReplica    `yaml:"replicas,omitempty"`
+	Upload                   BackupUpload       `yaml:"upload,omitempty"`
+	Retry                    BackupRetry        `yaml:"retry,omitempty"`
+	Connectivity             BackupConnectivity `yaml:"connectivity,omitempty"`
+	Discovery                BackupDiscovery    `yaml:"discovery,omitempty"`
+	Verbose                  bool               `yaml:"verbose" default:"true"`
+}
+
+type BackupReplica struct {
+	CloudProvider string                 `yaml:"cloudProvider,omitempty"`
+	BucketName    string                 `yaml:"bucketName,omitempty"`
+	SecretName    string                 `yaml:"secretName,omitempty"`
+	SecretKeyName string                 `yaml:"secretKeyName,omitempty"`
+	Volume        map[string]interface{} `yaml:"volume,omitempty"`
+}
+
+type BackupDrill struct {
+	Enabled     bool   `yaml:"enabled,omitempty"`
+	ScratchPath string `yaml:"scratchPath,omitempty"`
+}
+
+type BackupRestore struct {
+	Enabled              bool                   `yaml:"enabled,omitempty"`
+	BackupNames          string                 `yaml:"backupNames,omitempty"`
+	PointInTime          string                 `yaml:"pointInTime,omitempty"`
+	OverwriteDestination bool                   `yaml:"overwriteDestination,omitempty"`
+	ToPathData           string                 `yaml:"toPathData,omitempty"`
+	ToPathTxn            string                 `yaml:"toPathTxn,omitempty"`
+	TempPath             string                 `yaml:"tempPath,omitempty"`
+	Volume               map[string]interface{} `yaml:"volume,omitempty"`
+}
+
+type BackupMetrics struct {
+	PushgatewayUrl string `yaml:"pushgatewayUrl,omitempty"`
+	TextfilePath   string `yaml:"textfilePath,omitempty"`
+}
+
+type BackupNotifications struct {
+	NotifyOn          string            `yaml:"notifyOn,omitempty"`
+	WebhookSecretName string            `yaml:"webhookSecretName,omitempty"`
+	SlackWebhookUrl   string            `yaml:"slackWebhookUrl,omitempty"`
+	Email             BackupNotifyEmail `yaml:"email,omitempty"`
+}
+
+type BackupHooks struct {
+	Timeout    string     `yaml:"timeout,omitempty"`
+	PreBackup  BackupHook `yaml:"preBackup,omitempty"`
+	PostUpload BackupHook `yaml:"postUpload,omitempty"`
+	OnFailure  BackupHook `yaml:"onFailure,omitempty"`
+}
+
+type BackupHook struct {
+	Command       string `yaml:"command,omitempty"`
+	Url           string `yaml:"url,omitempty"`
+	FailurePolicy string `yaml:"failurePolicy,omitempty"`
+}
+
+type BackupNotifyEmail struct {
+	SmtpHost   string `yaml:"smtpHost,omitempty"`
+	SmtpPort   string `yaml:"smtpPort,omitempty"`
+	From       string `yaml:"from,omitempty"`
+	To         string `yaml:"to,omitempty"`
+	SecretName string `yaml:"secretName,omitempty"`
+}
+
+type BackupRetention struct {
+	KeepLast    string `yaml:"keepLast,omitempty"`
+	KeepDaily   string `yaml:"keepDaily,omitempty"`
+	KeepWeekly  string `yaml:"keepWeekly,omitempty"`
+	KeepMonthly string `yaml:"keepMonthly,omitempty"`
+	MaxAge      string `yaml:"maxAge,omitempty"`
+}
+
+type BackupUpload struct {
+	PartSizeMB  string `yaml:"partSizeMB,omitempty"`
+	Concurrency string `yaml:"concurrency,omitempty"`
+}
+
+type BackupDiscovery struct {
+	Enabled    bool   `yaml:"enabled"`
+	Neo4jName  string `yaml:"neo4jName,omitempty"`
+	Server     string `yaml:"server,omitempty"`
+	HttpPort   string `yaml:"httpPort,omitempty"`
+	SecretName string `yaml:"secretName,omitempty"`
+}
+
+type BackupConnectivity struct {
+	Timeout  string `yaml:"timeout,omitempty"`
+	BoltPort string `yaml:"boltPort,omitempty"`
+	HttpPort string `yaml:"httpPort,omitempty"`
+}
+
+type BackupRetry struct {
+	MaxAttempts    string `yaml:"maxAttempts,omitempty"`
+	InitialBackoff string `yaml:"initialBackoff,omitempty"`
+	MaxBackoff     string `yaml:"maxBackoff,omitempty"`
+	Jitter         string `yaml:"jitter,omitempty"`
+}
+
+type BackupEncryption struct {
+	SecretName    string `yaml:"secretName,omitempty"`
+	SecretKeyName string `yaml:"secretKeyName,omitempty"`
 }
 
 type ConsistencyCheck struct {
@@ -98,6 +216,7 @@ type ConsistencyCheck struct {
 	MaxOffHeapMemory    string `yaml:"maxOffHeapMemory,omitempty"`
 	Threads             string `yaml:"threads,omitempty"`
 	Verbose             bool   `yaml:"verbose" default:"true"`
+	FailureThreshold    string `yaml:"failureThreshold,omitempty"`
 }
 
 type Toleration struct {
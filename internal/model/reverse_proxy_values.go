@@ -7,11 +7,18 @@ type Neo4jReverseProxyValues struct {
 }
 
 type ReverseProxy struct {
-	Image       string  `yaml:"image,omitempty"`
-	ServiceName string  `yaml:"serviceName,omitempty"`
-	Namespace   string  `yaml:"namespace,omitempty"`
-	Domain      string  `yaml:"domain,omitempty"`
-	Ingress     Ingress `yaml:"ingress,omitempty"`
+	Image        string                   `yaml:"image,omitempty"`
+	ServiceName  string                   `yaml:"serviceName,omitempty"`
+	Namespace    string                   `yaml:"namespace,omitempty"`
+	Domain       string                   `yaml:"domain,omitempty"`
+	Connectivity ReverseProxyConnectivity `yaml:"connectivity,omitempty"`
+	Ingress      Ingress                  `yaml:"ingress,omitempty"`
+}
+
+type ReverseProxyConnectivity struct {
+	Timeout  string `yaml:"timeout,omitempty"`
+	BoltPort string `yaml:"boltPort,omitempty"`
+	HttpPort string `yaml:"httpPort,omitempty"`
 }
 
 type Ingress struct {
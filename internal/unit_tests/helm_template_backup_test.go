@@ -5,6 +5,8 @@ import (
 	"github.com/neo4j/helm-charts/internal/model"
 	"github.com/stretchr/testify/assert"
 	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
 	"testing"
 )
 
@@ -389,3 +391,754 @@ func TestOnPremScenario(t *testing.T) {
 	assert.NoError(t, err, "error seen while performing backup on onprem")
 
 }
+
+// TestBackupRetentionPolicy checks the retention policy env variables in the backup cronjob
+func TestBackupRetentionPolicy(t *testing.T) {
+	t.Parallel()
+
+	helmValues := model.DefaultNeo4jBackupValues
+	helmValues.DisableLookups = true
+	helmValues.Backup.SecretName = "demo"
+	helmValues.Backup.CloudProvider = "aws"
+	helmValues.Backup.BucketName = "demo2"
+	helmValues.Backup.DatabaseAdminServiceName = "standalone-admin"
+	helmValues.Backup.Database = "neo4j1"
+	helmValues.Backup.Retention = model.BackupRetention{
+		KeepLast:    "3",
+		KeepMonthly: "6",
+		MaxAge:      "365d",
+	}
+
+	manifests, err := model.HelmTemplateFromStruct(t, model.BackupHelmChart, helmValues)
+	assert.NoError(t, err, "error seen while trying to install helm backup with retention policy")
+	cronjobs := manifests.OfType(&batchv1.CronJob{})
+	assert.Len(t, cronjobs, 1, "there should be only one cronjob")
+	container := cronjobs[0].(*batchv1.CronJob).Spec.JobTemplate.Spec.Template.Spec.Containers[0]
+
+	envVars := map[string]string{}
+	for _, envVar := range container.Env {
+		envVars[envVar.Name] = envVar.Value
+	}
+	assert.Equal(t, helmValues.Backup.Retention.KeepLast, envVars["RETENTION_KEEP_LAST"])
+	assert.Equal(t, "", envVars["RETENTION_KEEP_DAILY"])
+	assert.Equal(t, helmValues.Backup.Retention.KeepMonthly, envVars["RETENTION_KEEP_MONTHLY"])
+	assert.Equal(t, helmValues.Backup.Retention.MaxAge, envVars["RETENTION_MAX_AGE"])
+}
+
+// TestBackupFilesystemCloudProvider checks the destination volume mount when filesystem is used as cloudProvider
+func TestBackupFilesystemCloudProvider(t *testing.T) {
+	t.Parallel()
+
+	helmValues := model.DefaultNeo4jBackupValues
+	helmValues.DisableLookups = true
+	helmValues.Backup.CloudProvider = "filesystem"
+	helmValues.Backup.BucketName = "neo4j/production"
+	helmValues.Backup.DatabaseAdminServiceName = "standalone-admin"
+	helmValues.Backup.Database = "neo4j1"
+
+	_, err := model.HelmTemplateFromStruct(t, model.BackupHelmChart, helmValues)
+	assert.Error(t, err, "error must be seen when destinationVolume is missing")
+	assert.Contains(t, err.Error(), "Empty destinationVolume")
+
+	helmValues.DestinationVolume = map[string]interface{}{
+		"persistentVolumeClaim": map[string]interface{}{
+			"claimName": "nfs-backup-pvc",
+		},
+	}
+	manifests, err := model.HelmTemplateFromStruct(t, model.BackupHelmChart, helmValues)
+	assert.NoError(t, err, "error seen while trying to install helm backup with filesystem cloudProvider")
+	cronjobs := manifests.OfType(&batchv1.CronJob{})
+	assert.Len(t, cronjobs, 1, "there should be only one cronjob")
+	podSpec := cronjobs[0].(*batchv1.CronJob).Spec.JobTemplate.Spec.Template.Spec
+
+	var claimName string
+	for _, volume := range podSpec.Volumes {
+		if volume.Name == "destination" && volume.PersistentVolumeClaim != nil {
+			claimName = volume.PersistentVolumeClaim.ClaimName
+		}
+	}
+	assert.Equal(t, "nfs-backup-pvc", claimName, "destination volume missing")
+	assert.Contains(t, podSpec.Containers[0].VolumeMounts, v1.VolumeMount{Name: "destination", MountPath: "/destination"})
+}
+
+// TestBackupEncryption checks the encryption key secret is mounted and ENCRYPTION_KEY_PATH is set
+func TestBackupEncryption(t *testing.T) {
+	t.Parallel()
+
+	helmValues := model.DefaultNeo4jBackupValues
+	helmValues.DisableLookups = true
+	helmValues.Backup.SecretName = "demo"
+	helmValues.Backup.CloudProvider = "aws"
+	helmValues.Backup.BucketName = "demo2"
+	helmValues.Backup.DatabaseAdminServiceName = "standalone-admin"
+	helmValues.Backup.Database = "neo4j1"
+	helmValues.Backup.Encryption = model.BackupEncryption{
+		SecretName: "backup-encryption-key",
+	}
+
+	_, err := model.HelmTemplateFromStruct(t, model.BackupHelmChart, helmValues)
+	assert.Error(t, err, "error must be seen when encryption secretKeyName is missing")
+	assert.Contains(t, err.Error(), "Empty encryption secretKeyName")
+
+	helmValues.Backup.Encryption.SecretKeyName = "key"
+	manifests, err := model.HelmTemplateFromStruct(t, model.BackupHelmChart, helmValues)
+	assert.NoError(t, err, "error seen while trying to install helm backup with encryption")
+	cronjobs := manifests.OfType(&batchv1.CronJob{})
+	assert.Len(t, cronjobs, 1, "there should be only one cronjob")
+	podSpec := cronjobs[0].(*batchv1.CronJob).Spec.JobTemplate.Spec.Template.Spec
+
+	envVars := map[string]string{}
+	for _, envVar := range podSpec.Containers[0].Env {
+		envVars[envVar.Name] = envVar.Value
+	}
+	assert.Equal(t, "/encryption/key", envVars["ENCRYPTION_KEY_PATH"])
+
+	var secretName string
+	for _, volume := range podSpec.Volumes {
+		if volume.Name == "encryption" && volume.Secret != nil {
+			secretName = volume.Secret.SecretName
+		}
+	}
+	assert.Equal(t, "backup-encryption-key", secretName)
+}
+
+// TestBackupStorageClassAndKmsKey checks the storage class and server side encryption values are passed to the backup container
+func TestBackupStorageClassAndKmsKey(t *testing.T) {
+	t.Parallel()
+
+	helmValues := model.DefaultNeo4jBackupValues
+	helmValues.DisableLookups = true
+	helmValues.Backup.SecretName = "demo"
+	helmValues.Backup.SecretKeyName = "credentials"
+	helmValues.Backup.CloudProvider = "azure"
+	helmValues.Backup.BucketName = "demo2"
+	helmValues.Backup.DatabaseAdminServiceName = "standalone-admin"
+	helmValues.Backup.Database = "neo4j1"
+	helmValues.Backup.StorageClass = "Cool"
+	helmValues.Backup.KmsKeyName = "demo-key"
+	helmValues.Backup.AzureEncryptionScope = "demo-scope"
+
+	manifests, err := model.HelmTemplateFromStruct(t, model.BackupHelmChart, helmValues)
+	assert.NoError(t, err, "error seen while trying to install helm backup with storage class")
+	cronjobs := manifests.OfType(&batchv1.CronJob{})
+	assert.Len(t, cronjobs, 1, "there should be only one cronjob")
+	container := cronjobs[0].(*batchv1.CronJob).Spec.JobTemplate.Spec.Template.Spec.Containers[0]
+
+	envVars := map[string]string{}
+	for _, envVar := range container.Env {
+		envVars[envVar.Name] = envVar.Value
+	}
+	assert.Equal(t, "Cool", envVars["STORAGE_CLASS"])
+	assert.Equal(t, "demo-key", envVars["KMS_KEY_NAME"])
+	assert.Equal(t, "demo-scope", envVars["AZURE_ENCRYPTION_SCOPE"])
+}
+
+// TestBackupMetrics checks the pushgateway url and textfile path are passed to the backup container
+func TestBackupMetrics(t *testing.T) {
+	t.Parallel()
+
+	helmValues := model.DefaultNeo4jBackupValues
+	helmValues.DisableLookups = true
+	helmValues.Backup.DatabaseAdminServiceName = "standalone-admin"
+	helmValues.Backup.Database = "neo4j1"
+	helmValues.Metrics = model.BackupMetrics{
+		PushgatewayUrl: "http://pushgateway.monitoring.svc.cluster.local:9091",
+		TextfilePath:   "/backups/neo4j_backup.prom",
+	}
+
+	manifests, err := model.HelmTemplateFromStruct(t, model.BackupHelmChart, helmValues)
+	assert.NoError(t, err, "error seen while trying to install helm backup with metrics")
+	cronjobs := manifests.OfType(&batchv1.CronJob{})
+	assert.Len(t, cronjobs, 1, "there should be only one cronjob")
+	container := cronjobs[0].(*batchv1.CronJob).Spec.JobTemplate.Spec.Template.Spec.Containers[0]
+
+	envVars := map[string]string{}
+	for _, envVar := range container.Env {
+		envVars[envVar.Name] = envVar.Value
+	}
+	assert.Equal(t, helmValues.Metrics.PushgatewayUrl, envVars["PUSHGATEWAY_URL"])
+	assert.Equal(t, helmValues.Metrics.TextfilePath, envVars["METRICS_TEXTFILE_PATH"])
+	assert.Equal(t, cronjobs[0].(*batchv1.CronJob).Name, envVars["METRICS_JOB_NAME"])
+}
+
+// TestBackupLogFormat checks the log format and level are passed to the backup container
+func TestBackupLogFormat(t *testing.T) {
+	t.Parallel()
+
+	helmValues := model.DefaultNeo4jBackupValues
+	helmValues.DisableLookups = true
+	helmValues.Backup.DatabaseAdminServiceName = "standalone-admin"
+	helmValues.Backup.Database = "neo4j1"
+	helmValues.Backup.LogFormat = "json"
+	helmValues.Backup.LogLevel = "debug"
+
+	manifests, err := model.HelmTemplateFromStruct(t, model.BackupHelmChart, helmValues)
+	assert.NoError(t, err, "error seen while trying to install helm backup with json logs")
+	cronjobs := manifests.OfType(&batchv1.CronJob{})
+	assert.Len(t, cronjobs, 1, "there should be only one cronjob")
+	container := cronjobs[0].(*batchv1.CronJob).Spec.JobTemplate.Spec.Template.Spec.Containers[0]
+
+	envVars := map[string]string{}
+	for _, envVar := range container.Env {
+		envVars[envVar.Name] = envVar.Value
+	}
+	assert.Equal(t, "json", envVars["LOG_FORMAT"])
+	assert.Equal(t, "debug", envVars["LOG_LEVEL"])
+}
+
+// TestBackupNotifications checks the notification settings and the smtp credentials are passed to the backup container
+func TestBackupNotifications(t *testing.T) {
+	t.Parallel()
+
+	helmValues := model.DefaultNeo4jBackupValues
+	helmValues.DisableLookups = true
+	helmValues.Backup.DatabaseAdminServiceName = "standalone-admin"
+	helmValues.Backup.Database = "neo4j1"
+	helmValues.Notifications = model.BackupNotifications{
+		NotifyOn:        "failure",
+		SlackWebhookUrl: "https://hooks.slack.com/services/demo",
+		Email: model.BackupNotifyEmail{
+			SmtpHost:   "smtp.example.com",
+			From:       "backup@example.com",
+			To:         "ops@example.com",
+			SecretName: "smtp-credentials",
+		},
+	}
+
+	_, err := model.HelmTemplateFromStruct(t, model.BackupHelmChart, helmValues)
+	assert.Error(t, err, "error must be seen for a webhook url set in the values")
+	assert.Contains(t, err.Error(), "notifications.slackWebhookUrl is not supported")
+
+	helmValues.Notifications.SlackWebhookUrl = ""
+	helmValues.Notifications.WebhookSecretName = "backup-notifications"
+	manifests, err := model.HelmTemplateFromStruct(t, model.BackupHelmChart, helmValues)
+	assert.NoError(t, err, "error seen while trying to install helm backup with notifications")
+	cronjobs := manifests.OfType(&batchv1.CronJob{})
+	assert.Len(t, cronjobs, 1, "there should be only one cronjob")
+	container := cronjobs[0].(*batchv1.CronJob).Spec.JobTemplate.Spec.Template.Spec.Containers[0]
+
+	envVars := map[string]v1.EnvVar{}
+	for _, envVar := range container.Env {
+		envVars[envVar.Name] = envVar
+	}
+	assert.Equal(t, "failure", envVars["NOTIFY_ON"].Value)
+	for name, key := range map[string]string{"NOTIFY_WEBHOOK_URL": "webhookUrl", "NOTIFY_SLACK_WEBHOOK_URL": "slackWebhookUrl"} {
+		assert.Empty(t, envVars[name].Value)
+		assert.Equal(t, "backup-notifications", envVars[name].ValueFrom.SecretKeyRef.Name)
+		assert.Equal(t, key, envVars[name].ValueFrom.SecretKeyRef.Key)
+	}
+	assert.Equal(t, "smtp.example.com", envVars["NOTIFY_SMTP_HOST"].Value)
+	assert.Equal(t, "ops@example.com", envVars["NOTIFY_EMAIL_TO"].Value)
+	assert.Equal(t, "smtp-credentials", envVars["NOTIFY_SMTP_PASSWORD"].ValueFrom.SecretKeyRef.Name)
+	assert.Equal(t, "password", envVars["NOTIFY_SMTP_PASSWORD"].ValueFrom.SecretKeyRef.Key)
+}
+
+// TestBackupConcurrency checks the number of databases backed up in parallel is passed to the backup container
+func TestBackupConcurrency(t *testing.T) {
+	t.Parallel()
+
+	helmValues := model.DefaultNeo4jBackupValues
+	helmValues.DisableLookups = true
+	helmValues.Backup.DatabaseAdminServiceName = "standalone-admin"
+	helmValues.Backup.Database = "neo4j,system,movies"
+
+	for _, concurrency := range []string{"", "2"} {
+		helmValues.Backup.Concurrency = concurrency
+		manifests, err := model.HelmTemplateFromStruct(t, model.BackupHelmChart, helmValues)
+		assert.NoError(t, err, "error seen while trying to install helm backup with concurrency %s", concurrency)
+		cronjobs := manifests.OfType(&batchv1.CronJob{})
+		assert.Len(t, cronjobs, 1, "there should be only one cronjob")
+		container := cronjobs[0].(*batchv1.CronJob).Spec.JobTemplate.Spec.Template.Spec.Containers[0]
+
+		envVars := map[string]string{}
+		for _, envVar := range container.Env {
+			envVars[envVar.Name] = envVar.Value
+		}
+		want := concurrency
+		if want == "" {
+			want = "1"
+		}
+		assert.Equal(t, want, envVars["BACKUP_CONCURRENCY"])
+	}
+}
+
+func TestBackupRetry(t *testing.T) {
+	t.Parallel()
+
+	helmValues := model.DefaultNeo4jBackupValues
+	helmValues.DisableLookups = true
+	helmValues.Backup.DatabaseAdminServiceName = "standalone-admin"
+	helmValues.Backup.Database = "neo4j"
+
+	envVarsFor := func(retry model.BackupRetry) map[string]string {
+		helmValues.Backup.Retry = retry
+		manifests, err := model.HelmTemplateFromStruct(t, model.BackupHelmChart, helmValues)
+		assert.NoError(t, err, "error seen while trying to install helm backup with retry %v", retry)
+		cronjobs := manifests.OfType(&batchv1.CronJob{})
+		assert.Len(t, cronjobs, 1, "there should be only one cronjob")
+		container := cronjobs[0].(*batchv1.CronJob).Spec.JobTemplate.Spec.Template.Spec.Containers[0]
+
+		envVars := map[string]string{}
+		for _, envVar := range container.Env {
+			envVars[envVar.Name] = envVar.Value
+		}
+		return envVars
+	}
+
+	envVars := envVarsFor(model.BackupRetry{})
+	assert.Equal(t, "3", envVars["RETRY_MAX_ATTEMPTS"])
+	assert.Equal(t, "5s", envVars["RETRY_INITIAL_BACKOFF"])
+	assert.Equal(t, "2m", envVars["RETRY_MAX_BACKOFF"])
+	assert.Equal(t, "0.2", envVars["RETRY_JITTER"])
+
+	envVars = envVarsFor(model.BackupRetry{MaxAttempts: "5", InitialBackoff: "30s", MaxBackoff: "10m", Jitter: "0"})
+	assert.Equal(t, "5", envVars["RETRY_MAX_ATTEMPTS"])
+	assert.Equal(t, "30s", envVars["RETRY_INITIAL_BACKOFF"])
+	assert.Equal(t, "10m", envVars["RETRY_MAX_BACKOFF"])
+	assert.Equal(t, "0", envVars["RETRY_JITTER"])
+}
+
+func TestBackupConnectivity(t *testing.T) {
+	t.Parallel()
+
+	helmValues := model.DefaultNeo4jBackupValues
+	helmValues.DisableLookups = true
+	helmValues.Backup.DatabaseAdminServiceName = "standalone-admin"
+	helmValues.Backup.Database = "neo4j"
+
+	envVarsFor := func(connectivity model.BackupConnectivity) map[string]string {
+		helmValues.Backup.Connectivity = connectivity
+		manifests, err := model.HelmTemplateFromStruct(t, model.BackupHelmChart, helmValues)
+		assert.NoError(t, err, "error seen while trying to install helm backup with connectivity %v", connectivity)
+		cronjobs := manifests.OfType(&batchv1.CronJob{})
+		assert.Len(t, cronjobs, 1, "there should be only one cronjob")
+		container := cronjobs[0].(*batchv1.CronJob).Spec.JobTemplate.Spec.Template.Spec.Containers[0]
+
+		envVars := map[string]string{}
+		for _, envVar := range container.Env {
+			envVars[envVar.Name] = envVar.Value
+		}
+		return envVars
+	}
+
+	envVars := envVarsFor(model.BackupConnectivity{})
+	assert.Equal(t, "10s", envVars["CONNECTIVITY_TIMEOUT"])
+	assert.Empty(t, envVars["PROBE_BOLT_PORT"])
+	assert.Empty(t, envVars["PROBE_HTTP_PORT"])
+
+	envVars = envVarsFor(model.BackupConnectivity{Timeout: "30s", BoltPort: "7687", HttpPort: "7474"})
+	assert.Equal(t, "30s", envVars["CONNECTIVITY_TIMEOUT"])
+	assert.Equal(t, "7687", envVars["PROBE_BOLT_PORT"])
+	assert.Equal(t, "7474", envVars["PROBE_HTTP_PORT"])
+}
+
+func TestBackupUpload(t *testing.T) {
+	t.Parallel()
+
+	helmValues := model.DefaultNeo4jBackupValues
+	helmValues.DisableLookups = true
+	helmValues.Backup.DatabaseAdminServiceName = "standalone-admin"
+	helmValues.Backup.Database = "neo4j"
+
+	for _, upload := range []model.BackupUpload{{}, {PartSizeMB: "256", Concurrency: "8"}} {
+		helmValues.Backup.Upload = upload
+		manifests, err := model.HelmTemplateFromStruct(t, model.BackupHelmChart, helmValues)
+		assert.NoError(t, err, "error seen while trying to install helm backup with upload %v", upload)
+		cronjobs := manifests.OfType(&batchv1.CronJob{})
+		assert.Len(t, cronjobs, 1, "there should be only one cronjob")
+		container := cronjobs[0].(*batchv1.CronJob).Spec.JobTemplate.Spec.Template.Spec.Containers[0]
+
+		envVars := map[string]string{}
+		for _, envVar := range container.Env {
+			envVars[envVar.Name] = envVar.Value
+		}
+		wantPartSize, wantConcurrency := "1024", "4"
+		if upload.PartSizeMB != "" {
+			wantPartSize, wantConcurrency = upload.PartSizeMB, upload.Concurrency
+		}
+		assert.Equal(t, wantPartSize, envVars["UPLOAD_PART_SIZE_MB"])
+		assert.Equal(t, wantConcurrency, envVars["UPLOAD_CONCURRENCY"])
+	}
+}
+
+// TestBackupUploadPerDatabase checks BACKUP_UPLOAD_PER_DATABASE is set and uploading per database is rejected without a cloudProvider
+func TestBackupUploadPerDatabase(t *testing.T) {
+	t.Parallel()
+
+	helmValues := model.DefaultNeo4jBackupValues
+	helmValues.DisableLookups = true
+	helmValues.Backup.DatabaseAdminServiceName = "standalone-admin"
+	helmValues.Backup.Database = "neo4j"
+	helmValues.Backup.UploadPerDatabase = true
+
+	_, err := model.HelmTemplateFromStruct(t, model.BackupHelmChart, helmValues)
+	assert.Error(t, err, "error must be seen when uploading per database is enabled without a cloudProvider")
+	assert.Contains(t, err.Error(), "Uploading per database requires a cloudProvider")
+
+	helmValues.Backup.SecretName = "demo"
+	helmValues.Backup.CloudProvider = "aws"
+	helmValues.Backup.BucketName = "demo2"
+	manifests, err := model.HelmTemplateFromStruct(t, model.BackupHelmChart, helmValues)
+	assert.NoError(t, err, "error seen while trying to install helm backup with upload per database")
+	cronjobs := manifests.OfType(&batchv1.CronJob{})
+	assert.Len(t, cronjobs, 1, "there should be only one cronjob")
+	container := cronjobs[0].(*batchv1.CronJob).Spec.JobTemplate.Spec.Template.Spec.Containers[0]
+
+	envVars := map[string]string{}
+	for _, envVar := range container.Env {
+		envVars[envVar.Name] = envVar.Value
+	}
+	assert.Equal(t, "true", envVars["BACKUP_UPLOAD_PER_DATABASE"])
+}
+
+func TestBackupChainCache(t *testing.T) {
+	t.Parallel()
+
+	helmValues := model.DefaultNeo4jBackupValues
+	helmValues.DisableLookups = true
+	helmValues.Backup.DatabaseAdminServiceName = "standalone-admin"
+	helmValues.Backup.Database = "neo4j"
+	helmValues.Backup.ChainCache = "remote"
+
+	_, err := model.HelmTemplateFromStruct(t, model.BackupHelmChart, helmValues)
+	assert.Error(t, err, "error must be seen for an invalid chainCache")
+	assert.Contains(t, err.Error(), "Invalid chainCache remote")
+
+	helmValues.Backup.ChainCache = "download"
+	_, err = model.HelmTemplateFromStruct(t, model.BackupHelmChart, helmValues)
+	assert.Error(t, err, "error must be seen when the chain is downloaded without a cloudProvider")
+	assert.Contains(t, err.Error(), "Downloading the backup chain requires a cloudProvider")
+
+	helmValues.Backup.SecretName = "demo"
+	helmValues.Backup.CloudProvider = "aws"
+	helmValues.Backup.BucketName = "demo2"
+	manifests, err := model.HelmTemplateFromStruct(t, model.BackupHelmChart, helmValues)
+	assert.NoError(t, err, "error seen while trying to install helm backup with chainCache")
+	cronjobs := manifests.OfType(&batchv1.CronJob{})
+	assert.Len(t, cronjobs, 1, "there should be only one cronjob")
+	container := cronjobs[0].(*batchv1.CronJob).Spec.JobTemplate.Spec.Template.Spec.Containers[0]
+
+	envVars := map[string]string{}
+	for _, envVar := range container.Env {
+		envVars[envVar.Name] = envVar.Value
+	}
+	assert.Equal(t, "download", envVars["BACKUP_CHAIN_CACHE"])
+}
+
+func TestBackupDrill(t *testing.T) {
+	t.Parallel()
+
+	helmValues := model.DefaultNeo4jBackupValues
+	helmValues.DisableLookups = true
+	helmValues.Backup.Database = "neo4j,system"
+	helmValues.Drill.Enabled = true
+
+	_, err := model.HelmTemplateFromStruct(t, model.BackupHelmChart, helmValues)
+	assert.Error(t, err, "error must be seen when the drill is enabled without a cloudProvider")
+	assert.Contains(t, err.Error(), "The backup drill verifies the backups present in the bucket and requires a cloudProvider")
+
+	// no backup server is needed to drill the backups present in the bucket
+	helmValues.Backup.SecretName = "demo"
+	helmValues.Backup.CloudProvider = "gcp"
+	helmValues.Backup.BucketName = "demo2"
+	manifests, err := model.HelmTemplateFromStruct(t, model.BackupHelmChart, helmValues)
+	assert.NoError(t, err, "error seen while trying to install helm backup with drill")
+	cronjobs := manifests.OfType(&batchv1.CronJob{})
+	assert.Len(t, cronjobs, 1, "there should be only one cronjob")
+	container := cronjobs[0].(*batchv1.CronJob).Spec.JobTemplate.Spec.Template.Spec.Containers[0]
+
+	envVars := map[string]string{}
+	for _, envVar := range container.Env {
+		envVars[envVar.Name] = envVar.Value
+	}
+	assert.Equal(t, "drill", envVars["OPERATION"])
+	assert.Equal(t, "/backups/drill", envVars["DRILL_SCRATCH_PATH"])
+	assert.Equal(t, "neo4j,system", envVars["DATABASE"])
+}
+
+func TestBackupRestore(t *testing.T) {
+	t.Parallel()
+
+	helmValues := model.DefaultNeo4jBackupValues
+	helmValues.DisableLookups = true
+	helmValues.Backup.Database = "neo4j"
+	helmValues.Restore.Enabled = true
+	helmValues.Restore.PointInTime = "yesterday"
+
+	_, err := model.HelmTemplateFromStruct(t, model.BackupHelmChart, helmValues)
+	assert.Error(t, err, "error must be seen for an invalid restore pointInTime")
+	assert.Contains(t, err.Error(), "Invalid restore pointInTime yesterday")
+
+	helmValues.Restore.PointInTime = "2024-03-10T10:00:00Z"
+	helmValues.Restore.ToPathData = "restore/data/databases"
+	_, err = model.HelmTemplateFromStruct(t, model.BackupHelmChart, helmValues)
+	assert.Error(t, err, "error must be seen for a relative restore path")
+	assert.Contains(t, err.Error(), "Invalid restore toPathData restore/data/databases")
+
+	helmValues.Drill.Enabled = true
+	helmValues.Restore.ToPathData = "/restore/data/databases"
+	_, err = model.HelmTemplateFromStruct(t, model.BackupHelmChart, helmValues)
+	assert.Error(t, err, "error must be seen when both restore and drill are enabled")
+	assert.Contains(t, err.Error(), "Please set either restore.enabled or drill.enabled")
+
+	// no backup server is needed to restore the backups present in the bucket
+	helmValues.Drill.Enabled = false
+	helmValues.Restore.OverwriteDestination = true
+	helmValues.Restore.Volume = map[string]interface{}{"persistentVolumeClaim": map[string]interface{}{"claimName": "data-neo4j-0"}}
+	manifests, err := model.HelmTemplateFromStruct(t, model.BackupHelmChart, helmValues)
+	assert.NoError(t, err, "error seen while trying to install helm backup with restore")
+	cronjobs := manifests.OfType(&batchv1.CronJob{})
+	assert.Len(t, cronjobs, 1, "there should be only one cronjob")
+	podSpec := cronjobs[0].(*batchv1.CronJob).Spec.JobTemplate.Spec.Template.Spec
+	container := podSpec.Containers[0]
+
+	envVars := map[string]string{}
+	for _, envVar := range container.Env {
+		envVars[envVar.Name] = envVar.Value
+	}
+	assert.Equal(t, "restore", envVars["OPERATION"])
+	assert.Equal(t, "neo4j", envVars["DATABASE"])
+	assert.Equal(t, "2024-03-10T10:00:00Z", envVars["RESTORE_POINT_IN_TIME"])
+	assert.Equal(t, "true", envVars["RESTORE_OVERWRITE_DESTINATION"])
+	assert.Equal(t, "/restore/data/databases", envVars["RESTORE_TO_PATH_DATA"])
+
+	var restoreMounted bool
+	for _, volumeMount := range container.VolumeMounts {
+		if volumeMount.Name == "restore" {
+			restoreMounted = true
+			assert.Equal(t, "/restore", volumeMount.MountPath)
+		}
+	}
+	assert.True(t, restoreMounted, "the restore volume should be mounted at /restore")
+	var restoreVolume bool
+	for _, volume := range podSpec.Volumes {
+		if volume.Name == "restore" {
+			restoreVolume = true
+			assert.Equal(t, "data-neo4j-0", volume.PersistentVolumeClaim.ClaimName)
+		}
+	}
+	assert.True(t, restoreVolume, "the restore volume should be present")
+}
+
+func TestBackupConsistencyCheckFailureThreshold(t *testing.T) {
+	t.Parallel()
+
+	helmValues := model.DefaultNeo4jBackupValues
+	helmValues.DisableLookups = true
+	helmValues.Backup.DatabaseAdminServiceName = "standalone-admin"
+	helmValues.ConsistencyCheck.Enable = true
+	helmValues.ConsistencyCheck.FailureThreshold = "-1"
+
+	_, err := model.HelmTemplateFromStruct(t, model.BackupHelmChart, helmValues)
+	assert.Error(t, err, "error must be seen for a negative failureThreshold")
+	assert.Contains(t, err.Error(), "Invalid consistencyCheck failureThreshold -1")
+
+	helmValues.ConsistencyCheck.FailureThreshold = "10"
+	manifests, err := model.HelmTemplateFromStruct(t, model.BackupHelmChart, helmValues)
+	assert.NoError(t, err, "error seen while trying to install helm backup with a consistency check failureThreshold")
+	cronjobs := manifests.OfType(&batchv1.CronJob{})
+	assert.Len(t, cronjobs, 1, "there should be only one cronjob")
+	container := cronjobs[0].(*batchv1.CronJob).Spec.JobTemplate.Spec.Template.Spec.Containers[0]
+
+	envVars := map[string]string{}
+	for _, envVar := range container.Env {
+		envVars[envVar.Name] = envVar.Value
+	}
+	assert.Equal(t, "true", envVars["CONSISTENCY_CHECK_ENABLE"])
+	assert.Equal(t, "10", envVars["CONSISTENCY_CHECK_FAILURE_THRESHOLD"])
+}
+
+func TestBackupHooks(t *testing.T) {
+	t.Parallel()
+
+	helmValues := model.DefaultNeo4jBackupValues
+	helmValues.DisableLookups = true
+	helmValues.Backup.DatabaseAdminServiceName = "standalone-admin"
+	helmValues.Hooks.PreBackup = model.BackupHook{Command: "curl -X POST \"$INGEST_URL/pause\"", FailurePolicy: "retry"}
+
+	_, err := model.HelmTemplateFromStruct(t, model.BackupHelmChart, helmValues)
+	assert.Error(t, err, "error must be seen for an invalid failurePolicy")
+	assert.Contains(t, err.Error(), "Invalid hooks preBackup failurePolicy retry")
+
+	helmValues.Hooks.PreBackup.FailurePolicy = ""
+	helmValues.Hooks.PostUpload = model.BackupHook{Url: "catalog.example.com"}
+	_, err = model.HelmTemplateFromStruct(t, model.BackupHelmChart, helmValues)
+	assert.Error(t, err, "error must be seen for a url without scheme")
+	assert.Contains(t, err.Error(), "Invalid hooks postUpload url catalog.example.com")
+
+	helmValues.Hooks.Timeout = "2m"
+	helmValues.Hooks.PostUpload = model.BackupHook{Url: "https://catalog.example.com/backups", FailurePolicy: "ignore"}
+	helmValues.Hooks.OnFailure = model.BackupHook{Command: "curl -X POST \"$INGEST_URL/resume\""}
+	manifests, err := model.HelmTemplateFromStruct(t, model.BackupHelmChart, helmValues)
+	assert.NoError(t, err, "error seen while trying to install helm backup with hooks")
+	cronjobs := manifests.OfType(&batchv1.CronJob{})
+	assert.Len(t, cronjobs, 1, "there should be only one cronjob")
+	container := cronjobs[0].(*batchv1.CronJob).Spec.JobTemplate.Spec.Template.Spec.Containers[0]
+
+	envVars := map[string]string{}
+	for _, envVar := range container.Env {
+		envVars[envVar.Name] = envVar.Value
+	}
+	assert.Equal(t, "2m", envVars["HOOK_TIMEOUT"])
+	assert.Equal(t, "curl -X POST \"$INGEST_URL/pause\"", envVars["HOOK_PRE_BACKUP_COMMAND"])
+	assert.Equal(t, "fail", envVars["HOOK_PRE_BACKUP_FAILURE_POLICY"])
+	assert.Equal(t, "https://catalog.example.com/backups", envVars["HOOK_POST_UPLOAD_URL"])
+	assert.Equal(t, "ignore", envVars["HOOK_POST_UPLOAD_FAILURE_POLICY"])
+	assert.Equal(t, "curl -X POST \"$INGEST_URL/resume\"", envVars["HOOK_ON_FAILURE_COMMAND"])
+}
+
+// TestBackupReplicas checks the replica destinations are passed as REPLICATION_DESTINATIONS along with their credentials and volumes
+func TestBackupReplicas(t *testing.T) {
+	t.Parallel()
+
+	helmValues := model.DefaultNeo4jBackupValues
+	helmValues.DisableLookups = true
+	helmValues.Backup.DatabaseAdminServiceName = "standalone-admin"
+	helmValues.Backup.Replicas = []model.BackupReplica{
+		{CloudProvider: "aws", BucketName: "dr-backups/neo4j", SecretName: "awscred-dr", SecretKeyName: "credentials"},
+		{CloudProvider: "filesystem", BucketName: "neo4j"},
+	}
+
+	_, err := model.HelmTemplateFromStruct(t, model.BackupHelmChart, helmValues)
+	assert.Error(t, err, "error must be seen when replicas are set without a cloudProvider")
+	assert.Contains(t, err.Error(), "Backup replication copies the files uploaded to the primary bucket and requires a cloudProvider")
+
+	helmValues.Backup.SecretName = "demo"
+	helmValues.Backup.SecretKeyName = "credentials"
+	helmValues.Backup.CloudProvider = "gcp"
+	helmValues.Backup.BucketName = "demo2"
+	_, err = model.HelmTemplateFromStruct(t, model.BackupHelmChart, helmValues)
+	assert.Error(t, err, "error must be seen when the volume of a filesystem replica is missing")
+	assert.Contains(t, err.Error(), "Empty volume for the filesystem replica 1")
+
+	helmValues.Backup.Replicas[1].Volume = map[string]interface{}{
+		"persistentVolumeClaim": map[string]interface{}{
+			"claimName": "nfs-dr-pvc",
+		},
+	}
+	manifests, err := model.HelmTemplateFromStruct(t, model.BackupHelmChart, helmValues)
+	assert.NoError(t, err, "error seen while trying to install helm backup with replicas")
+	cronjobs := manifests.OfType(&batchv1.CronJob{})
+	assert.Len(t, cronjobs, 1, "there should be only one cronjob")
+	podSpec := cronjobs[0].(*batchv1.CronJob).Spec.JobTemplate.Spec.Template.Spec
+
+	envVars := map[string]string{}
+	for _, envVar := range podSpec.Containers[0].Env {
+		envVars[envVar.Name] = envVar.Value
+	}
+	assert.JSONEq(t, `[{"provider":"aws","bucket":"dr-backups/neo4j","credentialPath":"/replica-credentials/0/credentials"},{"provider":"filesystem","bucket":"neo4j","path":"/replicas/1"}]`, envVars["REPLICATION_DESTINATIONS"])
+
+	var secretName, claimName string
+	for _, volume := range podSpec.Volumes {
+		if volume.Name == "replica-credentials-0" && volume.Secret != nil {
+			secretName = volume.Secret.SecretName
+		}
+		if volume.Name == "replica-1" && volume.PersistentVolumeClaim != nil {
+			claimName = volume.PersistentVolumeClaim.ClaimName
+		}
+	}
+	assert.Equal(t, "awscred-dr", secretName, "replica credentials volume missing")
+	assert.Equal(t, "nfs-dr-pvc", claimName, "replica volume missing")
+	assert.Contains(t, podSpec.Containers[0].VolumeMounts, v1.VolumeMount{Name: "replica-credentials-0", MountPath: "/replica-credentials/0", ReadOnly: true})
+	assert.Contains(t, podSpec.Containers[0].VolumeMounts, v1.VolumeMount{Name: "replica-1", MountPath: "/replicas/1"})
+}
+
+func TestBackupDiscovery(t *testing.T) {
+	t.Parallel()
+
+	helmValues := model.DefaultNeo4jBackupValues
+	helmValues.DisableLookups = true
+	helmValues.Backup.Database = "neo4j,sales"
+	helmValues.Backup.DatabaseNamespace = "neo4j"
+	helmValues.Backup.Discovery = model.BackupDiscovery{Enabled: true}
+
+	_, err := model.HelmTemplateFromStruct(t, model.BackupHelmChart, helmValues)
+	assert.Error(t, err, "error must be seen when discovery is enabled without neo4jName")
+	assert.Contains(t, err.Error(), "Empty discovery neo4jName")
+
+	helmValues.Backup.Discovery.Neo4jName = "mycluster"
+	helmValues.Backup.DatabaseAdminServiceName = "standalone-admin"
+	_, err = model.HelmTemplateFromStruct(t, model.BackupHelmChart, helmValues)
+	assert.Error(t, err, "error must be seen when discovery is enabled along with databaseAdminServiceName")
+	assert.Contains(t, err.Error(), "Cannot use both")
+
+	helmValues.Backup.DatabaseAdminServiceName = ""
+	helmValues.Backup.Discovery.Server = "server-2"
+	helmValues.Backup.Discovery.SecretName = "neo4j-credentials"
+	manifests, err := model.HelmTemplateFromStruct(t, model.BackupHelmChart, helmValues)
+	assert.NoError(t, err, "error seen while trying to install helm backup with discovery")
+	cronjobs := manifests.OfType(&batchv1.CronJob{})
+	assert.Len(t, cronjobs, 1, "there should be only one cronjob")
+	podSpec := cronjobs[0].(*batchv1.CronJob).Spec.JobTemplate.Spec.Template.Spec
+
+	envVars := map[string]string{}
+	secretRefs := map[string]string{}
+	for _, envVar := range podSpec.Containers[0].Env {
+		envVars[envVar.Name] = envVar.Value
+		if envVar.ValueFrom != nil && envVar.ValueFrom.SecretKeyRef != nil {
+			secretRefs[envVar.Name] = envVar.ValueFrom.SecretKeyRef.Name
+		}
+	}
+	assert.Equal(t, "true", envVars["BACKUP_DISCOVERY"])
+	assert.Equal(t, "mycluster", envVars["DISCOVERY_NEO4J_NAME"])
+	assert.Equal(t, "server-2", envVars["DISCOVERY_SERVER"])
+	assert.Equal(t, "7474", envVars["DISCOVERY_HTTP_PORT"])
+	assert.Equal(t, "neo4j-credentials", secretRefs["NEO4J_USERNAME"])
+	assert.Equal(t, "neo4j-credentials", secretRefs["NEO4J_PASSWORD"])
+
+	serviceAccounts := manifests.OfType(&v1.ServiceAccount{})
+	assert.Len(t, serviceAccounts, 1, "a service account must be created for the discovery")
+	assert.Equal(t, serviceAccounts[0].(*v1.ServiceAccount).Name, podSpec.ServiceAccountName)
+	roles := manifests.OfType(&rbacv1.Role{})
+	assert.Len(t, roles, 1, "there should be only one role")
+	assert.Equal(t, "neo4j", roles[0].(*rbacv1.Role).Namespace)
+	assert.ElementsMatch(t, []string{"services", "pods"}, roles[0].(*rbacv1.Role).Rules[0].Resources)
+	roleBindings := manifests.OfType(&rbacv1.RoleBinding{})
+	assert.Len(t, roleBindings, 1, "there should be only one role binding")
+	assert.Equal(t, podSpec.ServiceAccountName, roleBindings[0].(*rbacv1.RoleBinding).Subjects[0].Name)
+
+	helmValues.ServiceAccountName = "backup-sa"
+	manifests, err = model.HelmTemplateFromStruct(t, model.BackupHelmChart, helmValues)
+	assert.NoError(t, err, "error seen while trying to install helm backup with discovery and serviceAccountName")
+	assert.Empty(t, manifests.OfType(&v1.ServiceAccount{}), "no service account must be created when serviceAccountName is set")
+	roleBindings = manifests.OfType(&rbacv1.RoleBinding{})
+	assert.Len(t, roleBindings, 1, "there should be only one role binding")
+	assert.Equal(t, "backup-sa", roleBindings[0].(*rbacv1.RoleBinding).Subjects[0].Name)
+}
+
+func TestBackupEndpoints(t *testing.T) {
+	t.Parallel()
+
+	helmValues := model.DefaultNeo4jBackupValues
+	helmValues.DisableLookups = true
+	helmValues.Backup.Database = "neo4j"
+	helmValues.Backup.DatabaseBackupEndpoints = "server-3-admin:6362,server-2-admin:6362"
+	helmValues.Backup.DatabaseAdminServiceName = "standalone-admin"
+
+	_, err := model.HelmTemplateFromStruct(t, model.BackupHelmChart, helmValues)
+	assert.Error(t, err, "error must be seen when databaseBackupEndpoints is set along with databaseAdminServiceName")
+	assert.Contains(t, err.Error(), "Cannot use both")
+
+	helmValues.Backup.DatabaseAdminServiceName = ""
+	helmValues.Backup.Discovery = model.BackupDiscovery{HttpPort: "7475", SecretName: "neo4j-credentials"}
+	manifests, err := model.HelmTemplateFromStruct(t, model.BackupHelmChart, helmValues)
+	assert.NoError(t, err, "error seen while trying to install helm backup with databaseBackupEndpoints")
+	cronjobs := manifests.OfType(&batchv1.CronJob{})
+	assert.Len(t, cronjobs, 1, "there should be only one cronjob")
+	podSpec := cronjobs[0].(*batchv1.CronJob).Spec.JobTemplate.Spec.Template.Spec
+
+	envVars := map[string]string{}
+	secretRefs := map[string]string{}
+	for _, envVar := range podSpec.Containers[0].Env {
+		envVars[envVar.Name] = envVar.Value
+		if envVar.ValueFrom != nil && envVar.ValueFrom.SecretKeyRef != nil {
+			secretRefs[envVar.Name] = envVar.ValueFrom.SecretKeyRef.Name
+		}
+	}
+	assert.Equal(t, "server-3-admin:6362,server-2-admin:6362", envVars["DATABASE_BACKUP_ENDPOINTS"])
+	assert.Equal(t, "false", envVars["BACKUP_DISCOVERY"])
+	assert.Equal(t, "7475", envVars["DISCOVERY_HTTP_PORT"])
+	assert.Equal(t, "neo4j-credentials", secretRefs["NEO4J_PASSWORD"])
+	assert.Empty(t, podSpec.ServiceAccountName, "no service account is required for the backup endpoints")
+	assert.Empty(t, manifests.OfType(&rbacv1.Role{}), "no role is required for the backup endpoints")
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"github.com/neo4j/helm-charts/internal/model"
 	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
 	v1 "k8s.io/api/networking/v1"
 	"testing"
 )
@@ -105,3 +106,32 @@ func TestReverseProxyIngressHostName(t *testing.T) {
 	ingressHostName := ingressList[0].(*v1.Ingress).Spec.Rules[0].Host
 	assert.Equal(t, ingressHostName, "demo.com", "ingress hostname not matching")
 }
+
+// TestReverseProxyConnectivityChecks checks if the connectivity timeout and probe ports are passed to the reverse proxy or not
+func TestReverseProxyConnectivityChecks(t *testing.T) {
+	t.Parallel()
+
+	envVarsFor := func(helmValues model.Neo4jReverseProxyValues) map[string]string {
+		manifests, err := model.HelmTemplateFromStruct(t, model.ReverseProxyHelmChart, helmValues)
+		assert.NoError(t, err, "error seen while testing connectivity checks with reverse proxy helm chart")
+		deployments := manifests.OfType(&appsv1.Deployment{})
+		assert.Len(t, deployments, 1, fmt.Sprintf("number of deployments should be 1 , not equal with %d", len(deployments)))
+		envVars := map[string]string{}
+		for _, envVar := range deployments[0].(*appsv1.Deployment).Spec.Template.Spec.Containers[0].Env {
+			envVars[envVar.Name] = envVar.Value
+		}
+		return envVars
+	}
+
+	envVars := envVarsFor(model.DefaultNeo4jReverseProxyValues)
+	assert.Equal(t, "10s", envVars["CONNECTIVITY_TIMEOUT"])
+	assert.Equal(t, "", envVars["PROBE_BOLT_PORT"])
+	assert.Equal(t, "", envVars["PROBE_HTTP_PORT"])
+
+	helmValues := model.DefaultNeo4jReverseProxyValues
+	helmValues.ReverseProxy.Connectivity = model.ReverseProxyConnectivity{Timeout: "30s", BoltPort: "7687", HttpPort: "7474"}
+	envVars = envVarsFor(helmValues)
+	assert.Equal(t, "30s", envVars["CONNECTIVITY_TIMEOUT"])
+	assert.Equal(t, "7687", envVars["PROBE_BOLT_PORT"])
+	assert.Equal(t, "7474", envVars["PROBE_HTTP_PORT"])
+}
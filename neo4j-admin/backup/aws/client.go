@@ -5,9 +5,13 @@ import (
 	"fmt"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/common"
 	"os"
 )
 
+// awsClient implements common.StorageBackend
+var _ common.StorageBackend = &awsClient{}
+
 type awsClient struct {
 	cfg *aws.Config
 }
@@ -2,15 +2,23 @@ package aws
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
 	smithyendpoints "github.com/aws/smithy-go/endpoints"
 	"github.com/neo4j/helm-charts/neo4j-admin/backup/common"
-	"log"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/logging"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/retry"
+	"log/slog"
 	"os"
+	"path"
+	"slices"
 	"strings"
+	"sync"
 )
 
 type resolverV2 struct{}
@@ -20,23 +28,18 @@ func (*resolverV2) ResolveEndpoint(ctx context.Context, params s3.EndpointParame
 	return s3.NewDefaultEndpointResolverV2().ResolveEndpoint(ctx, params)
 }
 
-// CheckBucketAccess checks if the given bucket name is accessible or not
-func (a *awsClient) CheckBucketAccess(bucketName string) error {
+// CheckAccess checks if the given bucket name is accessible or not
+func (a *awsClient) CheckAccess(bucketName string) error {
 
 	client := a.getS3Client()
+	parentBucketName, prefix := common.SplitBucketName(bucketName)
 	//Create an Amazon S3 service client
 	s3Input := &s3.ListObjectsV2Input{
-		Bucket: aws.String(bucketName),
+		Bucket: aws.String(parentBucketName),
 	}
-	if strings.Contains(bucketName, "/") {
-		firstIndex := strings.Index(bucketName, "/")
-		name := bucketName[:firstIndex]
-		prefix := bucketName[firstIndex+1:]
-		log.Printf("Name = %s , Prefix = %s", name, prefix)
-		s3Input = &s3.ListObjectsV2Input{
-			Bucket: aws.String(name),
-			Prefix: aws.String(prefix),
-		}
+	if prefix != "" {
+		slog.Debug("Checking s3 bucket prefix", "bucket", parentBucketName, "prefix", prefix)
+		s3Input.Prefix = aws.String(prefix)
 	}
 
 	// Get the first page of results for ListObjectsV2 for a bucket
@@ -44,12 +47,12 @@ func (a *awsClient) CheckBucketAccess(bucketName string) error {
 	if err != nil {
 		return fmt.Errorf("Unable to connect to s3 bucket %s \n Here's why: %v\n", bucketName, err)
 	}
-	if strings.Contains(bucketName, "/") {
+	if prefix != "" {
 		if len(objects.Contents) == 0 {
 			return fmt.Errorf("s3 Bucket %s does not exist", bucketName)
 		}
 	}
-	log.Printf("Connectivity with S3 Bucket '%s' established", bucketName)
+	slog.Info("Connectivity with s3 bucket established", "bucket", bucketName)
 
 	return nil
 }
@@ -58,92 +61,405 @@ func (a *awsClient) CheckBucketAccess(bucketName string) error {
 func (a *awsClient) UploadFile(fileNames []string, bucketName string) error {
 
 	s3Client := a.getS3Client()
-	parentBucketName := bucketName
-	// if bucketName is demo/test/test2
-	// parentBucketName will be "demo"
-	if strings.Contains(bucketName, "/") {
-		index := strings.Index(bucketName, "/")
-		parentBucketName = bucketName[:index]
-	}
 	//location := "/backups"
 	location := os.Getenv("LOCATION")
 	for _, fileName := range fileNames {
 
 		filePath := fmt.Sprintf("%s/%s", location, fileName)
-		yes, err := common.IsFileBigger(filePath)
+		// the checksums computed for the backup manifest are reused , the file is not read again
+		checksums, err := common.FileChecksums(filePath)
+		if err != nil {
+			return err
+		}
+		options, err := common.UploadOptionsFromEnv()
+		if err != nil {
+			return err
+		}
+		//use UploadLargeObject if file size is more than the upload part size
+		err = retry.Do("upload", isRetryable, func() error {
+			if options.IsMultipart(checksums.Size) {
+				return a.UploadLargeObject(fileName, location, bucketName, checksums)
+			}
+			return a.putObject(s3Client, fileName, location, bucketName, checksums)
+		})
 		if err != nil {
 			return err
 		}
-		//use UploadLargeObject if file size is more than 1GB
-		if yes {
-			err = a.UploadLargeObject(fileName, location, bucketName, parentBucketName)
+	}
+	return nil
+}
+
+// putObject uploads the file in a single request. s3 verifies the provided SHA-256 checksum and rejects the upload on mismatch
+func (a *awsClient) putObject(s3Client *s3.Client, fileName string, location string, bucketName string, checksums common.Checksums) error {
+	filePath := fmt.Sprintf("%s/%s", location, fileName)
+	// if bucketName is demo/test/test2
+	// parentBucketName will be "demo"
+	parentBucketName, prefix := common.SplitBucketName(bucketName)
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return retry.Permanent(fmt.Errorf("Couldn't open file %v to upload. Here's why: %w\n", filePath, err))
+	}
+	defer file.Close()
+
+	metadata, err := common.ObjectMetadata(location, fileName, checksums)
+	if err != nil {
+		return retry.Permanent(err)
+	}
+
+	keyName := common.ObjectName(prefix, fileName)
+	logger := logging.ForFile(fileName)
+	logger.Info("Starting upload of file", "path", filePath, "key", keyName)
+	input := &s3.PutObjectInput{
+		Bucket:            aws.String(parentBucketName),
+		Key:               aws.String(keyName),
+		Body:              file,
+		ChecksumAlgorithm: types.ChecksumAlgorithmSha256,
+		ChecksumSHA256:    aws.String(checksums.SHA256Base64()),
+		Metadata:          metadata,
+	}
+	if err = applyUploadOptions(input); err != nil {
+		return retry.Permanent(err)
+	}
+	output, err := s3Client.PutObject(context.TODO(), input)
+	if err != nil {
+		return fmt.Errorf("Couldn't upload file %v to %v:%v. Here's why: %w\n", filePath, bucketName, fileName, err)
+	}
+	if value := aws.ToString(output.ChecksumSHA256); value != "" && value != checksums.SHA256Base64() {
+		return fmt.Errorf("Checksum mismatch for file %v uploaded to %v. Local SHA-256 = %s , Remote SHA-256 = %s", filePath, bucketName, checksums.SHA256Base64(), value)
+	}
+	logger.Info("File uploaded to s3 bucket", "bucket", bucketName, "size", checksums.Size)
+	return nil
+}
+
+// maxUploadParts is the maximum number of parts of a s3 multipart upload
+const maxUploadParts = 10000
+
+// UploadLargeObject uploads the file in parts of UPLOAD_PART_SIZE_MB with UPLOAD_CONCURRENCY parts in parallel. The SHA-256
+// checksum of every part is computed while the part is uploaded and compared with the one verified by s3 , then the completed
+// object is verified against the composite checksum of the parts and the size of the local file
+// The multipart upload id is persisted under the location so that a retry only uploads the missing parts. The parts already
+// uploaded are read again to verify them against the local file
+func (a *awsClient) UploadLargeObject(fileName string, location string, bucketName string, checksums common.Checksums) error {
+	filePath := fmt.Sprintf("%s/%s", location, fileName)
+	parentBucketName, prefix := common.SplitBucketName(bucketName)
+	keyName := common.ObjectName(prefix, fileName)
+
+	options, err := common.UploadOptionsFromEnv()
+	if err != nil {
+		return retry.Permanent(err)
+	}
+	parts := options.Parts(checksums.Size)
+	if len(parts) > maxUploadParts {
+		return retry.Permanent(fmt.Errorf("Large file %v needs %d parts of %d bytes which is more than the %d parts allowed by s3. Increase the upload part size", filePath, len(parts), options.PartSize, maxUploadParts))
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return retry.Permanent(fmt.Errorf("Couldn't open large file %v to upload. Here's why: %w\n", filePath, err))
+	}
+	defer file.Close()
+
+	s3Client := a.getS3Client()
+	logger := logging.ForFile(fileName)
+	uploadID, uploaded, err := a.resumeMultipartUpload(s3Client, location, fileName, bucketName, keyName, checksums, options.PartSize)
+	if err != nil {
+		return err
+	}
+	if uploadID == "" {
+		logger.Info("Starting multipart upload of file", "path", filePath, "key", keyName, "parts", len(parts))
+		uploadID, err = a.createMultipartUpload(s3Client, location, fileName, parentBucketName, keyName, checksums)
+		if err != nil {
+			return err
+		}
+		state := &common.UploadState{Bucket: bucketName, Key: keyName, SHA256: checksums.SHA256Hex(), PartSize: options.PartSize, UploadID: uploadID}
+		if err = common.SaveUploadState(location, fileName, state); err != nil {
+			logger.Warn("Unable to persist upload state , the upload cannot be resumed", "error", err)
+		}
+	} else {
+		logger.Info("Resuming multipart upload of file", "path", filePath, "key", keyName, "parts", len(parts), "uploaded_parts", len(uploaded))
+	}
+
+	// base64 encoded SHA-256 checksums of the parts by part number
+	partChecksums := make(map[int32]string, len(parts))
+	var missing []common.Part
+	for _, part := range parts {
+		if uploadedPart, ok := uploaded[int32(part.Number)]; ok && aws.ToInt64(uploadedPart.Size) == part.Size {
+			checksum, err := partChecksum(file, part)
 			if err != nil {
-				return err
+				return retry.Permanent(fmt.Errorf("Couldn't read part %d of large file %v. Here's why: %w\n", part.Number, filePath, err))
 			}
-			continue
+			if checksum == aws.ToString(uploadedPart.ChecksumSHA256) {
+				partChecksums[int32(part.Number)] = checksum
+				continue
+			}
+			logger.Warn("Uploaded part does not match the local file , uploading it again", "part", part.Number)
+		}
+		missing = append(missing, part)
+	}
+	var mutex sync.Mutex
+	err = common.UploadParts(missing, options.Concurrency, func(part common.Part) error {
+		body := newPartReader(file, part)
+		output, err := s3Client.UploadPart(context.TODO(), &s3.UploadPartInput{
+			Bucket:            aws.String(parentBucketName),
+			Key:               aws.String(keyName),
+			UploadId:          aws.String(uploadID),
+			PartNumber:        aws.Int32(int32(part.Number)),
+			Body:              body,
+			ContentLength:     aws.Int64(part.Size),
+			ChecksumAlgorithm: types.ChecksumAlgorithmSha256,
+		})
+		if err != nil {
+			return fmt.Errorf("Couldn't upload part %d of large file %v to %v. Here's why: %w\n", part.Number, filePath, bucketName, err)
 		}
+		checksum, err := body.checksum()
+		if err != nil {
+			return fmt.Errorf("Couldn't compute the checksum of part %d of large file %v. Here's why: %w\n", part.Number, filePath, err)
+		}
+		if value := aws.ToString(output.ChecksumSHA256); value != checksum {
+			return fmt.Errorf("Checksum mismatch for part %d of large file %v uploaded to %v. Local SHA-256 = %s , Remote SHA-256 = %s", part.Number, filePath, bucketName, checksum, value)
+		}
+		mutex.Lock()
+		uploaded[int32(part.Number)] = types.Part{
+			PartNumber: aws.Int32(int32(part.Number)),
+			ETag:       output.ETag,
+			Size:       aws.Int64(part.Size),
+		}
+		partChecksums[int32(part.Number)] = checksum
+		mutex.Unlock()
+		logger.Debug("Part uploaded", "part", part.Number, "size", part.Size)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
 
-		file, err := os.Open(filePath)
+	// s3 rejects the completion if the local checksum of a part differs from the one it verified when the part was uploaded
+	completedParts := make([]types.CompletedPart, 0, len(parts))
+	orderedChecksums := make([]string, 0, len(parts))
+	for _, part := range parts {
+		uploadedPart := uploaded[int32(part.Number)]
+		completedParts = append(completedParts, types.CompletedPart{
+			PartNumber:     uploadedPart.PartNumber,
+			ETag:           uploadedPart.ETag,
+			ChecksumSHA256: aws.String(partChecksums[int32(part.Number)]),
+		})
+		orderedChecksums = append(orderedChecksums, partChecksums[int32(part.Number)])
+	}
+	expected, err := compositeChecksum(orderedChecksums)
+	if err != nil {
+		return retry.Permanent(err)
+	}
+	output, err := s3Client.CompleteMultipartUpload(context.TODO(), &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(parentBucketName),
+		Key:             aws.String(keyName),
+		UploadId:        aws.String(uploadID),
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: completedParts},
+	})
+	if err != nil {
+		return fmt.Errorf("Couldn't complete upload of large file %v to %v:%v. Here's why: %w\n", filePath, bucketName, fileName, err)
+	}
+	if err = common.RemoveUploadState(location, fileName); err != nil {
+		logger.Warn("Unable to remove upload state", "error", err)
+	}
+	if value := aws.ToString(output.ChecksumSHA256); value != expected {
+		return fmt.Errorf("Checksum mismatch for large file %v uploaded to %v. Local composite SHA-256 = %s , Remote composite SHA-256 = %s", filePath, bucketName, expected, value)
+	}
+
+	info, err := a.StatFile(fileName, bucketName)
+	if err != nil {
+		return err
+	}
+	if info.Size != checksums.Size {
+		return fmt.Errorf("Size mismatch for large file %v uploaded to %v. Local size = %d , Remote size = %d", filePath, bucketName, checksums.Size, info.Size)
+	}
+	logger.Info("File uploaded to s3 bucket", "bucket", bucketName, "size", checksums.Size)
+	return nil
+}
+
+// createMultipartUpload starts a multipart upload with the storage class and encryption options and returns its id
+func (a *awsClient) createMultipartUpload(s3Client *s3.Client, location string, fileName string, parentBucketName string, keyName string, checksums common.Checksums) (string, error) {
+	options := &s3.PutObjectInput{}
+	if err := applyUploadOptions(options); err != nil {
+		return "", retry.Permanent(err)
+	}
+	metadata, err := common.ObjectMetadata(location, fileName, checksums)
+	if err != nil {
+		return "", retry.Permanent(err)
+	}
+	output, err := s3Client.CreateMultipartUpload(context.TODO(), &s3.CreateMultipartUploadInput{
+		Bucket:               aws.String(parentBucketName),
+		Key:                  aws.String(keyName),
+		ChecksumAlgorithm:    types.ChecksumAlgorithmSha256,
+		Metadata:             metadata,
+		StorageClass:         options.StorageClass,
+		ServerSideEncryption: options.ServerSideEncryption,
+		SSEKMSKeyId:          options.SSEKMSKeyId,
+	})
+	if err != nil {
+		return "", fmt.Errorf("Couldn't start multipart upload of %v to %v. Here's why: %w\n", keyName, parentBucketName, err)
+	}
+	return aws.ToString(output.UploadId), nil
+}
+
+// resumeMultipartUpload returns the id and the already uploaded parts of the multipart upload persisted for the file
+// An empty id is returned when there is nothing to resume. The multipart upload of a previous version of the file is aborted
+func (a *awsClient) resumeMultipartUpload(s3Client *s3.Client, location string, fileName string, bucketName string, keyName string, checksums common.Checksums, partSize int64) (string, map[int32]types.Part, error) {
+	logger := logging.ForFile(fileName)
+	uploaded := make(map[int32]types.Part)
+	state, err := common.LoadUploadState(location, fileName)
+	if err != nil {
+		logger.Warn("Ignoring upload state", "error", err)
+		return "", uploaded, nil
+	}
+	if state == nil || state.UploadID == "" {
+		return "", uploaded, nil
+	}
+	if !state.Matches(bucketName, keyName, checksums.SHA256Hex(), partSize) {
+		parentBucketName, _ := common.SplitBucketName(state.Bucket)
+		_, err = s3Client.AbortMultipartUpload(context.TODO(), &s3.AbortMultipartUploadInput{
+			Bucket:   aws.String(parentBucketName),
+			Key:      aws.String(state.Key),
+			UploadId: aws.String(state.UploadID),
+		})
+		if err != nil {
+			logger.Warn("Unable to abort stale multipart upload", "upload_id", state.UploadID, "error", err)
+		} else {
+			logger.Info("Aborted stale multipart upload", "upload_id", state.UploadID)
+		}
+		return "", uploaded, nil
+	}
+
+	parentBucketName, _ := common.SplitBucketName(bucketName)
+	paginator := s3.NewListPartsPaginator(s3Client, &s3.ListPartsInput{
+		Bucket:   aws.String(parentBucketName),
+		Key:      aws.String(keyName),
+		UploadId: aws.String(state.UploadID),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(context.TODO())
+		if err != nil {
+			var apiError smithy.APIError
+			if errors.As(err, &apiError) && apiError.ErrorCode() == "NoSuchUpload" {
+				logger.Warn("Multipart upload not found , restarting the upload", "upload_id", state.UploadID)
+				return "", make(map[int32]types.Part), nil
+			}
+			return "", nil, fmt.Errorf("Couldn't list the uploaded parts of large file %v. Here's why: %w\n", fileName, err)
+		}
+		for _, part := range page.Parts {
+			uploaded[aws.ToInt32(part.PartNumber)] = part
+		}
+	}
+	return state.UploadID, uploaded, nil
+}
+
+// DownloadFile downloads the provided files from the s3 bucket to the location
+func (a *awsClient) DownloadFile(fileNames []string, bucketName string) error {
+
+	s3Client := a.getS3Client()
+	parentBucketName, prefix := common.SplitBucketName(bucketName)
+	downloader := manager.NewDownloader(s3Client)
+	location := os.Getenv("LOCATION")
+	for _, fileName := range fileNames {
+
+		filePath := fmt.Sprintf("%s/%s", location, fileName)
+		file, err := os.Create(filePath)
 		if err != nil {
-			return fmt.Errorf("Couldn't open file %v to upload. Here's why: %v\n", filePath, err)
+			return fmt.Errorf("Couldn't create file %v to download. Here's why: %v\n", filePath, err)
 		}
 
-		log.Printf("Starting upload of file %s", filePath)
-		log.Printf("KeyName := %s", generateKeyName(bucketName, fileName))
-		_, err = s3Client.PutObject(context.TODO(), &s3.PutObjectInput{
+		keyName := common.ObjectName(prefix, fileName)
+		logger := logging.ForFile(fileName)
+		logger.Info("Starting download of file", "key", keyName)
+		_, err = downloader.Download(context.TODO(), file, &s3.GetObjectInput{
 			Bucket: aws.String(parentBucketName),
-			Key:    aws.String(generateKeyName(bucketName, fileName)),
-			Body:   file,
+			Key:    aws.String(keyName),
 		})
+		file.Close()
 		if err != nil {
-			return fmt.Errorf("Couldn't upload file %v to %v:%v. Here's why: %v\n", filePath, bucketName, fileName, err)
+			return fmt.Errorf("Couldn't download file %v from %v. Here's why: %v\n", fileName, bucketName, err)
 		}
-		file.Close()
-		log.Printf("File %s uploaded to s3 bucket %s !!", fileName, bucketName)
+		logger.Info("File downloaded from s3 bucket", "bucket", bucketName, "path", filePath)
 	}
 	return nil
 }
 
-func (a *awsClient) UploadLargeObject(fileName string, location string, bucketName string, parentBucketName string) error {
-	filePath := fmt.Sprintf("%s/%s", location, fileName)
+// ListFiles returns the files present directly under the prefix of the provided s3 bucket
+func (a *awsClient) ListFiles(bucketName string) ([]common.FileInfo, error) {
 
-	//divide the file into 1GB parts
-	var partGiBs int64 = 1
 	s3Client := a.getS3Client()
-	uploader := manager.NewUploader(s3Client, func(u *manager.Uploader) {
-		u.PartSize = partGiBs * 1024 * 1024 * 1024
+	parentBucketName, prefix := common.SplitBucketName(bucketName)
+	if prefix != "" {
+		prefix = fmt.Sprintf("%s/", prefix)
+	}
+
+	var files []common.FileInfo
+	paginator := s3.NewListObjectsV2Paginator(s3Client, &s3.ListObjectsV2Input{
+		Bucket:    aws.String(parentBucketName),
+		Prefix:    aws.String(prefix),
+		Delimiter: aws.String("/"),
 	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(context.TODO())
+		if err != nil {
+			return nil, fmt.Errorf("Unable to list objects in s3 bucket %s \n Here's why: %v\n", bucketName, err)
+		}
+		for _, object := range page.Contents {
+			files = append(files, common.FileInfo{
+				Name:         path.Base(aws.ToString(object.Key)),
+				Size:         aws.ToInt64(object.Size),
+				LastModified: aws.ToTime(object.LastModified),
+			})
+		}
+	}
+	return files, nil
+}
 
-	file, err := os.Open(filePath)
-	if err != nil {
-		return fmt.Errorf("Couldn't open large file %v to upload. Here's why: %v\n", filePath, err)
+// DeleteFiles deletes the provided files from the s3 bucket
+func (a *awsClient) DeleteFiles(fileNames []string, bucketName string) error {
+
+	s3Client := a.getS3Client()
+	parentBucketName, prefix := common.SplitBucketName(bucketName)
+	for _, fileName := range fileNames {
+		_, err := s3Client.DeleteObject(context.TODO(), &s3.DeleteObjectInput{
+			Bucket: aws.String(parentBucketName),
+			Key:    aws.String(common.ObjectName(prefix, fileName)),
+		})
+		if err != nil {
+			return fmt.Errorf("Couldn't delete file %v from %v. Here's why: %v\n", fileName, bucketName, err)
+		}
+		logging.ForFile(fileName).Info("File deleted from s3 bucket", "bucket", bucketName)
 	}
+	return nil
+}
 
-	defer file.Close()
+// StatFile returns the info of the provided file present in the s3 bucket
+func (a *awsClient) StatFile(fileName string, bucketName string) (common.FileInfo, error) {
 
-	log.Printf("Starting upload of file %s", filePath)
-	log.Printf("KeyName := %s", generateKeyName(bucketName, fileName))
-	_, err = uploader.Upload(context.TODO(), &s3.PutObjectInput{
-		Bucket: aws.String(parentBucketName),
-		Key:    aws.String(generateKeyName(bucketName, fileName)),
-		Body:   file,
+	s3Client := a.getS3Client()
+	parentBucketName, prefix := common.SplitBucketName(bucketName)
+	output, err := s3Client.HeadObject(context.TODO(), &s3.HeadObjectInput{
+		Bucket:       aws.String(parentBucketName),
+		Key:          aws.String(common.ObjectName(prefix, fileName)),
+		ChecksumMode: types.ChecksumModeEnabled,
 	})
 	if err != nil {
-		return fmt.Errorf("Couldn't upload large file %v to %v:%v. Here's why: %v\n", filePath, bucketName, fileName, err)
+		return common.FileInfo{}, fmt.Errorf("Couldn't get info of file %v from %v. Here's why: %v\n", fileName, bucketName, err)
 	}
-	log.Printf("File (Large) %s uploaded to s3 bucket %s !!", fileName, bucketName)
-	return err
-}
-
-func generateKeyName(bucketName string, fileName string) string {
-	keyName := fileName
-	// if bucketName is demo/test/test2 , fileName is demo.backup
-	// keyName should be test/test2/demo.backup
-	if strings.Contains(bucketName, "/") {
-		index := strings.Index(bucketName, "/")
-		keyName = fmt.Sprintf("%s/%s", bucketName[index+1:], fileName)
+	file := common.FileInfo{
+		Name:         fileName,
+		Size:         aws.ToInt64(output.ContentLength),
+		LastModified: aws.ToTime(output.LastModified),
+		Metadata:     common.NormalizeMetadata(output.Metadata),
+	}
+	// the checksum of an object uploaded in parts is the checksum of the checksums of its parts followed by -<number of parts>
+	if value := aws.ToString(output.ChecksumSHA256); !strings.Contains(value, "-") {
+		file.SHA256 = value
+	}
+	if value := aws.ToString(output.ChecksumCRC32C); !strings.Contains(value, "-") {
+		file.CRC32C = value
 	}
-	return keyName
+	return file, nil
 }
 
 func (a *awsClient) getS3Client() *s3.Client {
@@ -158,3 +474,30 @@ func (a *awsClient) getS3Client() *s3.Client {
 	}
 	return client
 }
+
+// applyUploadOptions sets the storage class (STORAGE_CLASS) and the SSE-KMS key id (KMS_KEY_NAME) on the upload input
+// The bucket defaults are used when they are not set
+func applyUploadOptions(input *s3.PutObjectInput) error {
+	if value := strings.TrimSpace(os.Getenv("STORAGE_CLASS")); value != "" {
+		storageClass := types.StorageClass(value)
+		if !slices.Contains(storageClass.Values(), storageClass) {
+			return fmt.Errorf("Invalid s3 storage class %s. Allowed values are %v", value, storageClass.Values())
+		}
+		input.StorageClass = storageClass
+	}
+	if value := strings.TrimSpace(os.Getenv("KMS_KEY_NAME")); value != "" {
+		input.ServerSideEncryption = types.ServerSideEncryptionAwsKms
+		input.SSEKMSKeyId = aws.String(value)
+	}
+	return nil
+}
+
+// isRetryable classifies the errors returned by s3. Requests rejected by s3 are only retried for timeouts , throttling
+// and server errors while errors without a http response (ex: connection reset) are always retried
+func isRetryable(err error) bool {
+	var responseError interface{ HTTPStatusCode() int }
+	if errors.As(err, &responseError) {
+		return retry.IsRetryableStatusCode(responseError.HTTPStatusCode())
+	}
+	return true
+}
@@ -1,8 +1,14 @@
 package aws
 
 import (
+	"errors"
 	"fmt"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
 	"github.com/stretchr/testify/assert"
+	"net/http"
 	"os"
 	"testing"
 )
@@ -45,8 +51,8 @@ func TestCheckBucketAccessForAWS(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if err := client.CheckBucketAccess(tt.bucketName); (err != nil) != tt.wantErr {
-				t.Errorf("CheckBucketAccess() error = %v, wantErr %v", err, tt.wantErr)
+			if err := client.CheckAccess(tt.bucketName); (err != nil) != tt.wantErr {
+				t.Errorf("CheckAccess() error = %v, wantErr %v", err, tt.wantErr)
 			}
 		})
 	}
@@ -94,3 +100,31 @@ func TestUploadFileForAWS(t *testing.T) {
 		})
 	}
 }
+
+func TestApplyUploadOptionsForAWS(t *testing.T) {
+	t.Setenv("STORAGE_CLASS", "STANDARD_IA")
+	t.Setenv("KMS_KEY_NAME", "arn:aws:kms:us-east-1:111122223333:key/demo")
+	input := &s3.PutObjectInput{}
+	assert.NoError(t, applyUploadOptions(input))
+	assert.Equal(t, types.StorageClassStandardIa, input.StorageClass)
+	assert.Equal(t, types.ServerSideEncryptionAwsKms, input.ServerSideEncryption)
+	assert.Equal(t, "arn:aws:kms:us-east-1:111122223333:key/demo", *input.SSEKMSKeyId)
+
+	t.Setenv("STORAGE_CLASS", "COLD")
+	assert.Error(t, applyUploadOptions(&s3.PutObjectInput{}))
+}
+
+func TestIsRetryableForAWS(t *testing.T) {
+	responseError := func(statusCode int) error {
+		return fmt.Errorf("Couldn't upload file. Here's why: %w", &awshttp.ResponseError{
+			ResponseError: &smithyhttp.ResponseError{
+				Response: &smithyhttp.Response{Response: &http.Response{StatusCode: statusCode}},
+				Err:      errors.New("demo"),
+			},
+		})
+	}
+	assert.True(t, isRetryable(responseError(http.StatusServiceUnavailable)))
+	assert.True(t, isRetryable(responseError(http.StatusTooManyRequests)))
+	assert.False(t, isRetryable(responseError(http.StatusForbidden)))
+	assert.True(t, isRetryable(errors.New("connection reset by peer")))
+}
@@ -0,0 +1,77 @@
+package aws
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/common"
+	"hash"
+	"io"
+)
+
+// partReader reads a part of a file while computing its SHA-256 checksum so that the part is read once for both the upload and
+// its verification. The sdk rewinds the part before a retry or , over plain http , after reading it to compute the checksum
+// it sends along with the part. The checksum is computed again from the start on every rewind
+type partReader struct {
+	section *io.SectionReader
+	hash    hash.Hash
+	hashed  int64
+}
+
+func newPartReader(file io.ReaderAt, part common.Part) *partReader {
+	return &partReader{section: io.NewSectionReader(file, part.Offset, part.Size), hash: sha256.New()}
+}
+
+func (r *partReader) Read(p []byte) (int, error) {
+	position, err := r.section.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, err
+	}
+	n, err := r.section.Read(p)
+	// bytes read again after seeking back to the middle of the part are already hashed
+	if position == r.hashed {
+		r.hash.Write(p[:n])
+		r.hashed += int64(n)
+	}
+	return n, err
+}
+
+func (r *partReader) Seek(offset int64, whence int) (int64, error) {
+	position, err := r.section.Seek(offset, whence)
+	if err == nil && position == 0 {
+		r.hash.Reset()
+		r.hashed = 0
+	}
+	return position, err
+}
+
+// checksum returns the base64 encoded SHA-256 checksum of the part. An error is returned if the part was not read entirely
+func (r *partReader) checksum() (string, error) {
+	if r.hashed != r.section.Size() {
+		return "", fmt.Errorf("only %d of the %d bytes of the part were read", r.hashed, r.section.Size())
+	}
+	return base64.StdEncoding.EncodeToString(r.hash.Sum(nil)), nil
+}
+
+// partChecksum reads the part of the file and returns its base64 encoded SHA-256 checksum
+func partChecksum(file io.ReaderAt, part common.Part) (string, error) {
+	reader := newPartReader(file, part)
+	if _, err := io.Copy(io.Discard, reader); err != nil {
+		return "", err
+	}
+	return reader.checksum()
+}
+
+// compositeChecksum returns the checksum s3 reports for an object uploaded in parts from the base64 encoded SHA-256 checksums
+// of its parts in order. It is the SHA-256 checksum of the concatenated part checksums followed by the number of parts
+func compositeChecksum(partChecksums []string) (string, error) {
+	digest := sha256.New()
+	for _, partChecksum := range partChecksums {
+		decoded, err := base64.StdEncoding.DecodeString(partChecksum)
+		if err != nil {
+			return "", fmt.Errorf("invalid part checksum %s \n Here's why: %v", partChecksum, err)
+		}
+		digest.Write(decoded)
+	}
+	return fmt.Sprintf("%s-%d", base64.StdEncoding.EncodeToString(digest.Sum(nil)), len(partChecksums)), nil
+}
@@ -0,0 +1,59 @@
+package aws
+
+import (
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/common"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestPartReader(t *testing.T) {
+	t.Parallel()
+	file := strings.NewReader("demodata")
+	part := common.Part{Number: 2, Offset: 4, Size: 4}
+
+	reader := newPartReader(file, part)
+	_, err := reader.checksum()
+	assert.Error(t, err, "the part was not read")
+
+	// the sdk reads the part to compute its checksum and rewinds it before sending it
+	buffer := make([]byte, 2)
+	_, err = io.ReadFull(reader, buffer)
+	assert.NoError(t, err)
+	position, err := reader.Seek(0, io.SeekStart)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), position)
+	contents, err := io.ReadAll(reader)
+	assert.NoError(t, err)
+	assert.Equal(t, "data", string(contents))
+	checksum, err := reader.checksum()
+	assert.NoError(t, err)
+	assert.Equal(t, "Om6weQ85rIfJTzhWst0sXREOaBFgImGpqSPTuyOtyLc=", checksum)
+
+	// reading the end of the part again does not change the checksum
+	_, err = reader.Seek(2, io.SeekStart)
+	assert.NoError(t, err)
+	_, err = io.ReadAll(reader)
+	assert.NoError(t, err)
+	checksum, err = reader.checksum()
+	assert.NoError(t, err)
+	assert.Equal(t, "Om6weQ85rIfJTzhWst0sXREOaBFgImGpqSPTuyOtyLc=", checksum)
+
+	checksum, err = partChecksum(file, common.Part{Number: 1, Offset: 0, Size: 4})
+	assert.NoError(t, err)
+	assert.Equal(t, "KpdRbDVLaISM29j1SiJqClWyHtE44getbFy7nACqWuo=", checksum)
+}
+
+func TestCompositeChecksum(t *testing.T) {
+	t.Parallel()
+	checksum, err := compositeChecksum([]string{
+		"KpdRbDVLaISM29j1SiJqClWyHtE44getbFy7nACqWuo=",
+		"Om6weQ85rIfJTzhWst0sXREOaBFgImGpqSPTuyOtyLc=",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "8Q6DVTFSvRvCN18jlQ7eU/1JbESYXROxjO5EI+TmeHg=-2", checksum)
+
+	_, err = compositeChecksum([]string{"not base64"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,157 @@
+package azure
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"fmt"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/streaming"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blockblob"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/common"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/logging"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/retry"
+	"golang.org/x/net/context"
+	"io"
+	"os"
+)
+
+// maxBlocks is the maximum number of blocks of a block blob
+const maxBlocks = 50000
+
+// blockID returns the id of the block with the given number. All the ids of a blob must have the same length
+func blockID(number int) string {
+	return base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%08d", number)))
+}
+
+// uploadBlocks stages the file in blocks of UPLOAD_PART_SIZE_MB with UPLOAD_CONCURRENCY blocks in parallel and commits the block list
+// Azure keeps the uncommitted blocks for a week so a retry only stages the missing blocks. The upload state persisted under
+// the location guarantees that the staged blocks belong to the same file contents
+func (a *azureClient) uploadBlocks(fileName string, location string, containerName string, checksums common.Checksums, options common.UploadOptions) error {
+	filePath := fmt.Sprintf("%s/%s", location, fileName)
+	parentContainerName, prefix := common.SplitBucketName(containerName)
+	name := common.ObjectName(prefix, fileName)
+
+	parts := options.Parts(checksums.Size)
+	if len(parts) > maxBlocks {
+		return retry.Permanent(fmt.Errorf("File %v needs %d blocks of %d bytes which is more than the %d blocks allowed by azure. Increase the upload part size", filePath, len(parts), options.PartSize, maxBlocks))
+	}
+	uploadOptions := &azblob.UploadFileOptions{}
+	if err := applyUploadOptions(uploadOptions); err != nil {
+		return retry.Permanent(err)
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return retry.Permanent(fmt.Errorf("Couldn't open file %v to upload. Here's why: %w\n", filePath, err))
+	}
+	defer file.Close()
+
+	logger := logging.ForFile(fileName)
+	blobClient := a.client.ServiceClient().NewContainerClient(parentContainerName).NewBlockBlobClient(name)
+	staged, err := a.stagedBlocks(blobClient, location, fileName, containerName, name, checksums, options.PartSize)
+	if err != nil {
+		return err
+	}
+	if len(staged) == 0 {
+		logger.Info("Starting block upload of file", "path", filePath, "blob", name, "blocks", len(parts))
+		state := &common.UploadState{Bucket: containerName, Key: name, SHA256: checksums.SHA256Hex(), PartSize: options.PartSize}
+		if err = common.SaveUploadState(location, fileName, state); err != nil {
+			logger.Warn("Unable to persist upload state , the upload cannot be resumed", "error", err)
+		}
+	} else {
+		logger.Info("Resuming block upload of file", "path", filePath, "blob", name, "blocks", len(parts), "staged_blocks", len(staged))
+	}
+
+	var missing []common.Part
+	blockIDs := make([]string, 0, len(parts))
+	for _, part := range parts {
+		blockIDs = append(blockIDs, blockID(part.Number))
+		if size, ok := staged[blockID(part.Number)]; ok && size == part.Size {
+			continue
+		}
+		missing = append(missing, part)
+	}
+	// azure verifies every block against the MD5 checksum of the block read from the local file
+	err = common.UploadParts(missing, options.Concurrency, func(part common.Part) error {
+		checksum, err := blockMD5(file, part)
+		if err != nil {
+			return retry.Permanent(fmt.Errorf("Couldn't read block %d of file %v. Here's why: %w\n", part.Number, filePath, err))
+		}
+		_, err = blobClient.StageBlock(context.TODO(), blockID(part.Number), streaming.NopCloser(io.NewSectionReader(file, part.Offset, part.Size)), &blockblob.StageBlockOptions{
+			CPKScopeInfo:            uploadOptions.CPKScopeInfo,
+			TransactionalValidation: blob.TransferValidationTypeMD5(checksum),
+		})
+		if err != nil {
+			return fmt.Errorf("Couldn't upload block %d of file %v to %v Here's why: %w\n", part.Number, filePath, containerName, err)
+		}
+		logger.Debug("Block uploaded", "block", part.Number, "size", part.Size)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	metadata, err := blobMetadata(location, fileName, checksums)
+	if err != nil {
+		return retry.Permanent(err)
+	}
+	// the Content-MD5 property is stored as provided so that the downloads can be verified , azure does not validate it
+	_, err = blobClient.CommitBlockList(context.TODO(), blockIDs, &blockblob.CommitBlockListOptions{
+		HTTPHeaders:  &blob.HTTPHeaders{BlobContentMD5: checksums.MD5},
+		Metadata:     metadata,
+		Tier:         uploadOptions.AccessTier,
+		CPKScopeInfo: uploadOptions.CPKScopeInfo,
+	})
+	if err != nil {
+		return fmt.Errorf("Couldn't commit the blocks of file %v to %v Here's why: %w\n", filePath, containerName, err)
+	}
+	if err = common.RemoveUploadState(location, fileName); err != nil {
+		logger.Warn("Unable to remove upload state", "error", err)
+	}
+	if err = a.verifySize(fileName, containerName, checksums); err != nil {
+		return err
+	}
+	logger.Info("File uploaded to azure container", "container", containerName, "size", checksums.Size)
+	return nil
+}
+
+// blockMD5 reads the block of the file and returns its MD5 checksum. The block is read again when it is staged which
+// avoids holding it in memory
+func blockMD5(file io.ReaderAt, part common.Part) ([]byte, error) {
+	hash := md5.New()
+	if _, err := io.Copy(hash, io.NewSectionReader(file, part.Offset, part.Size)); err != nil {
+		return nil, err
+	}
+	return hash.Sum(nil), nil
+}
+
+// stagedBlocks returns the size of the uncommitted blocks of the blob by block id when the persisted upload state
+// belongs to the same file contents. Nothing is returned otherwise so that all the blocks are staged again
+func (a *azureClient) stagedBlocks(blobClient *blockblob.Client, location string, fileName string, containerName string, name string, checksums common.Checksums, partSize int64) (map[string]int64, error) {
+	logger := logging.ForFile(fileName)
+	staged := make(map[string]int64)
+	state, err := common.LoadUploadState(location, fileName)
+	if err != nil {
+		logger.Warn("Ignoring upload state", "error", err)
+		return staged, nil
+	}
+	if !state.Matches(containerName, name, checksums.SHA256Hex(), partSize) {
+		return staged, nil
+	}
+	response, err := blobClient.GetBlockList(context.TODO(), blockblob.BlockListTypeUncommitted, nil)
+	if err != nil {
+		// nothing was staged yet
+		if bloberror.HasCode(err, bloberror.BlobNotFound) {
+			return staged, nil
+		}
+		return nil, fmt.Errorf("Couldn't list the staged blocks of file %s Here's why: %w\n", fileName, err)
+	}
+	for _, block := range response.UncommittedBlocks {
+		if block.Name != nil && block.Size != nil {
+			staged[*block.Name] = *block.Size
+		}
+	}
+	return staged, nil
+}
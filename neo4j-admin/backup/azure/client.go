@@ -5,11 +5,15 @@ import (
 	"fmt"
 	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
-	"log"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/common"
+	"log/slog"
 	"os"
 	"regexp"
 )
 
+// azureClient implements common.StorageBackend
+var _ common.StorageBackend = &azureClient{}
+
 type azureClient struct {
 	client *azblob.Client
 }
@@ -20,7 +24,7 @@ func NewAzureClient(credentialPath string) (*azureClient, error) {
 
 	if credentialPath == "/credentials/" {
 		storageAccountName := os.Getenv("AZURE_STORAGE_ACCOUNT_NAME")
-		log.Printf("Azure storage account name %v", storageAccountName)
+		slog.Info("Using azure storage account", "storage_account", storageAccountName)
 		serviceURL := fmt.Sprintf("https://%s.blob.core.windows.net/", storageAccountName)
 		cred, err := azidentity.NewDefaultAzureCredential(nil)
 		if err != nil {
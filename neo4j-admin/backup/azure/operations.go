@@ -5,23 +5,25 @@ import (
 	"fmt"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blockblob"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/common"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/logging"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/retry"
 	"golang.org/x/net/context"
-	"log"
+	"log/slog"
 	"os"
 	"strings"
 )
 
-func (a *azureClient) CheckContainerAccess(containerName string) error {
+// CheckAccess checks if the given container name is accessible or not
+func (a *azureClient) CheckAccess(containerName string) error {
 
-	prefix := ""
-	parentContainerName := containerName
+	parentContainerName, prefix := common.SplitBucketName(containerName)
 	options := &azblob.ListBlobsFlatOptions{
 		Include: azblob.ListBlobsInclude{Snapshots: true, Versions: true},
 	}
-	if strings.Contains(containerName, "/") {
-		index := strings.Index(containerName, "/")
-		prefix = containerName[index+1:]
-		parentContainerName = containerName[:index]
+	if prefix != "" {
 		options.Prefix = &prefix
 	}
 	pager := a.client.NewListBlobsFlatPager(parentContainerName, options)
@@ -32,46 +34,250 @@ func (a *azureClient) CheckContainerAccess(containerName string) error {
 		if errors.As(err, &azureResponseError) && azureResponseError.ErrorCode == "ContainerNotFound" {
 			return errors.New(fmt.Sprintf("ContainerName = %s , ErrorMessage = %s", containerName, azureResponseError.RawResponse.Status))
 		}
-		log.Printf("error %v", err)
+		slog.Error("Unable to list blobs in azure container", "container", containerName, "error", err)
 		return err
 	}
-	log.Printf("Connectivity with Azure container '%s' established", containerName)
+	slog.Info("Connectivity with azure container established", "container", containerName)
 	return nil
 }
 
 // UploadFile uploads the file present at the provided location to the azure container
 func (a *azureClient) UploadFile(fileNames []string, containerName string) error {
 
-	prefix := ""
-	parentContainerName := containerName
+	//location := "/backups"
+	location := os.Getenv("LOCATION")
+	for _, fileName := range fileNames {
+
+		filePath := fmt.Sprintf("%s/%s", location, fileName)
+		checksums, err := common.FileChecksums(filePath)
+		if err != nil {
+			return err
+		}
+		options, err := common.UploadOptionsFromEnv()
+		if err != nil {
+			return err
+		}
+		//stage the file in blocks which can be resumed if file size is more than the upload part size
+		err = retry.Do("upload", isRetryable, func() error {
+			if options.IsMultipart(checksums.Size) || checksums.Size > maxPutBlobSize {
+				return a.uploadBlocks(fileName, location, containerName, checksums, options)
+			}
+			return a.uploadFile(fileName, location, containerName, checksums)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// maxPutBlobSize is the maximum size of a blob uploaded in a single request
+const maxPutBlobSize = 5000 * 1024 * 1024
+
+// uploadFile uploads a single file in a single request. Azure verifies the contents against the provided MD5 checksum
+// and rejects the upload on mismatch
+func (a *azureClient) uploadFile(fileName string, location string, containerName string, checksums common.Checksums) error {
+	filePath := fmt.Sprintf("%s/%s", location, fileName)
 	// if bucketName is demo/test/test2
 	// parentBucketName will be "demo"
-	if strings.Contains(containerName, "/") {
-		index := strings.Index(containerName, "/")
-		parentContainerName = containerName[:index]
-		prefix = containerName[index+1:]
+	parentContainerName, prefix := common.SplitBucketName(containerName)
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return retry.Permanent(fmt.Errorf("Couldn't open file %v to upload. Here's why: %w\n", filePath, err))
 	}
-	//location := "/backups"
+	defer file.Close()
+
+	logger := logging.ForFile(fileName)
+	logger.Info("Starting upload of file", "path", filePath)
+	metadata, err := blobMetadata(location, fileName, checksums)
+	if err != nil {
+		return retry.Permanent(err)
+	}
+	uploadOptions := &azblob.UploadFileOptions{}
+	if err = applyUploadOptions(uploadOptions); err != nil {
+		return retry.Permanent(err)
+	}
+	blobClient := a.client.ServiceClient().NewContainerClient(parentContainerName).NewBlockBlobClient(common.ObjectName(prefix, fileName))
+	_, err = blobClient.Upload(context.TODO(), file, &blockblob.UploadOptions{
+		HTTPHeaders:             &blob.HTTPHeaders{BlobContentMD5: checksums.MD5},
+		Metadata:                metadata,
+		Tier:                    uploadOptions.AccessTier,
+		CPKScopeInfo:            uploadOptions.CPKScopeInfo,
+		TransactionalValidation: blob.TransferValidationTypeMD5(checksums.MD5),
+	})
+	if err != nil {
+		return fmt.Errorf("Couldn't upload file %v to %v Here's why: %w\n", filePath, containerName, err)
+	}
+	if err = a.verifySize(fileName, containerName, checksums); err != nil {
+		return err
+	}
+	logger.Info("File uploaded to azure container", "container", containerName, "size", checksums.Size)
+	return nil
+}
+
+// DownloadFile downloads the provided blobs from the azure container to the location
+func (a *azureClient) DownloadFile(fileNames []string, containerName string) error {
+
+	parentContainerName, prefix := common.SplitBucketName(containerName)
 	location := os.Getenv("LOCATION")
 	for _, fileName := range fileNames {
 
+		name := common.ObjectName(prefix, fileName)
 		filePath := fmt.Sprintf("%s/%s", location, fileName)
-		file, err := os.Open(filePath)
+		file, err := os.Create(filePath)
 		if err != nil {
-			return fmt.Errorf("Couldn't open file %v to upload. Here's why: %v\n", filePath, err)
+			return fmt.Errorf("Couldn't create file %v to download. Here's why: %v\n", filePath, err)
 		}
 
-		name := fileName
-		if prefix != "" {
-			name = fmt.Sprintf("%s/%s", prefix, fileName)
+		logger := logging.ForFile(fileName)
+		logger.Info("Starting download of file", "blob", name)
+		_, err = a.client.DownloadFile(context.TODO(), parentContainerName, name, file, nil)
+		file.Close()
+		if err != nil {
+			return fmt.Errorf("Couldn't download file %v from %v Here's why: %v\n", fileName, containerName, err)
+		}
+		logger.Info("File downloaded from azure container", "container", containerName, "path", filePath)
+	}
+	return nil
+}
+
+// ListFiles returns the blobs present directly under the prefix of the provided azure container
+func (a *azureClient) ListFiles(containerName string) ([]common.FileInfo, error) {
+
+	parentContainerName, prefix := common.SplitBucketName(containerName)
+	options := &azblob.ListBlobsFlatOptions{}
+	if prefix != "" {
+		prefix = fmt.Sprintf("%s/", prefix)
+		options.Prefix = &prefix
+	}
+
+	var files []common.FileInfo
+	pager := a.client.NewListBlobsFlatPager(parentContainerName, options)
+	for pager.More() {
+		page, err := pager.NextPage(context.TODO())
+		if err != nil {
+			return nil, fmt.Errorf("Unable to list blobs in azure container %s \n Here's why: %v", containerName, err)
 		}
-		log.Printf("Starting upload of file %s", filePath)
-		_, err = a.client.UploadFile(context.TODO(), parentContainerName, name, file, nil)
+		for _, blob := range page.Segment.BlobItems {
+			name := strings.TrimPrefix(*blob.Name, prefix)
+			// skip blobs present in nested directories
+			if name == "" || strings.Contains(name, "/") {
+				continue
+			}
+			file := common.FileInfo{Name: name}
+			if blob.Properties != nil {
+				if blob.Properties.ContentLength != nil {
+					file.Size = *blob.Properties.ContentLength
+				}
+				if blob.Properties.LastModified != nil {
+					file.LastModified = *blob.Properties.LastModified
+				}
+			}
+			files = append(files, file)
+		}
+	}
+	return files, nil
+}
+
+// DeleteFiles deletes the provided blobs from the azure container
+func (a *azureClient) DeleteFiles(fileNames []string, containerName string) error {
+
+	parentContainerName, prefix := common.SplitBucketName(containerName)
+	for _, fileName := range fileNames {
+		_, err := a.client.DeleteBlob(context.TODO(), parentContainerName, common.ObjectName(prefix, fileName), nil)
 		if err != nil {
-			return fmt.Errorf("Couldn't upload file %v to %v Here's why: %v\n", filePath, containerName, err)
+			return fmt.Errorf("Couldn't delete file %s from azure container %s Here's why: %v\n", fileName, containerName, err)
 		}
-		log.Printf("File %s uploaded to azure container %s !!", fileName, containerName)
-		file.Close()
+		logging.ForFile(fileName).Info("File deleted from azure container", "container", containerName)
 	}
 	return nil
 }
+
+// StatFile returns the info of the provided blob present in the azure container
+func (a *azureClient) StatFile(fileName string, containerName string) (common.FileInfo, error) {
+
+	parentContainerName, prefix := common.SplitBucketName(containerName)
+	blobClient := a.client.ServiceClient().NewContainerClient(parentContainerName).NewBlobClient(common.ObjectName(prefix, fileName))
+	properties, err := blobClient.GetProperties(context.TODO(), nil)
+	if err != nil {
+		return common.FileInfo{}, fmt.Errorf("Couldn't get info of file %s from azure container %s Here's why: %v\n", fileName, containerName, err)
+	}
+	file := common.FileInfo{Name: fileName}
+	if properties.ContentLength != nil {
+		file.Size = *properties.ContentLength
+	}
+	if properties.LastModified != nil {
+		file.LastModified = *properties.LastModified
+	}
+	metadata := make(map[string]string, len(properties.Metadata))
+	for key, value := range properties.Metadata {
+		if value != nil {
+			metadata[key] = *value
+		}
+	}
+	file.Metadata = common.NormalizeMetadata(metadata)
+	return file, nil
+}
+
+// blobMetadata returns the metadata to attach to the uploaded blob in the format expected by the azure sdk
+func blobMetadata(location string, fileName string, checksums common.Checksums) (map[string]*string, error) {
+	metadata, err := common.ObjectMetadata(location, fileName, checksums)
+	if err != nil {
+		return nil, err
+	}
+	blobMetadata := make(map[string]*string, len(metadata))
+	for key, value := range metadata {
+		value := value
+		blobMetadata[key] = &value
+	}
+	return blobMetadata, nil
+}
+
+// verifySize compares the size of the uploaded blob with the size of the local file
+// The contents are verified by azure while they are uploaded since the Content-MD5 property of a blob is not validated
+func (a *azureClient) verifySize(fileName string, containerName string, checksums common.Checksums) error {
+
+	parentContainerName, prefix := common.SplitBucketName(containerName)
+	blobClient := a.client.ServiceClient().NewContainerClient(parentContainerName).NewBlobClient(common.ObjectName(prefix, fileName))
+	properties, err := blobClient.GetProperties(context.TODO(), nil)
+	if err != nil {
+		return fmt.Errorf("Couldn't get info of file %s from azure container %s Here's why: %w\n", fileName, containerName, err)
+	}
+	if properties.ContentLength == nil || *properties.ContentLength != checksums.Size {
+		return fmt.Errorf("Size mismatch for file %s uploaded to azure container %s. Local size = %d", fileName, containerName, checksums.Size)
+	}
+	return nil
+}
+
+// applyUploadOptions sets the access tier (STORAGE_CLASS) and the encryption scope (AZURE_ENCRYPTION_SCOPE) on the upload options
+// The container defaults are used when they are not set
+func applyUploadOptions(options *azblob.UploadFileOptions) error {
+	if value := strings.TrimSpace(os.Getenv("STORAGE_CLASS")); value != "" {
+		var accessTier *blob.AccessTier
+		for _, tier := range blob.PossibleAccessTierValues() {
+			if strings.EqualFold(string(tier), value) {
+				accessTier = &tier
+				break
+			}
+		}
+		if accessTier == nil {
+			return fmt.Errorf("Invalid azure access tier %s. Allowed values are %v", value, blob.PossibleAccessTierValues())
+		}
+		options.AccessTier = accessTier
+	}
+	if value := strings.TrimSpace(os.Getenv("AZURE_ENCRYPTION_SCOPE")); value != "" {
+		options.CPKScopeInfo = &blob.CPKScopeInfo{EncryptionScope: &value}
+	}
+	return nil
+}
+
+// isRetryable classifies the errors returned by azure. Requests rejected by azure are only retried for timeouts , throttling
+// and server errors while errors without a http response (ex: connection reset) are always retried
+func isRetryable(err error) bool {
+	var responseError *azcore.ResponseError
+	if errors.As(err, &responseError) {
+		return retry.IsRetryableStatusCode(responseError.StatusCode)
+	}
+	return true
+}
@@ -1,9 +1,17 @@
 package azure
 
 import (
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/common"
 	"github.com/stretchr/testify/assert"
+	"net/http"
 	"os"
+	"strings"
 	"testing"
 )
 
@@ -40,8 +48,8 @@ func TestCheckContainerAccessForAzure(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if err := client.CheckContainerAccess(tt.bucketName); (err != nil) != tt.wantErr {
-				t.Errorf("CheckContainerAccess() error = %v, wantErr %v", err, tt.wantErr)
+			if err := client.CheckAccess(tt.bucketName); (err != nil) != tt.wantErr {
+				t.Errorf("CheckAccess() error = %v, wantErr %v", err, tt.wantErr)
 			}
 		})
 	}
@@ -89,3 +97,31 @@ func TestUploadFileForAzure(t *testing.T) {
 		})
 	}
 }
+
+func TestApplyUploadOptionsForAzure(t *testing.T) {
+	t.Setenv("STORAGE_CLASS", "cool")
+	t.Setenv("AZURE_ENCRYPTION_SCOPE", "demo-scope")
+	options := &azblob.UploadFileOptions{}
+	assert.NoError(t, applyUploadOptions(options))
+	assert.Equal(t, blob.AccessTierCool, *options.AccessTier)
+	assert.Equal(t, "demo-scope", *options.CPKScopeInfo.EncryptionScope)
+
+	t.Setenv("STORAGE_CLASS", "GLACIER")
+	assert.Error(t, applyUploadOptions(&azblob.UploadFileOptions{}))
+}
+
+func TestIsRetryableForAzure(t *testing.T) {
+	responseError := func(statusCode int) error {
+		return fmt.Errorf("Couldn't upload file. Here's why: %w", &azcore.ResponseError{StatusCode: statusCode})
+	}
+	assert.True(t, isRetryable(responseError(http.StatusInternalServerError)))
+	assert.True(t, isRetryable(responseError(http.StatusTooManyRequests)))
+	assert.False(t, isRetryable(responseError(http.StatusNotFound)))
+	assert.True(t, isRetryable(errors.New("connection reset by peer")))
+}
+
+func TestBlockMD5ForAzure(t *testing.T) {
+	checksum, err := blockMD5(strings.NewReader("datademo"), common.Part{Number: 2, Offset: 4, Size: 4})
+	assert.NoError(t, err)
+	assert.Equal(t, "/gHOKn+6yPr67XyYKgTiKQ==", base64.StdEncoding.EncodeToString(checksum))
+}
@@ -0,0 +1,196 @@
+package catalog
+
+import (
+	"fmt"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/chain"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/common"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Report archive name suffixes
+// Ex: neo4j-2023-05-04T17-21-40.backup.report.tar.gz written by the consistency check of a backup run
+// Ex: neo4j-2023-05-04T17-21-27.backup.drill.report.tar.gz written by the drill of neo4j-2023-05-04T17-21-27.backup
+const (
+	reportSuffix      = ".report.tar.gz"
+	drillReportSuffix = ".drill.report.tar.gz"
+)
+
+// Entry is a backup file present in the bucket along with its chain and the inconsistency reports found for it
+type Entry struct {
+	FileName     string    `json:"fileName"`
+	Database     string    `json:"database"`
+	Timestamp    time.Time `json:"timestamp"`
+	Size         int64     `json:"size"`
+	LastModified time.Time `json:"lastModified"`
+	Type         string    `json:"type"`
+	Parent       string    `json:"parent,omitempty"`
+	Chain        string    `json:"chain"`
+	SHA256       string    `json:"sha256,omitempty"`
+	// Reports are the inconsistency report archives of the consistency checks and drills of the backup
+	Reports []string `json:"reports,omitempty"`
+	// Broken is set when a backup file the differential backup depends on is missing from the bucket
+	Broken bool `json:"broken,omitempty"`
+}
+
+// Good returns true if the backup can be restored and no inconsistencies were reported for it
+// A backup whose consistency was never checked is considered good
+func (e Entry) Good() bool {
+	return !e.Broken && len(e.Reports) == 0
+}
+
+// Status returns a one word description of the entry ex: ok , inconsistent , broken
+func (e Entry) Status() string {
+	switch {
+	case e.Broken:
+		return "broken"
+	case len(e.Reports) != 0:
+		return "inconsistent"
+	}
+	return "ok"
+}
+
+// Catalog is the backups present in a bucket grouped by database , oldest first
+type Catalog struct {
+	entries map[string][]Entry
+}
+
+// Build returns the catalog of the backups of the database ("*" for all the databases) among the files listed from the bucket
+// The chain of every backup file is read from its object metadata returned by statFile
+func Build(files []common.FileInfo, statFile func(string, string) (common.FileInfo, error), bucketName string, database string) (*Catalog, error) {
+	c := &Catalog{entries: make(map[string][]Entry)}
+	var reports []string
+	for _, file := range files {
+		if strings.HasSuffix(file.Name, reportSuffix) {
+			reports = append(reports, file.Name)
+			continue
+		}
+		backup, ok := common.ParseBackupFileName(file.Name)
+		if !ok || (database != "*" && database != "" && backup.Database != database) {
+			continue
+		}
+		info, err := statFile(file.Name, bucketName)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read the metadata of backup %s \n Here's why: %v", file.Name, err)
+		}
+		link := chain.LinkFromMetadata(file.Name, info.Metadata)
+		c.entries[backup.Database] = append(c.entries[backup.Database], Entry{
+			FileName:     file.Name,
+			Database:     backup.Database,
+			Timestamp:    backup.Timestamp,
+			Size:         file.Size,
+			LastModified: file.LastModified,
+			Type:         link.Type,
+			Parent:       link.Parent,
+			Chain:        link.Chain,
+			SHA256:       info.Metadata[common.ChecksumMetadataKey],
+		})
+	}
+	for _, entries := range c.entries {
+		sort.Slice(entries, func(i, j int) bool {
+			return entries[i].Timestamp.Before(entries[j].Timestamp)
+		})
+		markBrokenChains(entries)
+	}
+	for _, report := range reports {
+		c.attachReport(report)
+	}
+	return c, nil
+}
+
+// markBrokenChains marks the differential backups whose parent is missing , or itself broken , as broken
+// The entries are sorted oldest first so the parents are marked before their children
+func markBrokenChains(entries []Entry) {
+	broken := make(map[string]bool, len(entries))
+	for i := range entries {
+		broken[entries[i].FileName] = false
+	}
+	for i := range entries {
+		if entries[i].Parent == "" {
+			continue
+		}
+		parentBroken, present := broken[entries[i].Parent]
+		entries[i].Broken = !present || parentBroken
+		broken[entries[i].FileName] = entries[i].Broken
+	}
+}
+
+// attachReport attaches the report to the backup it was written for. The report of a drill is named after the drilled backup
+// while the report of a backup run is named after the time of the check which follows the backup of the database in the same run
+func (c *Catalog) attachReport(report string) {
+	if strings.HasSuffix(report, drillReportSuffix) {
+		fileName := strings.TrimSuffix(report, drillReportSuffix)
+		if backup, ok := common.ParseBackupFileName(fileName); ok {
+			for i, entry := range c.entries[backup.Database] {
+				if entry.FileName == fileName {
+					c.entries[backup.Database][i].Reports = append(entry.Reports, report)
+				}
+			}
+		}
+		return
+	}
+	check, ok := common.ParseBackupFileName(strings.TrimSuffix(report, reportSuffix))
+	if !ok {
+		return
+	}
+	entries := c.entries[check.Database]
+	for i := len(entries) - 1; i >= 0; i-- {
+		if !entries[i].Timestamp.After(check.Timestamp) {
+			entries[i].Reports = append(entries[i].Reports, report)
+			return
+		}
+	}
+}
+
+// Databases returns the names of the databases having backups in the catalog
+func (c *Catalog) Databases() []string {
+	databases := make([]string, 0, len(c.entries))
+	for database := range c.entries {
+		databases = append(databases, database)
+	}
+	sort.Strings(databases)
+	return databases
+}
+
+// Entries returns the backups of the database , oldest first
+func (c *Catalog) Entries(database string) []Entry {
+	return c.entries[database]
+}
+
+// Find returns the entry of the backup file
+func (c *Catalog) Find(fileName string) (Entry, bool) {
+	backup, ok := common.ParseBackupFileName(fileName)
+	if !ok {
+		return Entry{}, false
+	}
+	for _, entry := range c.entries[backup.Database] {
+		if entry.FileName == fileName {
+			return entry, true
+		}
+	}
+	return Entry{}, false
+}
+
+// Chain returns the entries of the chain ending with the backup file , starting with its full backup
+// Only the entries present in the catalog are returned when the chain is broken
+func (c *Catalog) Chain(fileName string) []Entry {
+	var entries []Entry
+	visited := make(map[string]bool)
+	for entry, ok := c.Find(fileName); ok && !visited[entry.FileName]; entry, ok = c.Find(entry.Parent) {
+		visited[entry.FileName] = true
+		entries = append([]Entry{entry}, entries...)
+	}
+	return entries
+}
+
+// Latest returns the latest good backup of the database
+func (c *Catalog) Latest(database string) (Entry, bool) {
+	entries := c.entries[database]
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].Good() {
+			return entries[i], true
+		}
+	}
+	return Entry{}, false
+}
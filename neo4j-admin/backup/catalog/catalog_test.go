@@ -0,0 +1,71 @@
+package catalog
+
+import (
+	"fmt"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/chain"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/common"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestBuild(t *testing.T) {
+	t.Parallel()
+
+	full := chain.Next("neo4j-2024-03-08T02-00-00.backup", "neo4j", "AUTO", nil)
+	diff := chain.Next("neo4j-2024-03-09T02-00-00.backup", "neo4j", "AUTO", &full)
+	inconsistent := chain.Next("neo4j-2024-03-10T02-00-00.backup", "neo4j", "AUTO", &diff)
+	orphan := chain.Next("movies-2024-03-10T02-00-00.backup", "movies", "AUTO", &chain.Link{FileName: "movies-2024-03-09T02-00-00.backup", Chain: "movies-2024-03-09T02-00-00.backup"})
+	metadata := map[string]map[string]string{"system-2024-03-10T02-00-00.backup": {common.ChecksumMetadataKey: "abc"}}
+	for _, link := range []chain.Link{full, diff, inconsistent, orphan} {
+		metadata[link.FileName] = link.Metadata()
+	}
+	files := []common.FileInfo{
+		{Name: "backup-manifest-2024-03-10T02-00-00.json"},
+		{Name: "movies-2024-03-10T02-00-00.backup", Size: 10},
+		{Name: "neo4j-2024-03-08T02-00-00.backup", Size: 100},
+		{Name: "neo4j-2024-03-09T02-00-00.backup", Size: 20},
+		{Name: "neo4j-2024-03-10T02-00-00.backup", Size: 30},
+		{Name: "neo4j-2024-03-10T02-00-05.backup.report.tar.gz"},
+		{Name: "system-2024-03-10T02-00-00.backup", Size: 5},
+		{Name: "system-2024-03-10T02-00-00.backup.drill.report.tar.gz"},
+	}
+	statFile := func(fileName string, bucketName string) (common.FileInfo, error) {
+		assert.Equal(t, "demo", bucketName)
+		return common.FileInfo{Name: fileName, Metadata: metadata[fileName]}, nil
+	}
+
+	c, err := Build(files, statFile, "demo", "*")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"movies", "neo4j", "system"}, c.Databases())
+
+	entries := c.Entries("neo4j")
+	if assert.Len(t, entries, 3) {
+		assert.Equal(t, chain.TypeFull, entries[0].Type)
+		assert.Equal(t, chain.TypeDiff, entries[1].Type)
+		assert.Equal(t, full.FileName, entries[1].Parent)
+		assert.Equal(t, []string{"neo4j-2024-03-10T02-00-05.backup.report.tar.gz"}, entries[2].Reports, "the report should be attached to the backup checked in the same run")
+		assert.Equal(t, "inconsistent", entries[2].Status())
+	}
+	latest, ok := c.Latest("neo4j")
+	assert.True(t, ok)
+	assert.Equal(t, diff.FileName, latest.FileName, "the latest good backup should skip the inconsistent one")
+	assert.Len(t, c.Chain(inconsistent.FileName), 3)
+
+	movies, _ := c.Find(orphan.FileName)
+	assert.True(t, movies.Broken)
+	_, ok = c.Latest("movies")
+	assert.False(t, ok, "a backup with a broken chain is not good")
+
+	system, _ := c.Find("system-2024-03-10T02-00-00.backup")
+	assert.Equal(t, "abc", system.SHA256)
+	assert.Equal(t, []string{"system-2024-03-10T02-00-00.backup.drill.report.tar.gz"}, system.Reports)
+
+	c, err = Build(files, statFile, "demo", "system")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"system"}, c.Databases())
+
+	_, err = Build(files, func(fileName string, bucketName string) (common.FileInfo, error) {
+		return common.FileInfo{}, fmt.Errorf("access denied")
+	}, "demo", "*")
+	assert.ErrorContains(t, err, "access denied")
+}
@@ -0,0 +1,135 @@
+package chain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Cache keeps the unencrypted backup files of the current chain of every database along with their links
+// neo4j-admin takes a differential backup on top of the chain present in the backup directory and the consistency check of
+// a differential backup needs its whole chain. The files are hard linked between the cache and the backup directory so both
+// must be on the same volume
+type Cache struct {
+	directory string
+}
+
+// NewCache returns the cache kept in the directory. The directory is created if missing
+func NewCache(directory string) (*Cache, error) {
+	if err := os.MkdirAll(directory, 0755); err != nil {
+		return nil, fmt.Errorf("Unable to create chain cache directory %s \n Here's why: %v", directory, err)
+	}
+	return &Cache{directory: directory}, nil
+}
+
+// Chain returns the links of the cached chain of the database starting with its full backup
+// nil is returned when nothing is cached or when a cached backup file is missing
+func (c *Cache) Chain(database string) ([]Link, error) {
+	data, err := os.ReadFile(c.linksPath(database))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read chain cache of database %s \n Here's why: %v", database, err)
+	}
+	var links []Link
+	if err = json.Unmarshal(data, &links); err != nil {
+		return nil, fmt.Errorf("Unable to parse chain cache of database %s \n Here's why: %v", database, err)
+	}
+	for _, link := range links {
+		if _, err = os.Stat(filepath.Join(c.directory, link.FileName)); err != nil {
+			slog.Warn("Ignoring chain cache , cached backup file is missing", "database", database, "file", link.FileName, "error", err)
+			return nil, nil
+		}
+	}
+	return links, nil
+}
+
+// Databases returns the databases whose chain is cached
+func (c *Cache) Databases() ([]string, error) {
+	entries, err := os.ReadDir(c.directory)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to list chain cache directory %s \n Here's why: %v", c.directory, err)
+	}
+	var databases []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".json") {
+			databases = append(databases, strings.TrimSuffix(entry.Name(), ".json"))
+		}
+	}
+	return databases, nil
+}
+
+// Store caches the chain of the database. The backup files which are not cached yet must be present at the location
+// The backup files of the previously cached chain which are not part of the chain are removed from the cache
+func (c *Cache) Store(location string, database string, links []Link) error {
+	previous, err := c.Chain(database)
+	if err != nil {
+		slog.Warn("Replacing unreadable chain cache", "database", database, "error", err)
+	}
+	kept := make(map[string]bool)
+	for _, link := range links {
+		kept[link.FileName] = true
+		cachedPath := filepath.Join(c.directory, link.FileName)
+		if _, err = os.Stat(cachedPath); err == nil {
+			continue
+		}
+		if err = os.Link(filepath.Join(location, link.FileName), cachedPath); err != nil {
+			return fmt.Errorf("Unable to cache backup file %s \n Here's why: %v", link.FileName, err)
+		}
+	}
+
+	data, err := json.Marshal(links)
+	if err != nil {
+		return fmt.Errorf("Unable to serialize chain cache of database %s \n Here's why: %v", database, err)
+	}
+	// the links are replaced atomically so that an interrupted run leaves either the previous or the new chain
+	linksPath := c.linksPath(database)
+	if err = os.WriteFile(linksPath+".tmp", data, 0644); err != nil {
+		return fmt.Errorf("Unable to write chain cache of database %s \n Here's why: %v", database, err)
+	}
+	if err = os.Rename(linksPath+".tmp", linksPath); err != nil {
+		return fmt.Errorf("Unable to write chain cache of database %s \n Here's why: %v", database, err)
+	}
+	for _, link := range previous {
+		if kept[link.FileName] {
+			continue
+		}
+		if err = os.Remove(filepath.Join(c.directory, link.FileName)); err != nil {
+			slog.Warn("Unable to remove cached backup file", "file", link.FileName, "error", err)
+		}
+	}
+	tip := links[len(links)-1]
+	slog.Info("Backup chain cached", "database", database, "tip", tip.FileName, "type", tip.Type, "length", len(links))
+	return nil
+}
+
+// Link hard links the backup files of the cached chain of the database into the location and returns the chain along with
+// the names of the linked files. Files already present at the location are not linked. nil is returned when nothing is cached
+func (c *Cache) Link(database string, location string) ([]Link, []string, error) {
+	links, err := c.Chain(database)
+	if err != nil || links == nil {
+		return nil, nil, err
+	}
+	var linked []string
+	for _, link := range links {
+		destination := filepath.Join(location, link.FileName)
+		if _, err = os.Stat(destination); err == nil {
+			continue
+		}
+		if err = os.Link(filepath.Join(c.directory, link.FileName), destination); err != nil {
+			return nil, linked, fmt.Errorf("Unable to link cached backup file %s to %s \n Here's why: %v", link.FileName, location, err)
+		}
+		linked = append(linked, link.FileName)
+	}
+	return links, linked, nil
+}
+
+func (c *Cache) linksPath(database string) string {
+	return filepath.Join(c.directory, database+".json")
+}
@@ -0,0 +1,64 @@
+package chain
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCache(t *testing.T) {
+	t.Parallel()
+
+	location := t.TempDir()
+	cache, err := NewCache(filepath.Join(location, ".chains"))
+	assert.NoError(t, err)
+	links, linked, err := cache.Link("neo4j", location)
+	assert.NoError(t, err)
+	assert.Nil(t, links)
+	assert.Empty(t, linked)
+
+	full := Next("neo4j-2024-03-08T02-00-00.backup", "neo4j", "AUTO", nil)
+	diff := Next("neo4j-2024-03-09T02-00-00.backup", "neo4j", "AUTO", &full)
+	for _, link := range []Link{full, diff} {
+		assert.NoError(t, os.WriteFile(filepath.Join(location, link.FileName), []byte(link.FileName), 0644))
+	}
+	assert.NoError(t, cache.Store(location, "neo4j", []Link{full}))
+	assert.NoError(t, cache.Store(location, "neo4j", []Link{full, diff}))
+	databases, err := cache.Databases()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"neo4j"}, databases)
+
+	// the backup files are deleted from the location after the upload and linked back before the next backup
+	assert.NoError(t, os.Remove(filepath.Join(location, full.FileName)))
+	assert.NoError(t, os.Remove(filepath.Join(location, diff.FileName)))
+	links, linked, err = cache.Link("neo4j", location)
+	assert.NoError(t, err)
+	assert.Equal(t, []Link{full, diff}, links)
+	assert.Equal(t, []string{full.FileName, diff.FileName}, linked)
+	contents, err := os.ReadFile(filepath.Join(location, diff.FileName))
+	assert.NoError(t, err)
+	assert.Equal(t, diff.FileName, string(contents))
+
+	// files already present at the location are not linked again
+	links, linked, err = cache.Link("neo4j", location)
+	assert.NoError(t, err)
+	assert.Len(t, links, 2)
+	assert.Empty(t, linked)
+
+	// a new full backup replaces the chain and its files
+	newFull := Next("neo4j-2024-03-10T02-00-00.backup", "neo4j", "FULL", &diff)
+	assert.NoError(t, os.WriteFile(filepath.Join(location, newFull.FileName), []byte(newFull.FileName), 0644))
+	assert.NoError(t, cache.Store(location, "neo4j", Extend([]Link{full, diff}, newFull)))
+	links, err = cache.Chain("neo4j")
+	assert.NoError(t, err)
+	assert.Equal(t, []Link{newFull}, links)
+	_, err = os.Stat(filepath.Join(location, ".chains", full.FileName))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	// a chain with a missing file is ignored
+	assert.NoError(t, os.Remove(filepath.Join(location, ".chains", newFull.FileName)))
+	links, err = cache.Chain("neo4j")
+	assert.NoError(t, err)
+	assert.Nil(t, links)
+}
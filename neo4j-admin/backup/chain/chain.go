@@ -0,0 +1,143 @@
+package chain
+
+import (
+	"fmt"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/common"
+	"os"
+	"strings"
+)
+
+// Object metadata keys recording the chain membership of a backup file
+// Underscores are used since azure only allows metadata names which are valid C# identifiers
+const (
+	TypeMetadataKey   = "backup_type"
+	ParentMetadataKey = "backup_parent"
+	ChainMetadataKey  = "backup_chain"
+)
+
+// Backup types recorded in the object metadata
+const (
+	TypeFull = "FULL"
+	TypeDiff = "DIFF"
+)
+
+// Link is the position of a backup file in its chain
+// A chain starts with a full backup followed by the differential backups taken on top of it
+type Link struct {
+	FileName string `json:"fileName"`
+	Database string `json:"database"`
+	Type     string `json:"type"`
+	// Parent is the file name of the backup the differential backup was taken on top of. Empty for a full backup
+	Parent string `json:"parent,omitempty"`
+	// Chain is the file name of the full backup the chain starts with
+	Chain string `json:"chain"`
+}
+
+// Metadata returns the object metadata recording the link
+func (l Link) Metadata() map[string]string {
+	metadata := map[string]string{
+		TypeMetadataKey:  l.Type,
+		ChainMetadataKey: l.Chain,
+	}
+	if l.Parent != "" {
+		metadata[ParentMetadataKey] = l.Parent
+	}
+	return metadata
+}
+
+// LinkFromMetadata returns the link recorded in the object metadata of the backup file
+// Backup files uploaded without (valid) chain metadata are considered full backups
+func LinkFromMetadata(fileName string, metadata map[string]string) Link {
+	var database string
+	if backup, ok := common.ParseBackupFileName(fileName); ok {
+		database = backup.Database
+	}
+	metadata = common.NormalizeMetadata(metadata)
+	link := Link{
+		FileName: fileName,
+		Database: database,
+		Type:     strings.ToUpper(metadata[TypeMetadataKey]),
+		Parent:   metadata[ParentMetadataKey],
+		Chain:    metadata[ChainMetadataKey],
+	}
+	if link.Type != TypeDiff || link.Parent == "" {
+		return Next(fileName, database, TypeFull, nil)
+	}
+	return link
+}
+
+// Next returns the link of the backup file taken with the given --type on top of the tip of the chain
+// neo4j-admin takes a differential backup for AUTO and DIFF only when the previous backup is present in the backup directory
+// so the backup is recorded as differential whenever a tip was provided. Recording a full backup as differential is safe
+// since it only keeps (or downloads) its parents in addition
+func Next(fileName string, database string, backupType string, tip *Link) Link {
+	if tip == nil || strings.ToUpper(backupType) == TypeFull {
+		return Link{FileName: fileName, Database: database, Type: TypeFull, Chain: fileName}
+	}
+	return Link{FileName: fileName, Database: database, Type: TypeDiff, Parent: tip.FileName, Chain: tip.Chain}
+}
+
+// Extend returns the chain continued with the link. A full backup starts a new chain
+func Extend(links []Link, link Link) []Link {
+	if link.Type == TypeFull {
+		return []Link{link}
+	}
+	return append(append([]Link{}, links...), link)
+}
+
+// Tip returns the latest link of the chain or nil for an empty chain
+func Tip(links []Link) *Link {
+	if len(links) == 0 {
+		return nil
+	}
+	return &links[len(links)-1]
+}
+
+// Mode decides how the previous backup is made available to neo4j-admin so that differential backups can be taken
+type Mode string
+
+const (
+	// ModeNone keeps no chain cache. Differential backups only happen when the backup files are kept at /backups
+	ModeNone Mode = "none"
+	// ModeLocal keeps the current chain of every database in the chain cache at /backups. Requires a persistent tempVolume
+	ModeLocal Mode = "local"
+	// ModeDownload additionally downloads the chain of the latest backup of the database from the bucket when it is not cached
+	ModeDownload Mode = "download"
+)
+
+// ModeFromEnv returns the chain cache mode configured via BACKUP_CHAIN_CACHE. Default is none
+func ModeFromEnv() (Mode, error) {
+	value := strings.ToLower(strings.TrimSpace(os.Getenv("BACKUP_CHAIN_CACHE")))
+	switch Mode(value) {
+	case "", ModeNone:
+		return ModeNone, nil
+	case ModeLocal, ModeDownload:
+		return Mode(value), nil
+	}
+	return "", fmt.Errorf("invalid BACKUP_CHAIN_CACHE %s. Value should be one of none , local or download", value)
+}
+
+// Resolve returns the links of the chain ending with the backup file , starting with its full backup
+// Backup files without chain metadata are considered full backups
+func Resolve(statFile func(string, string) (common.FileInfo, error), bucketName string, fileName string) ([]Link, error) {
+	var links []Link
+	tip := fileName
+	visited := make(map[string]bool)
+	for fileName != "" {
+		if visited[fileName] {
+			return nil, fmt.Errorf("backup chain of %s contains a cycle at %s", tip, fileName)
+		}
+		visited[fileName] = true
+		info, err := statFile(fileName, bucketName)
+		if err != nil {
+			if len(links) != 0 {
+				return nil, fmt.Errorf("backup chain of %s is broken , parent %s is missing. Here's why: %w", tip, fileName, err)
+			}
+			return nil, err
+		}
+		link := LinkFromMetadata(fileName, info.Metadata)
+		links = append([]Link{link}, links...)
+		fileName = link.Parent
+	}
+	return links, nil
+}
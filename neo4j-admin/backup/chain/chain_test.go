@@ -0,0 +1,92 @@
+package chain
+
+import (
+	"fmt"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/common"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestLinkMetadata(t *testing.T) {
+	t.Parallel()
+
+	full := Next("neo4j-2024-03-08T02-00-00.backup", "neo4j", "AUTO", nil)
+	assert.Equal(t, Link{FileName: "neo4j-2024-03-08T02-00-00.backup", Database: "neo4j", Type: TypeFull, Chain: "neo4j-2024-03-08T02-00-00.backup"}, full)
+	diff := Next("neo4j-2024-03-09T02-00-00.backup", "neo4j", "DIFF", &full)
+	assert.Equal(t, Link{FileName: "neo4j-2024-03-09T02-00-00.backup", Database: "neo4j", Type: TypeDiff, Parent: full.FileName, Chain: full.FileName}, diff)
+	assert.Equal(t, TypeFull, Next("neo4j-2024-03-10T02-00-00.backup", "neo4j", "FULL", &diff).Type)
+
+	assert.Equal(t, diff, LinkFromMetadata(diff.FileName, diff.Metadata()))
+	assert.Equal(t, full, LinkFromMetadata(full.FileName, full.Metadata()))
+	assert.Equal(t, diff, LinkFromMetadata(diff.FileName, map[string]string{"Backup_Type": "diff", "Backup_Parent": full.FileName, "Backup_Chain": full.FileName}))
+	assert.Equal(t, full, LinkFromMetadata(full.FileName, nil), "backup files without metadata are full backups")
+	assert.Equal(t, TypeFull, LinkFromMetadata(diff.FileName, map[string]string{TypeMetadataKey: TypeDiff}).Type, "a differential backup needs a parent")
+
+	chain := Extend(nil, full)
+	chain = Extend(chain, diff)
+	assert.Equal(t, []Link{full, diff}, chain)
+	assert.Equal(t, &diff, Tip(chain))
+	assert.Nil(t, Tip(nil))
+	assert.Equal(t, []Link{full}, Extend(chain, full))
+}
+
+func TestModeFromEnv(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    Mode
+		wantErr bool
+	}{
+		{value: "", want: ModeNone},
+		{value: "none", want: ModeNone},
+		{value: "Local", want: ModeLocal},
+		{value: "download", want: ModeDownload},
+		{value: "remote", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			t.Setenv("BACKUP_CHAIN_CACHE", tt.value)
+			mode, err := ModeFromEnv()
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, mode)
+		})
+	}
+}
+
+func TestResolve(t *testing.T) {
+	t.Parallel()
+
+	full := Next("neo4j-2024-03-08T02-00-00.backup", "neo4j", "AUTO", nil)
+	diff1 := Next("neo4j-2024-03-09T02-00-00.backup", "neo4j", "AUTO", &full)
+	diff2 := Next("neo4j-2024-03-10T02-00-00.backup", "neo4j", "AUTO", &diff1)
+	orphan := Link{FileName: "neo4j-2024-03-11T02-00-00.backup", Database: "neo4j", Type: TypeDiff, Parent: "neo4j-2024-03-01T02-00-00.backup"}
+	cycle := Link{FileName: "neo4j-2024-03-12T02-00-00.backup", Database: "neo4j", Type: TypeDiff, Parent: "neo4j-2024-03-12T02-00-00.backup"}
+	files := map[string]common.FileInfo{"legacy-2024-03-08T02-00-00.backup": {Name: "legacy-2024-03-08T02-00-00.backup"}}
+	for _, link := range []Link{full, diff1, diff2, orphan, cycle} {
+		files[link.FileName] = common.FileInfo{Name: link.FileName, Metadata: link.Metadata()}
+	}
+	statFile := func(fileName string, bucketName string) (common.FileInfo, error) {
+		if file, present := files[fileName]; present {
+			return file, nil
+		}
+		return common.FileInfo{}, fmt.Errorf("file %s does not exist in bucket %s", fileName, bucketName)
+	}
+
+	links, err := Resolve(statFile, "demo", diff2.FileName)
+	assert.NoError(t, err)
+	assert.Equal(t, []Link{full, diff1, diff2}, links)
+
+	links, err = Resolve(statFile, "demo", "legacy-2024-03-08T02-00-00.backup")
+	assert.NoError(t, err)
+	assert.Equal(t, []Link{{FileName: "legacy-2024-03-08T02-00-00.backup", Database: "legacy", Type: TypeFull, Chain: "legacy-2024-03-08T02-00-00.backup"}}, links)
+
+	_, err = Resolve(statFile, "demo", orphan.FileName)
+	assert.ErrorContains(t, err, "is broken")
+	_, err = Resolve(statFile, "demo", cycle.FileName)
+	assert.ErrorContains(t, err, "cycle")
+	_, err = Resolve(statFile, "demo", "missing-2024-03-08T02-00-00.backup")
+	assert.Error(t, err)
+}
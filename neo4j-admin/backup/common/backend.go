@@ -0,0 +1,19 @@
+package common
+
+// StorageBackend is implemented by every storage the backup files can be uploaded to
+// bucketName is of the form <bucket>/<prefix> where the prefix is optional
+// File names are relative to the location (LOCATION env) locally and to the prefix in the bucket
+type StorageBackend interface {
+	// CheckAccess checks if the given bucket is accessible or not
+	CheckAccess(bucketName string) error
+	// UploadFile uploads the files present at the location to the bucket
+	UploadFile(fileNames []string, bucketName string) error
+	// DownloadFile downloads the files from the bucket to the location
+	DownloadFile(fileNames []string, bucketName string) error
+	// ListFiles returns the files present directly under the prefix of the bucket
+	ListFiles(bucketName string) ([]FileInfo, error)
+	// DeleteFiles deletes the files from the bucket
+	DeleteFiles(fileNames []string, bucketName string) error
+	// StatFile returns the info of a file present in the bucket
+	StatFile(fileName string, bucketName string) (FileInfo, error)
+}
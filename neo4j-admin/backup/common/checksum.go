@@ -0,0 +1,115 @@
+package common
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"sync"
+)
+
+// ChecksumMetadataKey is the object metadata key under which the SHA-256 checksum of an uploaded file is recorded
+const ChecksumMetadataKey = "sha256"
+
+// Checksums contains the size and checksums of a file computed locally before it is uploaded
+type Checksums struct {
+	Size   int64
+	SHA256 []byte
+	MD5    []byte
+	CRC32C uint32
+}
+
+// ComputeChecksums reads the file present at the provided path once and computes all its checksums
+func ComputeChecksums(filePath string) (Checksums, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return Checksums{}, fmt.Errorf("Couldn't open file %v to compute checksums. Here's why: %v\n", filePath, err)
+	}
+	defer file.Close()
+	return ComputeReaderChecksums(file)
+}
+
+// fileChecksums are the checksums of a version of a file , identified by the file , its size and its modification time
+type fileChecksums struct {
+	info      os.FileInfo
+	checksums Checksums
+}
+
+var (
+	checksumsMutex sync.Mutex
+	checksumsCache = make(map[string]fileChecksums)
+)
+
+// FileChecksums returns the checksums of the file present at the provided path. They are computed once per version of the
+// file so that the backup manifest and the storage backends uploading the file share a single read of it. A file which
+// was replaced or modified since is read again
+func FileChecksums(filePath string) (Checksums, error) {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return Checksums{}, fmt.Errorf("Couldn't open file %v to compute checksums. Here's why: %v\n", filePath, err)
+	}
+	checksumsMutex.Lock()
+	cached, present := checksumsCache[filePath]
+	checksumsMutex.Unlock()
+	if present && sameVersion(cached.info, info) {
+		return cached.checksums, nil
+	}
+	checksums, err := ComputeChecksums(filePath)
+	if err != nil {
+		return Checksums{}, err
+	}
+	// the file is recorded as it was before the read so that a write racing with the read is not hidden
+	checksumsMutex.Lock()
+	checksumsCache[filePath] = fileChecksums{info: info, checksums: checksums}
+	checksumsMutex.Unlock()
+	return checksums, nil
+}
+
+// sameVersion returns true if both infos describe the same file with the same contents
+func sameVersion(cached os.FileInfo, current os.FileInfo) bool {
+	return os.SameFile(cached, current) && cached.Size() == current.Size() && cached.ModTime().Equal(current.ModTime())
+}
+
+// ComputeReaderChecksums computes the checksums of the contents of the provided reader
+func ComputeReaderChecksums(reader io.Reader) (Checksums, error) {
+	sha256Hash := sha256.New()
+	md5Hash := md5.New()
+	crc32cHash := crc32.New(crc32.MakeTable(crc32.Castagnoli))
+	size, err := io.Copy(io.MultiWriter(sha256Hash, md5Hash, crc32cHash), reader)
+	if err != nil {
+		return Checksums{}, fmt.Errorf("Couldn't read contents to compute checksums. Here's why: %v\n", err)
+	}
+	return Checksums{
+		Size:   size,
+		SHA256: sha256Hash.Sum(nil),
+		MD5:    md5Hash.Sum(nil),
+		CRC32C: crc32cHash.Sum32(),
+	}, nil
+}
+
+// SHA256Hex returns the hex encoded SHA-256 checksum
+func (c Checksums) SHA256Hex() string {
+	return hex.EncodeToString(c.SHA256)
+}
+
+// SHA256Base64 returns the base64 encoded SHA-256 checksum
+func (c Checksums) SHA256Base64() string {
+	return base64.StdEncoding.EncodeToString(c.SHA256)
+}
+
+// CRC32CBase64 returns the base64 encoded big endian CRC32C checksum as reported by gcs and s3
+func (c Checksums) CRC32CBase64() string {
+	crc32c := make([]byte, 4)
+	binary.BigEndian.PutUint32(crc32c, c.CRC32C)
+	return base64.StdEncoding.EncodeToString(crc32c)
+}
+
+// MD5Base64 returns the base64 encoded MD5 checksum
+func (c Checksums) MD5Base64() string {
+	return base64.StdEncoding.EncodeToString(c.MD5)
+}
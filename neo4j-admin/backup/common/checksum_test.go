@@ -0,0 +1,51 @@
+package common
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestComputeReaderChecksums(t *testing.T) {
+	t.Parallel()
+
+	checksums, err := ComputeReaderChecksums(strings.NewReader("demo"))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), checksums.Size)
+	assert.Equal(t, "2a97516c354b68848cdbd8f54a226a0a55b21ed138e207ad6c5cbb9c00aa5aea", checksums.SHA256Hex())
+	assert.Equal(t, "KpdRbDVLaISM29j1SiJqClWyHtE44getbFy7nACqWuo=", checksums.SHA256Base64())
+	assert.Equal(t, "/gHOKn+6yPr67XyYKgTiKQ==", checksums.MD5Base64())
+	assert.Equal(t, uint32(0x2d0dcca2), checksums.CRC32C)
+	assert.Equal(t, "LQ3Mog==", checksums.CRC32CBase64())
+}
+
+func TestFileChecksums(t *testing.T) {
+	t.Parallel()
+	filePath := filepath.Join(t.TempDir(), "neo4j-2024-03-10T10-00-00.backup")
+	assert.NoError(t, os.WriteFile(filePath, []byte("demo"), 0644))
+	info, err := os.Stat(filePath)
+	assert.NoError(t, err)
+
+	checksums, err := FileChecksums(filePath)
+	assert.NoError(t, err)
+	assert.Equal(t, "2a97516c354b68848cdbd8f54a226a0a55b21ed138e207ad6c5cbb9c00aa5aea", checksums.SHA256Hex())
+
+	// the same version of the file is not read again
+	assert.NoError(t, os.WriteFile(filePath, []byte("data"), 0644))
+	assert.NoError(t, os.Chtimes(filePath, info.ModTime(), info.ModTime()))
+	checksums, err = FileChecksums(filePath)
+	assert.NoError(t, err)
+	assert.Equal(t, "2a97516c354b68848cdbd8f54a226a0a55b21ed138e207ad6c5cbb9c00aa5aea", checksums.SHA256Hex())
+
+	// a modified file is read again
+	assert.NoError(t, os.Chtimes(filePath, info.ModTime(), info.ModTime().Add(time.Second)))
+	checksums, err = FileChecksums(filePath)
+	assert.NoError(t, err)
+	assert.Equal(t, "3a6eb0790f39ac87c94f3856b2dd2c5d110e6811602261a9a923d3bb23adc8b7", checksums.SHA256Hex())
+
+	_, err = FileChecksums(filepath.Join(t.TempDir(), "missing.backup"))
+	assert.Error(t, err)
+}
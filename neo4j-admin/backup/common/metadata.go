@@ -0,0 +1,83 @@
+package common
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// MetadataPath returns the path of the metadata file of the file present in the directory
+// The metadata recorded in it is attached to the object when the file is uploaded
+func MetadataPath(directory string, fileName string) string {
+	return filepath.Join(directory, fmt.Sprintf(".%s.metadata.json", fileName))
+}
+
+// LoadFileMetadata returns the metadata recorded for the file present in the directory or nil if there is none
+func LoadFileMetadata(directory string, fileName string) (map[string]string, error) {
+	data, err := os.ReadFile(MetadataPath(directory, fileName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't read metadata of file %s. Here's why: %v", fileName, err)
+	}
+	var metadata map[string]string
+	if err = json.Unmarshal(data, &metadata); err != nil {
+		return nil, fmt.Errorf("Couldn't parse metadata of file %s. Here's why: %v", fileName, err)
+	}
+	return metadata, nil
+}
+
+// SaveFileMetadata records the metadata of the file present in the directory. The metadata file is replaced atomically
+func SaveFileMetadata(directory string, fileName string, metadata map[string]string) error {
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return fmt.Errorf("Couldn't serialize metadata of file %s. Here's why: %v", fileName, err)
+	}
+	path := MetadataPath(directory, fileName)
+	if err = os.WriteFile(path+".tmp", data, 0644); err != nil {
+		return fmt.Errorf("Couldn't write metadata of file %s. Here's why: %v", fileName, err)
+	}
+	if err = os.Rename(path+".tmp", path); err != nil {
+		return fmt.Errorf("Couldn't write metadata of file %s. Here's why: %v", fileName, err)
+	}
+	return nil
+}
+
+// RemoveFileMetadata deletes the metadata recorded for the file present in the directory
+func RemoveFileMetadata(directory string, fileName string) error {
+	if err := os.Remove(MetadataPath(directory, fileName)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("Couldn't remove metadata of file %s. Here's why: %v", fileName, err)
+	}
+	return nil
+}
+
+// ObjectMetadata returns the metadata to attach to the uploaded object of the file present at the location
+// The SHA-256 checksum is always included. Keys are lower case since the providers do not preserve their case
+func ObjectMetadata(location string, fileName string, checksums Checksums) (map[string]string, error) {
+	recorded, err := LoadFileMetadata(location, fileName)
+	if err != nil {
+		return nil, err
+	}
+	metadata := map[string]string{ChecksumMetadataKey: checksums.SHA256Hex()}
+	for key, value := range recorded {
+		metadata[strings.ToLower(key)] = value
+	}
+	return metadata, nil
+}
+
+// NormalizeMetadata returns the object metadata with lower case keys
+func NormalizeMetadata(metadata map[string]string) map[string]string {
+	if len(metadata) == 0 {
+		return nil
+	}
+	normalized := make(map[string]string, len(metadata))
+	for key, value := range metadata {
+		normalized[strings.ToLower(key)] = value
+	}
+	return normalized
+}
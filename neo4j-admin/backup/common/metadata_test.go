@@ -0,0 +1,39 @@
+package common
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"testing"
+)
+
+func TestFileMetadata(t *testing.T) {
+	t.Parallel()
+
+	location := t.TempDir()
+	fileName := "neo4j-2024-03-10T10-00-00.backup"
+	checksums := Checksums{SHA256: []byte{0xab, 0xcd}}
+	metadata, err := LoadFileMetadata(location, fileName)
+	assert.NoError(t, err)
+	assert.Nil(t, metadata)
+	metadata, err = ObjectMetadata(location, fileName, checksums)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{ChecksumMetadataKey: "abcd"}, metadata)
+
+	assert.NoError(t, SaveFileMetadata(location, fileName, map[string]string{"Backup_Type": "FULL"}))
+	metadata, err = ObjectMetadata(location, fileName, checksums)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{ChecksumMetadataKey: "abcd", "backup_type": "FULL"}, metadata)
+
+	assert.NoError(t, RemoveFileMetadata(location, fileName))
+	assert.NoError(t, RemoveFileMetadata(location, fileName))
+	metadata, err = LoadFileMetadata(location, fileName)
+	assert.NoError(t, err)
+	assert.Nil(t, metadata)
+
+	assert.NoError(t, os.WriteFile(MetadataPath(location, fileName), []byte("{"), 0644))
+	_, err = LoadFileMetadata(location, fileName)
+	assert.Error(t, err)
+
+	assert.Nil(t, NormalizeMetadata(nil))
+	assert.Equal(t, map[string]string{"backup_chain": "a"}, NormalizeMetadata(map[string]string{"Backup_Chain": "a"}))
+}
@@ -0,0 +1,51 @@
+package common
+
+import (
+	"regexp"
+	"time"
+)
+
+// backupFileRegex matches the artifact names generated by neo4j-admin
+// Ex: neo4j-2023-05-04T17-21-27.backup
+var backupFileRegex = regexp.MustCompile(`^(.+)-(\d{4}-\d{2}-\d{2}T\d{2}-\d{2}-\d{2})\.backup$`)
+
+// BackupTimeLayout is the timestamp layout used by neo4j-admin in the backup artifact names
+const BackupTimeLayout = "2006-01-02T15-04-05"
+
+// FileInfo describes a file present in a bucket, container or local directory
+// Name is relative to the bucket prefix (or directory) it was listed from
+type FileInfo struct {
+	Name         string
+	Size         int64
+	LastModified time.Time
+	// Metadata is the object metadata with lower case keys. It is only returned by StatFile
+	Metadata map[string]string
+	// SHA256 and CRC32C are the base64 encoded checksums of the whole contents computed or validated by the storage backend
+	// They are only returned by StatFile and are empty when the backend does not provide them ex: s3 objects uploaded in parts
+	SHA256 string
+	CRC32C string
+}
+
+// Backup is a backup file whose name has been parsed into the database name and the creation time
+type Backup struct {
+	FileName  string
+	Database  string
+	Timestamp time.Time
+}
+
+// ParseBackupFileName parses the given file name and returns false if it is not a neo4j backup file
+func ParseBackupFileName(fileName string) (Backup, bool) {
+	matches := backupFileRegex.FindStringSubmatch(fileName)
+	if len(matches) != 3 {
+		return Backup{}, false
+	}
+	timestamp, err := time.Parse(BackupTimeLayout, matches[2])
+	if err != nil {
+		return Backup{}, false
+	}
+	return Backup{
+		FileName:  fileName,
+		Database:  matches[1],
+		Timestamp: timestamp,
+	}, true
+}
@@ -0,0 +1,47 @@
+package common
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParseBackupFileName(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		fileName string
+		valid    bool
+		database string
+	}{
+		{
+			name:     "simple database name",
+			fileName: "neo4j-2023-05-04T17-21-27.backup",
+			valid:    true,
+			database: "neo4j",
+		},
+		{
+			name:     "database name with hyphen",
+			fileName: "my-db-2023-05-04T17-21-27.backup",
+			valid:    true,
+			database: "my-db",
+		},
+		{
+			name:     "consistency check report",
+			fileName: "neo4j-2023-05-04T17-21-27.backup.report.tar.gz",
+			valid:    false,
+		},
+		{
+			name:     "random file",
+			fileName: "test.yaml",
+			valid:    false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backup, ok := ParseBackupFileName(tt.fileName)
+			assert.Equal(t, tt.valid, ok)
+			assert.Equal(t, tt.database, backup.Database)
+		})
+	}
+}
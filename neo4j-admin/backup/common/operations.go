@@ -2,19 +2,23 @@ package common
 
 import (
 	"fmt"
-	"os"
+	"strings"
 )
 
-// IsFileBigger returns true if file size is bigger than 1GB
-func IsFileBigger(filePath string) (bool, error) {
-	fileInfo, err := os.Stat(filePath)
-	if err != nil {
-		return false, fmt.Errorf("Couldn't get info of file %v to upload. Here's why: %v\n", filePath, err)
+// SplitBucketName splits the bucket name into the parent bucket name and the prefix
+// Ex: demo/test/test2 returns demo and test/test2
+func SplitBucketName(bucketName string) (string, string) {
+	if index := strings.Index(bucketName, "/"); index != -1 {
+		return bucketName[:index], strings.TrimSuffix(bucketName[index+1:], "/")
 	}
-	fileSize := float64(fileInfo.Size())
-	gb := float64(1024 * 1024 * 1024)
-	if fileSize/gb >= 1.0 {
-		return true, nil
+	return bucketName, ""
+}
+
+// ObjectName returns the name of the object for the given file under the given prefix
+// Ex: prefix test/test2 , fileName demo.backup returns test/test2/demo.backup
+func ObjectName(prefix string, fileName string) string {
+	if prefix == "" {
+		return fileName
 	}
-	return false, nil
+	return fmt.Sprintf("%s/%s", prefix, fileName)
 }
@@ -0,0 +1,30 @@
+package common
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestSplitBucketName(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		bucketName string
+		parent     string
+		prefix     string
+		objectName string
+	}{
+		{bucketName: "demo", parent: "demo", prefix: "", objectName: "demo.backup"},
+		{bucketName: "demo/test", parent: "demo", prefix: "test", objectName: "test/demo.backup"},
+		{bucketName: "demo/test/test2", parent: "demo", prefix: "test/test2", objectName: "test/test2/demo.backup"},
+		{bucketName: "demo/test/", parent: "demo", prefix: "test", objectName: "test/demo.backup"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.bucketName, func(t *testing.T) {
+			parent, prefix := SplitBucketName(tt.bucketName)
+			assert.Equal(t, tt.parent, parent)
+			assert.Equal(t, tt.prefix, prefix)
+			assert.Equal(t, tt.objectName, ObjectName(prefix, "demo.backup"))
+		})
+	}
+}
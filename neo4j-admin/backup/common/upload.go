@@ -0,0 +1,172 @@
+package common
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// DefaultUploadPartSizeMB matches the part size used before it was configurable
+	DefaultUploadPartSizeMB = 1024
+	// DefaultUploadConcurrency is the number of parts uploaded in parallel
+	DefaultUploadConcurrency = 4
+	// minUploadPartSizeMB is the smallest part size accepted by s3 (except for the last part)
+	minUploadPartSizeMB = 5
+)
+
+// UploadOptions configures the multipart (s3) , block (azure) and resumable (gcs) uploads of the large files
+// Files bigger than PartSize are uploaded in parts and the upload is resumed on retry
+type UploadOptions struct {
+	PartSize    int64
+	Concurrency int
+}
+
+// Part is a part of a file uploaded on its own. Number starts at 1
+type Part struct {
+	Number int
+	Offset int64
+	Size   int64
+}
+
+// UploadOptionsFromEnv returns the upload options configured via UPLOAD_PART_SIZE_MB and UPLOAD_CONCURRENCY
+func UploadOptionsFromEnv() (UploadOptions, error) {
+	options := UploadOptions{
+		PartSize:    DefaultUploadPartSizeMB * 1024 * 1024,
+		Concurrency: DefaultUploadConcurrency,
+	}
+	if value := strings.TrimSpace(os.Getenv("UPLOAD_PART_SIZE_MB")); value != "" {
+		partSize, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || partSize < minUploadPartSizeMB {
+			return UploadOptions{}, fmt.Errorf("invalid UPLOAD_PART_SIZE_MB %s. Value should be a number greater than or equal to %d", value, minUploadPartSizeMB)
+		}
+		options.PartSize = partSize * 1024 * 1024
+	}
+	if value := strings.TrimSpace(os.Getenv("UPLOAD_CONCURRENCY")); value != "" {
+		concurrency, err := strconv.Atoi(value)
+		if err != nil || concurrency < 1 {
+			return UploadOptions{}, fmt.Errorf("invalid UPLOAD_CONCURRENCY %s. Value should be a positive number", value)
+		}
+		options.Concurrency = concurrency
+	}
+	return options, nil
+}
+
+// IsMultipart returns true if a file of the given size is uploaded in parts
+func (o UploadOptions) IsMultipart(size int64) bool {
+	return size > o.PartSize
+}
+
+// Parts splits a file of the given size into parts of PartSize. The last part holds the remainder
+func (o UploadOptions) Parts(size int64) []Part {
+	var parts []Part
+	for offset := int64(0); offset < size; offset += o.PartSize {
+		parts = append(parts, Part{
+			Number: len(parts) + 1,
+			Offset: offset,
+			Size:   min(o.PartSize, size-offset),
+		})
+	}
+	return parts
+}
+
+// UploadParts calls upload for every part with at most concurrency parts in flight
+// It waits for the parts in flight and returns the first error once a part fails
+func UploadParts(parts []Part, concurrency int, upload func(part Part) error) error {
+	semaphore := make(chan struct{}, max(concurrency, 1))
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	var firstErr error
+	for _, part := range parts {
+		mutex.Lock()
+		failed := firstErr != nil
+		mutex.Unlock()
+		if failed {
+			break
+		}
+		semaphore <- struct{}{}
+		wg.Add(1)
+		go func(part Part) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+			if err := upload(part); err != nil {
+				mutex.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				mutex.Unlock()
+			}
+		}(part)
+	}
+	wg.Wait()
+	return firstErr
+}
+
+// UploadState is persisted next to the file while it is uploaded in parts so that an interrupted upload
+// (failed attempt or restarted pod with a persistent /backups volume) is resumed instead of restarted
+// Only one of UploadID (s3 multipart upload) or SessionURI (gcs resumable session) is set. Azure stages the blocks
+// with ids derived from the part number and lists the staged blocks when resuming
+type UploadState struct {
+	Bucket     string `json:"bucket"`
+	Key        string `json:"key"`
+	SHA256     string `json:"sha256"`
+	PartSize   int64  `json:"partSize"`
+	UploadID   string `json:"uploadId,omitempty"`
+	SessionURI string `json:"sessionUri,omitempty"`
+}
+
+// Matches returns true if the state belongs to an upload of the same file contents to the same object with the same part size
+func (s *UploadState) Matches(bucket string, key string, sha256 string, partSize int64) bool {
+	return s != nil && s.Bucket == bucket && s.Key == key && s.SHA256 == sha256 && s.PartSize == partSize
+}
+
+// uploadStatePath returns the path of the state file of the given file ex: /backups/.neo4j-2024-03-10T10-00-00.backup.upload.json
+func uploadStatePath(location string, fileName string) string {
+	return filepath.Join(location, fmt.Sprintf(".%s.upload.json", fileName))
+}
+
+// LoadUploadState returns the persisted upload state of the file or nil if there is none
+func LoadUploadState(location string, fileName string) (*UploadState, error) {
+	data, err := os.ReadFile(uploadStatePath(location, fileName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't read upload state of file %s. Here's why: %v", fileName, err)
+	}
+	var state UploadState
+	if err = json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("Couldn't parse upload state of file %s. Here's why: %v", fileName, err)
+	}
+	return &state, nil
+}
+
+// SaveUploadState persists the upload state of the file. The file is replaced atomically
+func SaveUploadState(location string, fileName string, state *UploadState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("Couldn't serialize upload state of file %s. Here's why: %v", fileName, err)
+	}
+	path := uploadStatePath(location, fileName)
+	if err = os.WriteFile(path+".tmp", data, 0600); err != nil {
+		return fmt.Errorf("Couldn't write upload state of file %s. Here's why: %v", fileName, err)
+	}
+	if err = os.Rename(path+".tmp", path); err != nil {
+		return fmt.Errorf("Couldn't write upload state of file %s. Here's why: %v", fileName, err)
+	}
+	return nil
+}
+
+// RemoveUploadState deletes the persisted upload state of the file once the upload completed
+func RemoveUploadState(location string, fileName string) error {
+	if err := os.Remove(uploadStatePath(location, fileName)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("Couldn't remove upload state of file %s. Here's why: %v", fileName, err)
+	}
+	return nil
+}
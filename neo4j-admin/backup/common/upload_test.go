@@ -0,0 +1,118 @@
+package common
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"sync/atomic"
+	"testing"
+)
+
+func TestUploadOptionsFromEnv(t *testing.T) {
+	t.Setenv("UPLOAD_PART_SIZE_MB", "")
+	t.Setenv("UPLOAD_CONCURRENCY", "")
+	options, err := UploadOptionsFromEnv()
+	assert.NoError(t, err)
+	assert.Equal(t, UploadOptions{PartSize: 1024 * 1024 * 1024, Concurrency: 4}, options)
+
+	t.Setenv("UPLOAD_PART_SIZE_MB", "64")
+	t.Setenv("UPLOAD_CONCURRENCY", "8")
+	options, err = UploadOptionsFromEnv()
+	assert.NoError(t, err)
+	assert.Equal(t, UploadOptions{PartSize: 64 * 1024 * 1024, Concurrency: 8}, options)
+
+	for key, value := range map[string]string{
+		"UPLOAD_PART_SIZE_MB": "4",
+		"UPLOAD_CONCURRENCY":  "0",
+	} {
+		t.Run(key, func(t *testing.T) {
+			t.Setenv(key, value)
+			_, err := UploadOptionsFromEnv()
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestParts(t *testing.T) {
+	t.Parallel()
+
+	options := UploadOptions{PartSize: 10}
+	tests := []struct {
+		name      string
+		size      int64
+		multipart bool
+		parts     []Part
+	}{
+		{name: "empty", size: 0, multipart: false, parts: nil},
+		{name: "single part", size: 10, multipart: false, parts: []Part{{Number: 1, Offset: 0, Size: 10}}},
+		{name: "remainder", size: 25, multipart: true, parts: []Part{{Number: 1, Offset: 0, Size: 10}, {Number: 2, Offset: 10, Size: 10}, {Number: 3, Offset: 20, Size: 5}}},
+		{name: "exact", size: 20, multipart: true, parts: []Part{{Number: 1, Offset: 0, Size: 10}, {Number: 2, Offset: 10, Size: 10}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.multipart, options.IsMultipart(tt.size))
+			assert.Equal(t, tt.parts, options.Parts(tt.size))
+		})
+	}
+}
+
+func TestUploadParts(t *testing.T) {
+	t.Parallel()
+
+	parts := UploadOptions{PartSize: 1}.Parts(20)
+	var inFlight, maxInFlight, uploaded atomic.Int32
+	err := UploadParts(parts, 3, func(part Part) error {
+		current := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			previous := maxInFlight.Load()
+			if current <= previous || maxInFlight.CompareAndSwap(previous, current) {
+				break
+			}
+		}
+		uploaded.Add(1)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, int32(20), uploaded.Load())
+	assert.LessOrEqual(t, maxInFlight.Load(), int32(3))
+
+	failure := errors.New("connection reset by peer")
+	err = UploadParts(parts, 1, func(part Part) error {
+		if part.Number == 5 {
+			return failure
+		}
+		return nil
+	})
+	assert.ErrorIs(t, err, failure)
+}
+
+func TestUploadState(t *testing.T) {
+	t.Parallel()
+
+	location := t.TempDir()
+	fileName := "neo4j-2024-03-10T10-00-00.backup"
+	state, err := LoadUploadState(location, fileName)
+	assert.NoError(t, err)
+	assert.Nil(t, state)
+	assert.False(t, state.Matches("demo", fileName, "abc", 10))
+
+	saved := &UploadState{Bucket: "demo", Key: fileName, SHA256: "abc", PartSize: 10, UploadID: "upload-1"}
+	assert.NoError(t, SaveUploadState(location, fileName, saved))
+	state, err = LoadUploadState(location, fileName)
+	assert.NoError(t, err)
+	assert.Equal(t, saved, state)
+	assert.True(t, state.Matches("demo", fileName, "abc", 10))
+	assert.False(t, state.Matches("demo", fileName, "def", 10), "a different file contents should not be resumed")
+	assert.False(t, state.Matches("demo", fileName, "abc", 20), "a different part size should not be resumed")
+
+	assert.NoError(t, RemoveUploadState(location, fileName))
+	assert.NoError(t, RemoveUploadState(location, fileName))
+	state, err = LoadUploadState(location, fileName)
+	assert.NoError(t, err)
+	assert.Nil(t, state)
+
+	assert.NoError(t, os.WriteFile(uploadStatePath(location, fileName), []byte("{"), 0600))
+	_, err = LoadUploadState(location, fileName)
+	assert.Error(t, err)
+}
@@ -0,0 +1,179 @@
+package consistency
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Types of inconsistencies the findings are grouped by
+const (
+	TypeNodes          = "nodes"
+	TypeRelationships  = "relationships"
+	TypeIndexes        = "indexes"
+	TypeCounts         = "counts"
+	TypePropertyOwners = "property_owners"
+	TypeOther          = "other"
+)
+
+// Types lists all the types of inconsistencies
+var Types = []string{TypeNodes, TypeRelationships, TypeIndexes, TypeCounts, TypePropertyOwners, TypeOther}
+
+// Findings summarizes the inconsistencies listed in the reports of a consistency check
+type Findings struct {
+	Errors   int `json:"errors"`
+	Warnings int `json:"warnings"`
+	// ByType is the number of inconsistencies , errors and warnings , by type ex: nodes , relationships , indexes
+	ByType map[string]int `json:"byType,omitempty"`
+}
+
+// Total returns the number of inconsistencies , errors and warnings
+func (f Findings) Total() int {
+	return f.Errors + f.Warnings
+}
+
+// Add counts an inconsistency of the type
+func (f *Findings) Add(inconsistencyType string, warning bool, count int) {
+	if warning {
+		f.Warnings += count
+	} else {
+		f.Errors += count
+	}
+	if f.ByType == nil {
+		f.ByType = make(map[string]int)
+	}
+	f.ByType[inconsistencyType] += count
+}
+
+// Merge adds the findings of another report
+func (f *Findings) Merge(other Findings) {
+	f.Errors += other.Errors
+	f.Warnings += other.Warnings
+	for inconsistencyType, count := range other.ByType {
+		if f.ByType == nil {
+			f.ByType = make(map[string]int)
+		}
+		f.ByType[inconsistencyType] += count
+	}
+}
+
+// String returns a one line description of the findings ex: 3 errors , 1 warnings (nodes=2 , relationships=2)
+func (f Findings) String() string {
+	types := make([]string, 0, len(f.ByType))
+	for inconsistencyType := range f.ByType {
+		types = append(types, inconsistencyType)
+	}
+	sort.Strings(types)
+	counts := make([]string, 0, len(types))
+	for _, inconsistencyType := range types {
+		counts = append(counts, fmt.Sprintf("%s=%d", inconsistencyType, f.ByType[inconsistencyType]))
+	}
+	return fmt.Sprintf("%d errors , %d warnings (%s)", f.Errors, f.Warnings, strings.Join(counts, " , "))
+}
+
+// ParseReport returns the findings listed in an inconsistency report written by neo4j-admin database check
+// Every inconsistency starts with an ERROR: or WARNING: line followed by the indented records it was found on
+// Ex:
+//
+//	ERROR: The referenced relationship record is not in use.
+//	    Node[12,used=true,rel=14,prop=-1,labels=Inline(0x1000000001:[1]),light,secondaryUnitId=-1]
+//	    Inconsistent with: Relationship[14,used=false,source=0,target=0,type=0]
+func ParseReport(reader io.Reader) (Findings, error) {
+	var findings Findings
+	var message string
+	var warning, pending bool
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(trimmed, "ERROR:"), strings.HasPrefix(trimmed, "WARNING:"):
+			if pending {
+				findings.Add(TypeOf(message, ""), warning, 1)
+			}
+			warning = strings.HasPrefix(trimmed, "WARNING:")
+			message, pending = trimmed, true
+		case pending && trimmed != "" && line != trimmed:
+			// the first indented line is the record the inconsistency was found on
+			findings.Add(TypeOf(message, trimmed), warning, 1)
+			pending = false
+		}
+	}
+	if pending {
+		findings.Add(TypeOf(message, ""), warning, 1)
+	}
+	if err := scanner.Err(); err != nil {
+		return Findings{}, fmt.Errorf("unable to read consistency check report \n Here's why: %v", err)
+	}
+	return findings, nil
+}
+
+// ReadReports returns the findings of the inconsistency reports (*.report files) present in the report directory
+func ReadReports(directory string) (Findings, error) {
+	reports, err := filepath.Glob(filepath.Join(directory, "*.report"))
+	if err != nil {
+		return Findings{}, err
+	}
+	if len(reports) == 0 {
+		return Findings{}, fmt.Errorf("no inconsistency report found in %s", directory)
+	}
+	var findings Findings
+	for _, report := range reports {
+		file, err := os.Open(report)
+		if err != nil {
+			return Findings{}, err
+		}
+		reportFindings, err := ParseReport(file)
+		file.Close()
+		if err != nil {
+			return Findings{}, err
+		}
+		findings.Merge(reportFindings)
+	}
+	return findings, nil
+}
+
+// TypeOf returns the type of the inconsistency from its message and the record it was found on ex: Node[12,used=true ...]
+// The record may also be a record type name of the summary statistics printed by neo4j-admin ex: RELATIONSHIP_GROUP
+func TypeOf(message string, record string) string {
+	message, record = strings.ToLower(message), strings.ToLower(record)
+	switch {
+	case strings.Contains(message, "owner") || strings.Contains(record, "owner"):
+		return TypePropertyOwners
+	case strings.Contains(message, "count") || strings.HasPrefix(record, "count"):
+		return TypeCounts
+	case strings.Contains(record, "index") || strings.Contains(record, "schema") || strings.Contains(record, "label_scan") ||
+		strings.Contains(record, "token_scan") || strings.Contains(message, "index"):
+		return TypeIndexes
+	case strings.HasPrefix(record, "relationship"):
+		return TypeRelationships
+	case strings.HasPrefix(record, "node"):
+		return TypeNodes
+	}
+	return TypeOther
+}
+
+// FailureThresholdFromEnv returns the number of inconsistency errors , configured via CONSISTENCY_CHECK_FAILURE_THRESHOLD ,
+// from which a consistency check fails the job. 0 , the default , never fails the job
+func FailureThresholdFromEnv() (int, error) {
+	value := strings.TrimSpace(os.Getenv("CONSISTENCY_CHECK_FAILURE_THRESHOLD"))
+	if value == "" {
+		return 0, nil
+	}
+	threshold, err := strconv.Atoi(value)
+	if err != nil || threshold < 0 {
+		return 0, fmt.Errorf("invalid CONSISTENCY_CHECK_FAILURE_THRESHOLD %s. Value should be a positive number of errors or 0", value)
+	}
+	return threshold, nil
+}
+
+// Exceeds returns true if the findings reach the failure threshold. A threshold of 0 is never reached
+func (f Findings) Exceeds(threshold int) bool {
+	return threshold > 0 && f.Errors >= threshold
+}
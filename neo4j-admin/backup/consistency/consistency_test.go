@@ -0,0 +1,80 @@
+package consistency
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseReport(t *testing.T) {
+	t.Parallel()
+
+	file, err := os.Open(filepath.Join("testdata", "inconsistencies.report"))
+	assert.NoError(t, err)
+	defer file.Close()
+
+	findings, err := ParseReport(file)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, findings.Errors)
+	assert.Equal(t, 1, findings.Warnings)
+	assert.Equal(t, map[string]int{
+		TypeNodes:          1,
+		TypeRelationships:  1,
+		TypePropertyOwners: 1,
+		TypeIndexes:        1,
+		TypeCounts:         1,
+	}, findings.ByType)
+	assert.Equal(t, "4 errors , 1 warnings (counts=1 , indexes=1 , nodes=1 , property_owners=1 , relationships=1)", findings.String())
+
+	findings, err = ParseReport(strings.NewReader("ERROR: Something unexpected happened."))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int{TypeOther: 1}, findings.ByType, "an inconsistency without record should still be counted")
+}
+
+func TestReadReports(t *testing.T) {
+	t.Parallel()
+
+	directory := t.TempDir()
+	_, err := ReadReports(directory)
+	assert.ErrorContains(t, err, "no inconsistency report found")
+
+	data, err := os.ReadFile(filepath.Join("testdata", "inconsistencies.report"))
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(filepath.Join(directory, "inconsistencies-2024-03-10.02.00.12.report"), data, 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(directory, "inconsistencies-2024-03-10.02.00.13.report"), []byte("ERROR: The node is not in use.\n    Node[3,used=false]\n"), 0644))
+
+	findings, err := ReadReports(directory)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, findings.Errors)
+	assert.Equal(t, 2, findings.ByType[TypeNodes])
+}
+
+func TestFailureThreshold(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    int
+		wantErr bool
+	}{
+		{value: "", want: 0},
+		{value: "1", want: 1},
+		{value: "-1", wantErr: true},
+		{value: "many", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Setenv("CONSISTENCY_CHECK_FAILURE_THRESHOLD", tt.value)
+		threshold, err := FailureThresholdFromEnv()
+		if tt.wantErr {
+			assert.Error(t, err, tt.value)
+			continue
+		}
+		assert.NoError(t, err)
+		assert.Equal(t, tt.want, threshold)
+	}
+
+	findings := Findings{Errors: 2, Warnings: 5}
+	assert.False(t, findings.Exceeds(0), "a threshold of 0 should never fail the job")
+	assert.True(t, findings.Exceeds(2))
+	assert.False(t, findings.Exceeds(3), "warnings should not count towards the threshold")
+}
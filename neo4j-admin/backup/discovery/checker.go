@@ -0,0 +1,85 @@
+package discovery
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/probe"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+// httpChecker dials the backup port and uses the /db/<database>/cluster/status endpoint of the neo4j http port
+type httpChecker struct {
+	client   *http.Client
+	timeout  time.Duration
+	username string
+	password string
+}
+
+// clusterStatus is the response of the /db/<database>/cluster/status endpoint
+type clusterStatus struct {
+	MemberID                 string `json:"memberId"`
+	Leader                   string `json:"leader"`
+	ParticipatingInRaftGroup bool   `json:"participatingInRaftGroup"`
+	Healthy                  bool   `json:"healthy"`
+}
+
+// NewHTTPChecker returns a Checker querying the database status endpoints of the neo4j servers
+// The endpoints require authentication unless dbms.security.cluster_status_auth_enabled is false. The credentials are
+// read from NEO4J_USERNAME and NEO4J_PASSWORD when present
+func NewHTTPChecker(timeout time.Duration) Checker {
+	return &httpChecker{
+		client:   &http.Client{Timeout: timeout},
+		timeout:  timeout,
+		username: os.Getenv("NEO4J_USERNAME"),
+		password: os.Getenv("NEO4J_PASSWORD"),
+	}
+}
+
+// Reachable dials the backup port of the server
+func (h *httpChecker) Reachable(server Server) error {
+	return probe.TCP(server.BackupAddress(), h.timeout)
+}
+
+// Status returns the status of the database on the server. A 404 means the database is not hosted (or not available)
+func (h *httpChecker) Status(server Server, database string) (DatabaseStatus, error) {
+	endpoint := fmt.Sprintf("http://%s/db/%s/cluster/status", server.HTTPAddress(), url.PathEscape(database))
+	request, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	if err != nil {
+		return DatabaseStatus{}, err
+	}
+	request.Header.Set("Accept", "application/json")
+	if h.username != "" {
+		request.SetBasicAuth(h.username, h.password)
+	}
+	response, err := h.client.Do(request)
+	if err != nil {
+		return DatabaseStatus{}, fmt.Errorf("unable to check database %s on server %s. Here's why: %w", database, server.Name, err)
+	}
+	defer response.Body.Close()
+	switch response.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		io.Copy(io.Discard, response.Body)
+		return DatabaseStatus{}, nil
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return DatabaseStatus{}, fmt.Errorf("unable to check database %s on server %s. The status endpoint requires credentials , set NEO4J_USERNAME and NEO4J_PASSWORD", database, server.Name)
+	default:
+		return DatabaseStatus{}, fmt.Errorf("unable to check database %s on server %s. Unexpected status %s", database, server.Name, response.Status)
+	}
+
+	var status clusterStatus
+	if err = json.NewDecoder(response.Body).Decode(&status); err != nil {
+		return DatabaseStatus{}, fmt.Errorf("unable to parse the status of database %s on server %s. Here's why: %w", database, server.Name, err)
+	}
+	role := RoleFollower
+	if !status.ParticipatingInRaftGroup {
+		role = RoleSecondary
+	} else if status.MemberID != "" && status.MemberID == status.Leader {
+		role = RoleLeader
+	}
+	return DatabaseStatus{Hosted: true, Healthy: status.Healthy, Role: role}, nil
+}
@@ -0,0 +1,187 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"log/slog"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// labels set by the neo4j helm chart on the services of every server
+const (
+	neo4jNameLabel = "helm.neo4j.com/neo4j.name"
+	instanceLabel  = "helm.neo4j.com/instance"
+	serviceLabel   = "helm.neo4j.com/service"
+)
+
+// Config describes the neo4j servers considered for the backup
+type Config struct {
+	Namespace     string
+	ClusterDomain string
+	// Neo4jName is the neo4j.name of the release (or of all the releases forming the cluster)
+	Neo4jName string
+	// Server restricts the discovery to the server of the given release (helm.neo4j.com/instance). Empty considers every server
+	Server string
+	// BackupPort and HTTPPort are used when the admin service does not expose the tcp-backup and tcp-http ports
+	BackupPort string
+	HTTPPort   string
+}
+
+// Enabled returns true when BACKUP_DISCOVERY is true
+func Enabled() bool {
+	return os.Getenv("BACKUP_DISCOVERY") == "true"
+}
+
+// ConfigFromEnv returns the discovery configuration as per the DISCOVERY_* and DATABASE_* environment variables
+func ConfigFromEnv() (Config, error) {
+	config := Config{
+		Namespace:     os.Getenv("DATABASE_NAMESPACE"),
+		ClusterDomain: os.Getenv("DATABASE_CLUSTER_DOMAIN"),
+		Neo4jName:     strings.TrimSpace(os.Getenv("DISCOVERY_NEO4J_NAME")),
+		Server:        strings.TrimSpace(os.Getenv("DISCOVERY_SERVER")),
+		BackupPort:    os.Getenv("DATABASE_BACKUP_PORT"),
+		HTTPPort:      os.Getenv("DISCOVERY_HTTP_PORT"),
+	}
+	if config.Neo4jName == "" {
+		return Config{}, fmt.Errorf("missing DISCOVERY_NEO4J_NAME. The neo4j.name of the release is required for the discovery")
+	}
+	if config.Namespace == "" {
+		config.Namespace = "default"
+	}
+	if config.ClusterDomain == "" {
+		config.ClusterDomain = "cluster.local"
+	}
+	if config.BackupPort == "" {
+		config.BackupPort = "6362"
+	}
+	if config.HTTPPort == "" {
+		config.HTTPPort = "7474"
+	}
+	return config, nil
+}
+
+// Server is a neo4j server reachable through its admin service
+type Server struct {
+	// Name is the release of the server (helm.neo4j.com/instance)
+	Name       string
+	Host       string
+	BackupPort string
+	HTTPPort   string
+}
+
+// BackupAddress returns the address of the backup port of the server in the format <host:port>
+func (s Server) BackupAddress() string {
+	return net.JoinHostPort(s.Host, s.BackupPort)
+}
+
+// HTTPAddress returns the address of the http port of the server in the format <host:port>
+func (s Server) HTTPAddress() string {
+	return net.JoinHostPort(s.Host, s.HTTPPort)
+}
+
+// Discoverer finds the neo4j servers of a release via the kubernetes api
+type Discoverer struct {
+	client  kubernetes.Interface
+	checker Checker
+	config  Config
+}
+
+// New returns a Discoverer using the given kubernetes client and checker
+func New(client kubernetes.Interface, checker Checker, config Config) *Discoverer {
+	return &Discoverer{client: client, checker: checker, config: config}
+}
+
+// NewInCluster returns a Discoverer authenticated with the service account of the pod
+func NewInCluster(config Config, timeout time.Duration) (*Discoverer, error) {
+	restConfig, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, fmt.Errorf("unable to load the in cluster kubernetes config. Here's why: %w", err)
+	}
+	client, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create the kubernetes client. Here's why: %w", err)
+	}
+	return New(client, NewHTTPChecker(timeout), config), nil
+}
+
+// Servers returns the servers of the release sorted by name. Every server is found via its admin service
+func (d *Discoverer) Servers(ctx context.Context) ([]Server, error) {
+	selector := labels.Set{neo4jNameLabel: d.config.Neo4jName, serviceLabel: "admin"}
+	if d.config.Server != "" {
+		selector[instanceLabel] = d.config.Server
+	}
+	services, err := d.client.CoreV1().Services(d.config.Namespace).List(ctx, metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list the admin services of %s in namespace %s. Here's why: %w", d.config.Neo4jName, d.config.Namespace, err)
+	}
+	var servers []Server
+	for _, service := range services.Items {
+		ready, err := d.ready(ctx, service)
+		if err != nil {
+			return nil, err
+		}
+		if !ready {
+			slog.Warn("Skipping server without ready pods", "server", service.Labels[instanceLabel], "service", service.Name)
+			continue
+		}
+		servers = append(servers, Server{
+			Name:       service.Labels[instanceLabel],
+			Host:       fmt.Sprintf("%s.%s.svc.%s", service.Name, service.Namespace, d.config.ClusterDomain),
+			BackupPort: servicePort(service, "tcp-backup", d.config.BackupPort),
+			HTTPPort:   servicePort(service, "tcp-http", d.config.HTTPPort),
+		})
+	}
+	sort.Slice(servers, func(i, j int) bool { return servers[i].Name < servers[j].Name })
+	return servers, nil
+}
+
+// Discover returns the server of the release to back up the databases from , preferring the ready secondaries
+// * only requires the system database
+func (d *Discoverer) Discover(ctx context.Context, databases []string) (Server, error) {
+	servers, err := d.Servers(ctx)
+	if err != nil {
+		return Server{}, err
+	}
+	if len(servers) == 0 {
+		return Server{}, fmt.Errorf("no ready neo4j server found for neo4j.name %s in namespace %s", d.config.Neo4jName, d.config.Namespace)
+	}
+	return Select(d.checker, servers, databases)
+}
+
+// ready returns true when at least one of the pods selected by the service is ready
+func (d *Discoverer) ready(ctx context.Context, service corev1.Service) (bool, error) {
+	if len(service.Spec.Selector) == 0 {
+		return false, nil
+	}
+	pods, err := d.client.CoreV1().Pods(service.Namespace).List(ctx, metav1.ListOptions{LabelSelector: labels.SelectorFromSet(service.Spec.Selector).String()})
+	if err != nil {
+		return false, fmt.Errorf("unable to list the pods of service %s. Here's why: %w", service.Name, err)
+	}
+	for _, pod := range pods.Items {
+		for _, condition := range pod.Status.Conditions {
+			if condition.Type == corev1.PodReady && condition.Status == corev1.ConditionTrue {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// servicePort returns the port of the service with the given name or the fallback if the service does not expose it
+func servicePort(service corev1.Service, name string, fallback string) string {
+	for _, port := range service.Spec.Ports {
+		if port.Name == name {
+			return fmt.Sprintf("%d", port.Port)
+		}
+	}
+	return fallback
+}
@@ -0,0 +1,206 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeChecker returns the status of the databases per server name. The servers in unreachable cannot be dialled
+type fakeChecker struct {
+	statuses    map[string]map[string]DatabaseStatus
+	unreachable map[string]bool
+}
+
+func (f fakeChecker) Reachable(server Server) error {
+	if f.unreachable[server.Name] {
+		return errors.New("connection refused")
+	}
+	return nil
+}
+
+func (f fakeChecker) Status(server Server, database string) (DatabaseStatus, error) {
+	return f.statuses[server.Name][database], nil
+}
+
+// hosted returns the statuses of healthy databases hosted with the given role
+func hosted(role Role, databases ...string) map[string]DatabaseStatus {
+	statuses := make(map[string]DatabaseStatus)
+	for _, database := range databases {
+		statuses[database] = DatabaseStatus{Hosted: true, Healthy: true, Role: role}
+	}
+	return statuses
+}
+
+// neo4jServer returns the admin service and pod of a server of the neo4j release as created by the neo4j helm chart
+func neo4jServer(neo4jName string, instance string, ready bool) []runtime.Object {
+	status := corev1.ConditionFalse
+	if ready {
+		status = corev1.ConditionTrue
+	}
+	return []runtime.Object{
+		&corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      instance + "-admin",
+				Namespace: "neo4j",
+				Labels:    map[string]string{neo4jNameLabel: neo4jName, instanceLabel: instance, serviceLabel: "admin"},
+			},
+			Spec: corev1.ServiceSpec{
+				Selector: map[string]string{instanceLabel: instance},
+				Ports:    []corev1.ServicePort{{Name: "tcp-backup", Port: 6362}, {Name: "tcp-http", Port: 7474}},
+			},
+		},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      instance + "-0",
+				Namespace: "neo4j",
+				Labels:    map[string]string{neo4jNameLabel: neo4jName, instanceLabel: instance},
+			},
+			Status: corev1.PodStatus{Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: status}}},
+		},
+	}
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	t.Setenv("DISCOVERY_NEO4J_NAME", "")
+	_, err := ConfigFromEnv()
+	assert.Error(t, err)
+
+	t.Setenv("DISCOVERY_NEO4J_NAME", "mycluster")
+	t.Setenv("DISCOVERY_SERVER", "server-2")
+	t.Setenv("DATABASE_NAMESPACE", "")
+	t.Setenv("DATABASE_CLUSTER_DOMAIN", "")
+	t.Setenv("DATABASE_BACKUP_PORT", "")
+	t.Setenv("DISCOVERY_HTTP_PORT", "")
+	config, err := ConfigFromEnv()
+	assert.NoError(t, err)
+	assert.Equal(t, Config{
+		Namespace:     "default",
+		ClusterDomain: "cluster.local",
+		Neo4jName:     "mycluster",
+		Server:        "server-2",
+		BackupPort:    "6362",
+		HTTPPort:      "7474",
+	}, config)
+}
+
+func TestDiscover(t *testing.T) {
+	t.Parallel()
+
+	var objects []runtime.Object
+	objects = append(objects, neo4jServer("mycluster", "server-1", false)...)
+	objects = append(objects, neo4jServer("mycluster", "server-2", true)...)
+	objects = append(objects, neo4jServer("mycluster", "server-3", true)...)
+	objects = append(objects, neo4jServer("mycluster", "server-4", true)...)
+	objects = append(objects, neo4jServer("othercluster", "other-1", true)...)
+	checker := fakeChecker{statuses: map[string]map[string]DatabaseStatus{
+		"server-1": hosted(RoleSecondary, "system", "neo4j", "sales"),
+		"server-2": hosted(RoleFollower, "system", "neo4j"),
+		"server-3": hosted(RoleFollower, "system", "neo4j", "sales"),
+		"server-4": hosted(RoleSecondary, "system", "neo4j"),
+		"other-1":  hosted(RoleSecondary, "system", "neo4j", "sales"),
+	}}
+	config := Config{Namespace: "neo4j", ClusterDomain: "cluster.local", Neo4jName: "mycluster", BackupPort: "6362", HTTPPort: "7474"}
+
+	tests := []struct {
+		name      string
+		server    string
+		databases []string
+		want      string
+		wantErr   bool
+	}{
+		{name: "ready secondary preferred", databases: []string{"neo4j"}, want: "server-4"},
+		{name: "all databases hosted", databases: []string{"neo4j", "sales"}, want: "server-3"},
+		{name: "all databases", databases: []string{"*"}, want: "server-4"},
+		{name: "specific server", server: "server-3", databases: []string{"neo4j"}, want: "server-3"},
+		{name: "specific server not ready", server: "server-1", databases: []string{"neo4j"}, wantErr: true},
+		{name: "database not hosted", databases: []string{"orders"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := config
+			config.Server = tt.server
+			discoverer := New(fake.NewSimpleClientset(objects...), checker, config)
+			server, err := discoverer.Discover(context.TODO(), tt.databases)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, server.Name)
+			assert.Equal(t, tt.want+"-admin.neo4j.svc.cluster.local:6362", server.BackupAddress())
+		})
+	}
+}
+
+func TestHTTPChecker(t *testing.T) {
+	t.Setenv("NEO4J_USERNAME", "neo4j")
+	t.Setenv("NEO4J_PASSWORD", "secret")
+
+	statuses := map[string]string{
+		"leader":    `{"memberId":"a","leader":"a","participatingInRaftGroup":true,"healthy":true}`,
+		"follower":  `{"memberId":"b","leader":"a","participatingInRaftGroup":true,"healthy":true}`,
+		"secondary": `{"memberId":"c","leader":"a","participatingInRaftGroup":false,"healthy":true}`,
+		"unhealthy": `{"memberId":"b","leader":"a","participatingInRaftGroup":true,"healthy":false}`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if username, password, ok := r.BasicAuth(); !ok || username != "neo4j" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		database := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/db/"), "/cluster/status")
+		if database == "broken" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		status, ok := statuses[database]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(status))
+	}))
+	defer server.Close()
+	host, port, _ := net.SplitHostPort(strings.TrimPrefix(server.URL, "http://"))
+	neo4j := Server{Name: "server-1", Host: host, BackupPort: port, HTTPPort: port}
+
+	checker := NewHTTPChecker(2 * time.Second)
+	assert.NoError(t, checker.Reachable(neo4j))
+	tests := []struct {
+		database string
+		want     DatabaseStatus
+		wantErr  bool
+	}{
+		{database: "leader", want: DatabaseStatus{Hosted: true, Healthy: true, Role: RoleLeader}},
+		{database: "follower", want: DatabaseStatus{Hosted: true, Healthy: true, Role: RoleFollower}},
+		{database: "secondary", want: DatabaseStatus{Hosted: true, Healthy: true, Role: RoleSecondary}},
+		{database: "unhealthy", want: DatabaseStatus{Hosted: true, Healthy: false, Role: RoleFollower}},
+		{database: "sales", want: DatabaseStatus{}},
+		{database: "broken", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.database, func(t *testing.T) {
+			status, err := checker.Status(neo4j, tt.database)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, status)
+		})
+	}
+
+	t.Setenv("NEO4J_PASSWORD", "wrong")
+	_, err := NewHTTPChecker(2*time.Second).Status(neo4j, "leader")
+	assert.ErrorContains(t, err, "requires credentials")
+}
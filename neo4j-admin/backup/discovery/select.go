@@ -0,0 +1,135 @@
+package discovery
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"strings"
+)
+
+// Role is the role of a server for a database. A lower role is preferred for the backup
+type Role int
+
+const (
+	// RoleSecondary servers do not participate in the raft group and are not affected by the backup load
+	RoleSecondary Role = iota
+	// RoleFollower servers are primaries which are not the leader of the database
+	RoleFollower
+	// RoleLeader servers accept the writes of the database
+	RoleLeader
+)
+
+func (r Role) String() string {
+	switch r {
+	case RoleSecondary:
+		return "secondary"
+	case RoleFollower:
+		return "follower"
+	default:
+		return "leader"
+	}
+}
+
+// DatabaseStatus is the status of a database on a server
+type DatabaseStatus struct {
+	// Hosted is false when the database is not hosted (or not available) on the server
+	Hosted  bool
+	Healthy bool
+	Role    Role
+}
+
+// Checker checks the health of the servers and the status of their databases
+type Checker interface {
+	// Reachable returns an error when the backup port of the server cannot be reached
+	Reachable(server Server) error
+	Status(server Server, database string) (DatabaseStatus, error)
+}
+
+// EndpointsFromEnv returns the servers of the ordered list DATABASE_BACKUP_ENDPOINTS ex: server-1-admin:6362,server-2-admin:6362
+// The database status of every endpoint is queried on DISCOVERY_HTTP_PORT (default 7474) of the same host
+func EndpointsFromEnv() ([]Server, error) {
+	httpPort := os.Getenv("DISCOVERY_HTTP_PORT")
+	if httpPort == "" {
+		httpPort = "7474"
+	}
+	var servers []Server
+	for _, endpoint := range strings.Split(os.Getenv("DATABASE_BACKUP_ENDPOINTS"), ",") {
+		endpoint = strings.TrimSpace(endpoint)
+		if endpoint == "" {
+			continue
+		}
+		host, port, err := net.SplitHostPort(endpoint)
+		if err != nil {
+			return nil, fmt.Errorf("invalid backup endpoint %s in DATABASE_BACKUP_ENDPOINTS. Value should be in the format <host:port>", endpoint)
+		}
+		servers = append(servers, Server{Name: endpoint, Host: host, BackupPort: port, HTTPPort: httpPort})
+	}
+	if len(servers) == 0 {
+		return nil, fmt.Errorf("empty DATABASE_BACKUP_ENDPOINTS")
+	}
+	return servers, nil
+}
+
+// Select returns the server to back up the databases from. Secondaries are preferred over followers and followers over
+// leaders so that the backup does not add load to the servers accepting the writes. The order of the servers breaks ties
+// A server is only selected when its backup port is reachable and it hosts all the databases in a healthy state
+func Select(checker Checker, servers []Server, databases []string) (Server, error) {
+	var selected Server
+	selectedRole := Role(-1)
+	for _, server := range servers {
+		role, ok := rank(checker, server, databases)
+		if !ok {
+			continue
+		}
+		if selectedRole == -1 || role < selectedRole {
+			selected, selectedRole = server, role
+		}
+		if role == RoleSecondary {
+			break
+		}
+	}
+	if selectedRole == -1 {
+		return Server{}, fmt.Errorf("none of the servers %v is healthy and hosts all the databases %v", serverNames(servers), databases)
+	}
+	if selectedRole != RoleSecondary {
+		slog.Warn("No healthy secondary hosts all the databases , falling back to a primary", "server", selected.Name, "role", selectedRole.String())
+	}
+	slog.Info("Backup server selected", "server", selected.Name, "role", selectedRole.String(), "address", selected.BackupAddress())
+	return selected, nil
+}
+
+// rank returns the highest role of the server across the databases and false when the server cannot be used for the backup
+func rank(checker Checker, server Server, databases []string) (Role, bool) {
+	logger := slog.With("server", server.Name)
+	if err := checker.Reachable(server); err != nil {
+		logger.Warn("Skipping unreachable server", "error", err)
+		return 0, false
+	}
+	role := RoleSecondary
+	for _, database := range databases {
+		// every server hosts the system database
+		if database == "*" {
+			database = "system"
+		}
+		status, err := checker.Status(server, database)
+		if err != nil {
+			logger.Warn("Skipping server , unable to get the database status", "database", database, "error", err)
+			return 0, false
+		}
+		if !status.Hosted || !status.Healthy {
+			logger.Info("Skipping server , database not hosted or unhealthy", "database", database, "hosted", status.Hosted, "healthy", status.Healthy)
+			return 0, false
+		}
+		role = max(role, status.Role)
+	}
+	return role, true
+}
+
+func serverNames(servers []Server) []string {
+	names := make([]string, 0, len(servers))
+	for _, server := range servers {
+		names = append(names, server.Name)
+	}
+	return names
+}
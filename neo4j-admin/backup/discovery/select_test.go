@@ -0,0 +1,68 @@
+package discovery
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestEndpointsFromEnv(t *testing.T) {
+	t.Setenv("DISCOVERY_HTTP_PORT", "")
+	t.Setenv("DATABASE_BACKUP_ENDPOINTS", " server-2-admin:6362, 10.3.3.2:6362 ,")
+	servers, err := EndpointsFromEnv()
+	assert.NoError(t, err)
+	assert.Equal(t, []Server{
+		{Name: "server-2-admin:6362", Host: "server-2-admin", BackupPort: "6362", HTTPPort: "7474"},
+		{Name: "10.3.3.2:6362", Host: "10.3.3.2", BackupPort: "6362", HTTPPort: "7474"},
+	}, servers)
+
+	for _, endpoints := range []string{"", "server-2-admin"} {
+		t.Run(endpoints, func(t *testing.T) {
+			t.Setenv("DATABASE_BACKUP_ENDPOINTS", endpoints)
+			_, err := EndpointsFromEnv()
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestSelect(t *testing.T) {
+	t.Parallel()
+
+	servers := []Server{{Name: "leader"}, {Name: "follower"}, {Name: "secondary"}, {Name: "unhealthy-secondary"}, {Name: "unreachable-secondary"}}
+	checker := fakeChecker{
+		statuses: map[string]map[string]DatabaseStatus{
+			"leader":                hosted(RoleLeader, "system", "neo4j", "sales"),
+			"follower":              hosted(RoleFollower, "system", "neo4j", "sales"),
+			"secondary":             hosted(RoleSecondary, "system", "neo4j"),
+			"unhealthy-secondary":   {"system": {Hosted: true, Healthy: false, Role: RoleSecondary}, "sales": {Hosted: true, Healthy: false, Role: RoleSecondary}},
+			"unreachable-secondary": hosted(RoleSecondary, "system", "neo4j", "sales"),
+		},
+		unreachable: map[string]bool{"unreachable-secondary": true},
+	}
+
+	tests := []struct {
+		name      string
+		servers   []Server
+		databases []string
+		want      string
+		wantErr   bool
+	}{
+		{name: "secondary", servers: servers, databases: []string{"neo4j"}, want: "secondary"},
+		{name: "system database", servers: servers, databases: []string{"*"}, want: "secondary"},
+		{name: "fallback to follower", servers: servers, databases: []string{"neo4j", "sales"}, want: "follower"},
+		{name: "fallback to leader", servers: servers[:1], databases: []string{"sales"}, want: "leader"},
+		{name: "follower preferred over leader", servers: []Server{{Name: "leader"}, {Name: "follower"}}, databases: []string{"neo4j"}, want: "follower"},
+		{name: "order breaks ties", servers: []Server{{Name: "follower"}, {Name: "leader"}, {Name: "unreachable-secondary"}}, databases: []string{"sales"}, want: "follower"},
+		{name: "none available", servers: servers, databases: []string{"orders"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, err := Select(checker, tt.servers, tt.databases)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, server.Name)
+		})
+	}
+}
@@ -0,0 +1,248 @@
+package encryption
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// magic identifies the files encrypted by this package
+const magic = "N4JBENC1"
+
+// chunkSize is the size of the plaintext chunks which are sealed individually
+// so that multi GB backup files can be encrypted without loading them in memory
+const chunkSize = 64 * 1024
+
+// KeySize is the size of the AES-256 key in bytes
+const KeySize = 32
+
+// nonce is made of a random prefix per file followed by the chunk counter
+const noncePrefixSize = 8
+
+// KeyFromFile reads the encryption key from the provided file (ex: a mounted kubernetes secret)
+// The file should contain a base64 encoded 32 byte key (ex: generated via 'openssl rand -base64 32') or the raw 32 bytes
+func KeyFromFile(keyPath string) ([]byte, error) {
+	data, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't read encryption key from %s. Here's why: %v\n", keyPath, err)
+	}
+	if len(data) == KeySize {
+		return data, nil
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, fmt.Errorf("Invalid encryption key present at %s. The key should be base64 encoded. Here's why: %v\n", keyPath, err)
+	}
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("Invalid encryption key present at %s. The key should be %d bytes long but is %d bytes", keyPath, KeySize, len(key))
+	}
+	return key, nil
+}
+
+// Encrypt reads the plaintext from the reader and writes it to the writer encrypted with AES-256-GCM
+// The plaintext is sealed in chunks. The last chunk is marked so that a truncated ciphertext is detected on decryption
+func Encrypt(key []byte, writer io.Writer, reader io.Reader) error {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return err
+	}
+	noncePrefix := make([]byte, noncePrefixSize)
+	if _, err = rand.Read(noncePrefix); err != nil {
+		return fmt.Errorf("Couldn't generate nonce. Here's why: %v\n", err)
+	}
+	if _, err = writer.Write(append([]byte(magic), noncePrefix...)); err != nil {
+		return err
+	}
+
+	bufferedReader := bufio.NewReaderSize(reader, chunkSize)
+	plaintext := make([]byte, chunkSize)
+	var counter uint32
+	for {
+		n, err := io.ReadFull(bufferedReader, plaintext)
+		if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+			return err
+		}
+		last := err != nil
+		if !last {
+			// a full chunk is the last one only if nothing follows it
+			if _, err = bufferedReader.Peek(1); errors.Is(err, io.EOF) {
+				last = true
+			}
+		}
+		ciphertext := aead.Seal(nil, nonce(noncePrefix, counter), plaintext[:n], additionalData(last))
+		if _, err = writer.Write(ciphertext); err != nil {
+			return err
+		}
+		if last {
+			return nil
+		}
+		counter++
+	}
+}
+
+// Decrypt reads the ciphertext written by Encrypt from the reader and writes the plaintext to the writer
+func Decrypt(key []byte, writer io.Writer, reader io.Reader) error {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return err
+	}
+	header := make([]byte, len(magic)+noncePrefixSize)
+	if _, err = io.ReadFull(reader, header); err != nil || string(header[:len(magic)]) != magic {
+		return fmt.Errorf("Contents are not encrypted or the header is corrupted")
+	}
+	noncePrefix := header[len(magic):]
+
+	bufferedReader := bufio.NewReaderSize(reader, chunkSize+aead.Overhead())
+	ciphertext := make([]byte, chunkSize+aead.Overhead())
+	var counter uint32
+	for {
+		n, err := io.ReadFull(bufferedReader, ciphertext)
+		if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+			return err
+		}
+		last := err != nil
+		if !last {
+			if _, err = bufferedReader.Peek(1); errors.Is(err, io.EOF) {
+				last = true
+			}
+		}
+		plaintext, err := aead.Open(nil, nonce(noncePrefix, counter), ciphertext[:n], additionalData(last))
+		if err != nil {
+			return fmt.Errorf("Couldn't decrypt chunk %d. The contents are either truncated , corrupted or encrypted with a different key. Here's why: %v", counter, err)
+		}
+		if _, err = writer.Write(plaintext); err != nil {
+			return err
+		}
+		if last {
+			return nil
+		}
+		counter++
+	}
+}
+
+// EncryptFile encrypts the file present at the provided path in place
+func EncryptFile(key []byte, filePath string) error {
+	return EncryptFileTo(key, filePath, filePath)
+}
+
+// EncryptFileTo writes the encrypted contents of the file present at the source path to the destination path
+// The source file is left untouched unless both paths are the same
+func EncryptFileTo(key []byte, sourcePath string, destinationPath string) error {
+	return transformFile(sourcePath, destinationPath, func(writer io.Writer, reader io.Reader) error {
+		return Encrypt(key, writer, reader)
+	})
+}
+
+// DecryptFile decrypts the file present at the provided path in place
+func DecryptFile(key []byte, filePath string) error {
+	return transformFile(filePath, filePath, func(writer io.Writer, reader io.Reader) error {
+		return Decrypt(key, writer, reader)
+	})
+}
+
+// IsEncrypted returns true if the file present at the provided path was encrypted by EncryptFile
+func IsEncrypted(filePath string) (bool, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return false, fmt.Errorf("Couldn't open file %s. Here's why: %v\n", filePath, err)
+	}
+	defer file.Close()
+	header := make([]byte, len(magic))
+	if _, err = io.ReadFull(file, header); err != nil {
+		return false, nil
+	}
+	return bytes.Equal(header, []byte(magic)), nil
+}
+
+// IsEncryptedWith returns true if the file present at the provided path was encrypted by EncryptFile with the provided key
+// Only the first chunk is decrypted
+func IsEncryptedWith(key []byte, filePath string) (bool, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return false, err
+	}
+	file, err := os.Open(filePath)
+	if err != nil {
+		return false, fmt.Errorf("Couldn't open file %s. Here's why: %v\n", filePath, err)
+	}
+	defer file.Close()
+	header := make([]byte, len(magic)+noncePrefixSize)
+	if _, err = io.ReadFull(file, header); err != nil || string(header[:len(magic)]) != magic {
+		return false, nil
+	}
+	ciphertext := make([]byte, chunkSize+aead.Overhead())
+	n, err := io.ReadFull(file, ciphertext)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return false, fmt.Errorf("Couldn't read file %s. Here's why: %v\n", filePath, err)
+	}
+	// the first chunk is also the last one of a small file
+	for _, last := range []bool{false, true} {
+		if _, err = aead.Open(nil, nonce(header[len(magic):], 0), ciphertext[:n], additionalData(last)); err == nil {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// transformFile writes the transformed contents of the source file to a temporary file next to the destination and
+// replaces the destination with it
+func transformFile(sourcePath string, destinationPath string, transform func(writer io.Writer, reader io.Reader) error) error {
+	source, err := os.Open(sourcePath)
+	if err != nil {
+		return fmt.Errorf("Couldn't open file %s. Here's why: %v\n", sourcePath, err)
+	}
+	defer source.Close()
+
+	temporaryFileName := destinationPath + ".tmp"
+	destination, err := os.Create(temporaryFileName)
+	if err != nil {
+		return fmt.Errorf("Couldn't create file %s. Here's why: %v\n", temporaryFileName, err)
+	}
+	bufferedWriter := bufio.NewWriterSize(destination, chunkSize)
+	if err = transform(bufferedWriter, source); err == nil {
+		err = bufferedWriter.Flush()
+	}
+	if closeErr := destination.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(temporaryFileName)
+		return fmt.Errorf("Couldn't transform file %s. Here's why: %v\n", sourcePath, err)
+	}
+	if err = os.Rename(temporaryFileName, destinationPath); err != nil {
+		os.Remove(temporaryFileName)
+		return fmt.Errorf("Couldn't rename file %s to %s. Here's why: %v\n", temporaryFileName, destinationPath, err)
+	}
+	return nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid encryption key. Here's why: %v\n", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+func nonce(noncePrefix []byte, counter uint32) []byte {
+	value := make([]byte, noncePrefixSize+4)
+	copy(value, noncePrefix)
+	binary.BigEndian.PutUint32(value[noncePrefixSize:], counter)
+	return value
+}
+
+func additionalData(last bool) []byte {
+	if last {
+		return []byte{1}
+	}
+	return []byte{0}
+}
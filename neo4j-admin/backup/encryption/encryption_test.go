@@ -0,0 +1,194 @@
+package encryption
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newKey(t *testing.T) []byte {
+	key := make([]byte, KeySize)
+	_, err := rand.Read(key)
+	assert.NoError(t, err)
+	return key
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	t.Parallel()
+
+	key := newKey(t)
+	tests := []struct {
+		name string
+		size int
+	}{
+		{name: "empty", size: 0},
+		{name: "smaller than a chunk", size: 100},
+		{name: "exactly one chunk", size: chunkSize},
+		{name: "multiple chunks", size: 3*chunkSize + 17},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plaintext := make([]byte, tt.size)
+			_, err := rand.Read(plaintext)
+			assert.NoError(t, err)
+
+			var ciphertext bytes.Buffer
+			assert.NoError(t, Encrypt(key, &ciphertext, bytes.NewReader(plaintext)))
+			if tt.size > 0 {
+				assert.NotContains(t, ciphertext.String(), string(plaintext))
+			}
+
+			var decrypted bytes.Buffer
+			assert.NoError(t, Decrypt(key, &decrypted, bytes.NewReader(ciphertext.Bytes())))
+			assert.Equal(t, tt.size, decrypted.Len())
+			assert.True(t, bytes.Equal(plaintext, decrypted.Bytes()))
+		})
+	}
+}
+
+func TestDecryptErrors(t *testing.T) {
+	t.Parallel()
+
+	key := newKey(t)
+	plaintext := make([]byte, 2*chunkSize+10)
+	var ciphertext bytes.Buffer
+	assert.NoError(t, Encrypt(key, &ciphertext, bytes.NewReader(plaintext)))
+	data := ciphertext.Bytes()
+
+	tampered := bytes.Clone(data)
+	tampered[len(tampered)/2] ^= 0xff
+
+	tests := []struct {
+		name string
+		key  []byte
+		data []byte
+	}{
+		{name: "wrong key", key: newKey(t), data: data},
+		{name: "truncated at chunk boundary", key: key, data: data[:len(magic)+noncePrefixSize+chunkSize+16]},
+		{name: "truncated", key: key, data: data[:len(data)-5]},
+		{name: "tampered", key: key, data: tampered},
+		{name: "not encrypted", key: key, data: plaintext},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var decrypted bytes.Buffer
+			assert.Error(t, Decrypt(tt.key, &decrypted, bytes.NewReader(tt.data)))
+		})
+	}
+}
+
+func TestEncryptFile(t *testing.T) {
+	t.Parallel()
+
+	key := newKey(t)
+	filePath := filepath.Join(t.TempDir(), "neo4j-2024-03-10T10-00-00.backup")
+	assert.NoError(t, os.WriteFile(filePath, []byte("demo"), 0644))
+
+	encrypted, err := IsEncrypted(filePath)
+	assert.NoError(t, err)
+	assert.False(t, encrypted)
+
+	assert.NoError(t, EncryptFile(key, filePath))
+	encrypted, err = IsEncrypted(filePath)
+	assert.NoError(t, err)
+	assert.True(t, encrypted)
+
+	assert.NoError(t, DecryptFile(key, filePath))
+	data, err := os.ReadFile(filePath)
+	assert.NoError(t, err)
+	assert.Equal(t, "demo", string(data))
+
+	assert.Error(t, DecryptFile(key, filePath))
+	data, err = os.ReadFile(filePath)
+	assert.NoError(t, err)
+	assert.Equal(t, "demo", string(data), "failed decryption should not modify the file")
+}
+
+func TestEncryptFileTo(t *testing.T) {
+	t.Parallel()
+
+	key := newKey(t)
+	directory := t.TempDir()
+	filePath := filepath.Join(directory, "neo4j-2024-03-10T10-00-00.backup")
+	copyPath := filepath.Join(directory, "copy.backup")
+	assert.NoError(t, os.WriteFile(filePath, []byte("demo"), 0644))
+
+	assert.NoError(t, EncryptFileTo(key, filePath, copyPath))
+	data, err := os.ReadFile(filePath)
+	assert.NoError(t, err)
+	assert.Equal(t, "demo", string(data), "the source file should be left untouched")
+	encrypted, err := IsEncrypted(copyPath)
+	assert.NoError(t, err)
+	assert.True(t, encrypted)
+
+	assert.NoError(t, DecryptFile(key, copyPath))
+	data, err = os.ReadFile(copyPath)
+	assert.NoError(t, err)
+	assert.Equal(t, "demo", string(data))
+}
+
+func TestIsEncryptedWith(t *testing.T) {
+	t.Parallel()
+
+	key := newKey(t)
+	directory := t.TempDir()
+	filePath := filepath.Join(directory, "neo4j-2024-03-10T10-00-00.backup")
+	assert.NoError(t, os.WriteFile(filePath, []byte("demo"), 0644))
+
+	encrypted, err := IsEncryptedWith(key, filePath)
+	assert.NoError(t, err)
+	assert.False(t, encrypted)
+
+	assert.NoError(t, EncryptFile(key, filePath))
+	encrypted, err = IsEncryptedWith(key, filePath)
+	assert.NoError(t, err)
+	assert.True(t, encrypted)
+	encrypted, err = IsEncryptedWith(newKey(t), filePath)
+	assert.NoError(t, err)
+	assert.False(t, encrypted, "the file was encrypted with a different key")
+
+	// a file spanning several chunks
+	largeFilePath := filepath.Join(directory, "large.backup")
+	assert.NoError(t, os.WriteFile(largeFilePath, bytes.Repeat([]byte("demo"), chunkSize), 0644))
+	assert.NoError(t, EncryptFile(key, largeFilePath))
+	encrypted, err = IsEncryptedWith(key, largeFilePath)
+	assert.NoError(t, err)
+	assert.True(t, encrypted)
+
+	_, err = IsEncryptedWith(key, filepath.Join(directory, "missing.backup"))
+	assert.Error(t, err)
+}
+
+func TestKeyFromFile(t *testing.T) {
+	t.Parallel()
+
+	key := newKey(t)
+	directory := t.TempDir()
+	tests := []struct {
+		name     string
+		contents []byte
+		wantErr  bool
+	}{
+		{name: "base64", contents: []byte(base64.StdEncoding.EncodeToString(key) + "\n")},
+		{name: "raw", contents: key},
+		{name: "short key", contents: []byte(base64.StdEncoding.EncodeToString(key[:16])), wantErr: true},
+		{name: "invalid", contents: []byte("not a key"), wantErr: true},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keyPath := filepath.Join(directory, string(rune('a'+i)))
+			assert.NoError(t, os.WriteFile(keyPath, tt.contents, 0600))
+			got, err := KeyFromFile(keyPath)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, key, got)
+		})
+	}
+}
@@ -0,0 +1,29 @@
+package filesystem
+
+import (
+	"fmt"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/common"
+	"os"
+)
+
+// filesystemClient implements common.StorageBackend
+var _ common.StorageBackend = &filesystemClient{}
+
+// filesystemClient stores the backup files in a mounted directory (ex: an NFS persistent volume)
+// The bucket name is treated as a path relative to the root directory
+type filesystemClient struct {
+	rootPath string
+}
+
+func NewFilesystemClient(rootPath string) (*filesystemClient, error) {
+	info, err := os.Stat(rootPath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to access destination directory %s \n Here's why: %v", rootPath, err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("destination %s is not a directory", rootPath)
+	}
+	return &filesystemClient{
+		rootPath: rootPath,
+	}, nil
+}
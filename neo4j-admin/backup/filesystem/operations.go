@@ -0,0 +1,227 @@
+package filesystem
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/common"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/logging"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/retry"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// partialFileSuffix is appended to the files while they are being copied
+// A file is renamed to its final name only once it is completely written
+const partialFileSuffix = ".partial"
+
+// CheckAccess checks if the directory of the given bucket name exists and is writable
+// The prefix directories are created if missing
+func (f *filesystemClient) CheckAccess(bucketName string) error {
+
+	parentBucketName, _ := common.SplitBucketName(bucketName)
+	info, err := os.Stat(f.path(parentBucketName))
+	if err != nil {
+		return fmt.Errorf("Unable to access directory %s \n Here's why: %v", f.path(parentBucketName), err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", f.path(parentBucketName))
+	}
+
+	directory := f.path(bucketName)
+	if err = os.MkdirAll(directory, 0755); err != nil {
+		return fmt.Errorf("Unable to create directory %s \n Here's why: %v", directory, err)
+	}
+	file, err := os.CreateTemp(directory, ".access-check-*")
+	if err != nil {
+		return fmt.Errorf("Directory %s is not writable \n Here's why: %v", directory, err)
+	}
+	file.Close()
+	if err = os.Remove(file.Name()); err != nil {
+		return fmt.Errorf("Unable to delete file %s \n Here's why: %v", file.Name(), err)
+	}
+	slog.Info("Access to directory established", "directory", directory)
+	return nil
+}
+
+// UploadFile copies the files present at the location to the directory of the given bucket name
+func (f *filesystemClient) UploadFile(fileNames []string, bucketName string) error {
+
+	directory := f.path(bucketName)
+	if err := os.MkdirAll(directory, 0755); err != nil {
+		return fmt.Errorf("Unable to create directory %s \n Here's why: %v", directory, err)
+	}
+	location := os.Getenv("LOCATION")
+	for _, fileName := range fileNames {
+		source := fmt.Sprintf("%s/%s", location, fileName)
+		destination := filepath.Join(directory, fileName)
+		logger := logging.ForFile(fileName)
+		logger.Info("Starting copy of file", "source", source, "destination", destination)
+		var checksums common.Checksums
+		err := retry.Do("upload", isRetryable, func() error {
+			var err error
+			checksums, err = copyFile(source, destination)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		// the metadata recorded for the file , along with its checksum , is kept next to the copy since files have no object metadata
+		metadata, err := common.ObjectMetadata(location, fileName, checksums)
+		if err != nil {
+			return err
+		}
+		if err = common.SaveFileMetadata(directory, fileName, metadata); err != nil {
+			return err
+		}
+		logger.Info("File copied to directory", "directory", directory)
+	}
+	return nil
+}
+
+// DownloadFile copies the files present in the directory of the given bucket name to the location
+func (f *filesystemClient) DownloadFile(fileNames []string, bucketName string) error {
+
+	directory := f.path(bucketName)
+	location := os.Getenv("LOCATION")
+	for _, fileName := range fileNames {
+		source := filepath.Join(directory, fileName)
+		destination := fmt.Sprintf("%s/%s", location, fileName)
+		logger := logging.ForFile(fileName)
+		logger.Info("Starting copy of file", "source", source, "destination", destination)
+		err := retry.Do("download", isRetryable, func() error {
+			_, err := copyFile(source, destination)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		logger.Info("File copied from directory", "directory", directory, "destination", destination)
+	}
+	return nil
+}
+
+// ListFiles returns the files present in the directory of the given bucket name
+// Sub directories , hidden files (ex: metadata files) and partially copied files are skipped
+func (f *filesystemClient) ListFiles(bucketName string) ([]common.FileInfo, error) {
+
+	directory := f.path(bucketName)
+	entries, err := os.ReadDir(directory)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to list files in directory %s \n Here's why: %v", directory, err)
+	}
+	var files []common.FileInfo
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") || strings.HasSuffix(entry.Name(), partialFileSuffix) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, fmt.Errorf("Unable to get info of file %s \n Here's why: %v", filepath.Join(directory, entry.Name()), err)
+		}
+		files = append(files, common.FileInfo{
+			Name:         entry.Name(),
+			Size:         info.Size(),
+			LastModified: info.ModTime(),
+		})
+	}
+	return files, nil
+}
+
+// DeleteFiles deletes the files from the directory of the given bucket name
+func (f *filesystemClient) DeleteFiles(fileNames []string, bucketName string) error {
+
+	directory := f.path(bucketName)
+	for _, fileName := range fileNames {
+		logging.ForFile(fileName).Info("Deleting file", "directory", directory)
+		if err := os.Remove(filepath.Join(directory, fileName)); err != nil {
+			return fmt.Errorf("Couldn't delete file %s from directory %s \n Here's why: %v", fileName, directory, err)
+		}
+		if err := common.RemoveFileMetadata(directory, fileName); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// StatFile returns the info of the file present in the directory of the given bucket name
+func (f *filesystemClient) StatFile(fileName string, bucketName string) (common.FileInfo, error) {
+
+	directory := f.path(bucketName)
+	filePath := filepath.Join(directory, fileName)
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return common.FileInfo{}, fmt.Errorf("Couldn't get info of file %s \n Here's why: %v", filePath, err)
+	}
+	metadata, err := common.LoadFileMetadata(directory, fileName)
+	if err != nil {
+		return common.FileInfo{}, err
+	}
+	return common.FileInfo{
+		Name:         fileName,
+		Size:         info.Size(),
+		LastModified: info.ModTime(),
+		Metadata:     common.NormalizeMetadata(metadata),
+	}, nil
+}
+
+// path returns the directory path of the given bucket name
+func (f *filesystemClient) path(bucketName string) string {
+	return filepath.Join(f.rootPath, bucketName)
+}
+
+// copyFile copies the source file to a partial file next to the destination and renames it once the copy is complete
+// and the checksum of the partial file matches the checksum of the source file. The checksums of the copy are returned
+func copyFile(source string, destination string) (common.Checksums, error) {
+	sourceFile, err := os.Open(source)
+	if err != nil {
+		return common.Checksums{}, fmt.Errorf("Couldn't open file %s to copy. Here's why: %w", source, err)
+	}
+	defer sourceFile.Close()
+	sourceHash := sha256.New()
+
+	partialFileName := destination + partialFileSuffix
+	destinationFile, err := os.Create(partialFileName)
+	if err != nil {
+		return common.Checksums{}, fmt.Errorf("Couldn't create file %s. Here's why: %w", partialFileName, err)
+	}
+	if _, err = io.Copy(destinationFile, io.TeeReader(sourceFile, sourceHash)); err != nil {
+		destinationFile.Close()
+		os.Remove(partialFileName)
+		return common.Checksums{}, fmt.Errorf("Couldn't copy file %s to %s. Here's why: %w", source, partialFileName, err)
+	}
+	// ensure the contents are persisted before the file becomes visible with its final name
+	if err = destinationFile.Sync(); err != nil {
+		destinationFile.Close()
+		os.Remove(partialFileName)
+		return common.Checksums{}, fmt.Errorf("Couldn't sync file %s. Here's why: %w", partialFileName, err)
+	}
+	if err = destinationFile.Close(); err != nil {
+		os.Remove(partialFileName)
+		return common.Checksums{}, fmt.Errorf("Couldn't close file %s. Here's why: %w", partialFileName, err)
+	}
+	checksums, err := common.ComputeChecksums(partialFileName)
+	if err != nil {
+		os.Remove(partialFileName)
+		return common.Checksums{}, err
+	}
+	if !bytes.Equal(checksums.SHA256, sourceHash.Sum(nil)) {
+		os.Remove(partialFileName)
+		return common.Checksums{}, fmt.Errorf("Checksum mismatch for file %s copied to %s. Source SHA-256 = %x , Destination SHA-256 = %s", source, partialFileName, sourceHash.Sum(nil), checksums.SHA256Hex())
+	}
+	if err = os.Rename(partialFileName, destination); err != nil {
+		os.Remove(partialFileName)
+		return common.Checksums{}, fmt.Errorf("Couldn't rename file %s to %s. Here's why: %w", partialFileName, destination, err)
+	}
+	return checksums, nil
+}
+
+// isRetryable retries the copy unless a file is missing or not accessible. Other errors ex: an NFS timeout are retried
+func isRetryable(err error) bool {
+	return !errors.Is(err, fs.ErrNotExist) && !errors.Is(err, fs.ErrPermission)
+}
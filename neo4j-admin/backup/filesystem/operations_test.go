@@ -0,0 +1,113 @@
+package filesystem
+
+import (
+	"fmt"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/common"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckAccessForFilesystem(t *testing.T) {
+	rootPath := t.TempDir()
+	assert.NoError(t, os.Mkdir(filepath.Join(rootPath, "helm-backup-test"), 0755))
+	client, err := NewFilesystemClient(rootPath)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		wantErr    bool
+		bucketName string
+	}{
+		{
+			name:       "valid bucket",
+			wantErr:    false,
+			bucketName: "helm-backup-test",
+		},
+		{
+			name:       "valid bucket with subdirectories",
+			wantErr:    false,
+			bucketName: "helm-backup-test/test/test2",
+		},
+		{
+			name:       "invalid bucket",
+			wantErr:    true,
+			bucketName: "does-not-exist-bucket",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := client.CheckAccess(tt.bucketName); (err != nil) != tt.wantErr {
+				t.Errorf("CheckAccess() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUploadListAndDeleteForFilesystem(t *testing.T) {
+	rootPath := t.TempDir()
+	client, err := NewFilesystemClient(rootPath)
+	assert.NoError(t, err)
+
+	currentDirectory, err := os.Getwd()
+	assert.NoError(t, err)
+	t.Setenv("LOCATION", fmt.Sprintf("%s/../testData", currentDirectory))
+
+	bucketName := "helm-backup-test/test"
+	assert.NoError(t, client.UploadFile([]string{"test.yaml", "test2.yaml"}, bucketName))
+	// partial files left by an interrupted copy are not listed
+	assert.NoError(t, os.WriteFile(filepath.Join(rootPath, bucketName, "test3.yaml"+partialFileSuffix), []byte("demo"), 0644))
+
+	files, err := client.ListFiles(bucketName)
+	assert.NoError(t, err)
+	assert.Len(t, files, 2)
+	assert.Equal(t, "test.yaml", files[0].Name)
+	expected, err := os.ReadFile(fmt.Sprintf("%s/../testData/test.yaml", currentDirectory))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(expected)), files[0].Size)
+
+	info, err := client.StatFile("test2.yaml", bucketName)
+	assert.NoError(t, err)
+	assert.Equal(t, "test2.yaml", info.Name)
+	checksums, err := common.ComputeChecksums(fmt.Sprintf("%s/../testData/test2.yaml", currentDirectory))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{common.ChecksumMetadataKey: checksums.SHA256Hex()}, info.Metadata, "the checksum of the copy is recorded")
+
+	// the metadata recorded for a file is copied along with it and returned as its metadata
+	location := t.TempDir()
+	t.Setenv("LOCATION", location)
+	assert.NoError(t, os.WriteFile(filepath.Join(location, "test4.yaml"), []byte("demo"), 0644))
+	assert.NoError(t, common.SaveFileMetadata(location, "test4.yaml", map[string]string{"backup_type": "FULL"}))
+	assert.NoError(t, client.UploadFile([]string{"test4.yaml"}, bucketName))
+	info, err = client.StatFile("test4.yaml", bucketName)
+	assert.NoError(t, err)
+	assert.Equal(t, "FULL", info.Metadata["backup_type"])
+	assert.NotEmpty(t, info.Metadata[common.ChecksumMetadataKey])
+	files, err = client.ListFiles(bucketName)
+	assert.NoError(t, err)
+	assert.Len(t, files, 3, "metadata files are not listed")
+	assert.NoError(t, client.DeleteFiles([]string{"test4.yaml"}, bucketName))
+	_, err = os.Stat(common.MetadataPath(filepath.Join(rootPath, bucketName), "test4.yaml"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	t.Setenv("LOCATION", t.TempDir())
+	assert.NoError(t, client.DownloadFile([]string{"test.yaml"}, bucketName))
+	downloaded, err := os.ReadFile(fmt.Sprintf("%s/test.yaml", os.Getenv("LOCATION")))
+	assert.NoError(t, err)
+	assert.Equal(t, expected, downloaded)
+
+	assert.NoError(t, client.DeleteFiles([]string{"test.yaml"}, bucketName))
+	files, err = client.ListFiles(bucketName)
+	assert.NoError(t, err)
+	assert.Len(t, files, 1)
+	_, err = client.StatFile("test.yaml", bucketName)
+	assert.Error(t, err)
+}
+
+func TestIsRetryableForFilesystem(t *testing.T) {
+	_, err := copyFile(filepath.Join(t.TempDir(), "missing.backup"), filepath.Join(t.TempDir(), "missing.backup"))
+	assert.Error(t, err)
+	assert.False(t, isRetryable(err))
+	assert.True(t, isRetryable(fmt.Errorf("Couldn't copy file. Here's why: %w", os.ErrDeadlineExceeded)))
+}
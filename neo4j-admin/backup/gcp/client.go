@@ -4,21 +4,34 @@ import (
 	"cloud.google.com/go/storage"
 	"context"
 	"fmt"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/common"
 	"google.golang.org/api/option"
-	"log"
+	htransport "google.golang.org/api/transport/http"
+	"log/slog"
+	"net/http"
 )
 
+// gcpClient implements common.StorageBackend
+var _ common.StorageBackend = &gcpClient{}
+
 type gcpClient struct {
 	storageClient *storage.Client
+	// httpClient and uploadEndpoint are used for the resumable uploads which are not exposed by the storage client
+	httpClient     *http.Client
+	uploadEndpoint string
 }
 
+// defaultUploadEndpoint is the gcs json api endpoint of the uploads
+const defaultUploadEndpoint = "https://storage.googleapis.com/upload/storage/v1"
+
 func NewGCPClient(credentialPath string) (*gcpClient, error) {
 	ctx := context.Background()
 	var client *storage.Client
 	var err error
 
+	httpOptions := []option.ClientOption{option.WithScopes(storage.ScopeReadWrite)}
 	if credentialPath == "/credentials/" {
-		log.Printf("Credential Path is %s", credentialPath)
+		slog.Debug("Using gcp credentials", "credential_path", credentialPath)
 		client, err = storage.NewClient(ctx)
 		if err != nil {
 			return nil, fmt.Errorf("Unable to create gcs storage client . Here's why: %v", err)
@@ -28,9 +41,16 @@ func NewGCPClient(credentialPath string) (*gcpClient, error) {
 		if err != nil {
 			return nil, fmt.Errorf("Unable to create gcs storage client with credentials file. Here's why: %v", err)
 		}
+		httpOptions = append(httpOptions, option.WithCredentialsFile(credentialPath))
+	}
+	httpClient, _, err := htransport.NewClient(ctx, httpOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to create gcs http client. Here's why: %v", err)
 	}
 
 	return &gcpClient{
-		storageClient: client,
+		storageClient:  client,
+		httpClient:     httpClient,
+		uploadEndpoint: defaultUploadEndpoint,
 	}, nil
 }
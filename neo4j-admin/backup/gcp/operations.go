@@ -5,22 +5,26 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/common"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/logging"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/retry"
+	"google.golang.org/api/googleapi"
 	"google.golang.org/api/iterator"
 	"io"
-	"log"
+	"log/slog"
 	"os"
+	"path"
+	"slices"
 	"strings"
 )
 
-// CheckBucketAccess checks if the given bucket name is accessible or not
-func (g *gcpClient) CheckBucketAccess(bucketName string) error {
+// CheckAccess checks if the given bucket name is accessible or not
+func (g *gcpClient) CheckAccess(bucketName string) error {
 
 	ctx := context.Background()
+	parentBucketName, prefix := common.SplitBucketName(bucketName)
 
-	if strings.Contains(bucketName, "/") {
-		index := strings.Index(bucketName, "/")
-		parentBucketName := bucketName[:index]
-		prefix := bucketName[index+1:]
+	if prefix != "" {
 		query := &storage.Query{
 			Prefix: prefix,
 		}
@@ -53,7 +57,7 @@ func (g *gcpClient) CheckBucketAccess(bucketName string) error {
 			return fmt.Errorf("BucketName provided '%s' not matching with the name retrieved '%s'", bucketName, bucketAttrs.Name)
 		}
 	}
-	log.Printf("Connectivity with bucket %s established", bucketName)
+	slog.Info("Connectivity with gcs bucket established", "bucket", bucketName)
 
 	return nil
 }
@@ -61,47 +65,204 @@ func (g *gcpClient) CheckBucketAccess(bucketName string) error {
 // UploadFile uploads the file present at the provided location to the gcs bucket
 func (g *gcpClient) UploadFile(fileNames []string, bucketName string) error {
 
-	prefix := ""
-	parentBucketName := bucketName
+	//location := "/backups"
+	location := os.Getenv("LOCATION")
+	for _, fileName := range fileNames {
+
+		filePath := fmt.Sprintf("%s/%s", location, fileName)
+		checksums, err := common.FileChecksums(filePath)
+		if err != nil {
+			return err
+		}
+		options, err := common.UploadOptionsFromEnv()
+		if err != nil {
+			return err
+		}
+		//use a resumable upload if file size is more than the upload part size
+		err = retry.Do("upload", isRetryable, func() error {
+			if options.IsMultipart(checksums.Size) {
+				return g.resumableUpload(fileName, location, bucketName, checksums, options.PartSize)
+			}
+			return g.uploadFile(fileName, location, bucketName, checksums)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// uploadFile uploads a single file. gcs rejects the upload if the received contents do not match the provided CRC32C and MD5 checksums
+func (g *gcpClient) uploadFile(fileName string, location string, bucketName string, checksums common.Checksums) error {
+	filePath := fmt.Sprintf("%s/%s", location, fileName)
 	// if bucketName is demo/test/test2
 	// parentBucketName will be "demo"
-	if strings.Contains(bucketName, "/") {
-		index := strings.Index(bucketName, "/")
-		parentBucketName = bucketName[:index]
-		prefix = bucketName[index+1:]
+	parentBucketName, prefix := common.SplitBucketName(bucketName)
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return retry.Permanent(fmt.Errorf("Couldn't open file %v to upload. Here's why: %w\n", filePath, err))
 	}
-	//location := "/backups"
+	defer file.Close()
+
+	logger := logging.ForFile(fileName)
+	logger.Info("Starting upload of file", "path", filePath)
+	// create a new object handle
+	object := g.storageClient.Bucket(parentBucketName).Object(common.ObjectName(prefix, fileName))
+
+	// create a new writer for the object
+	// cancelling the context aborts the upload if copying the file fails
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	writer := object.NewWriter(ctx)
+	writer.CRC32C = checksums.CRC32C
+	writer.SendCRC32C = true
+	writer.MD5 = checksums.MD5
+	if writer.Metadata, err = common.ObjectMetadata(location, fileName, checksums); err != nil {
+		return retry.Permanent(err)
+	}
+	if err = applyUploadOptions(&writer.ObjectAttrs); err != nil {
+		return retry.Permanent(err)
+	}
+
+	// copy the file contents to the object writer
+	if _, err = io.Copy(writer, file); err != nil {
+		return fmt.Errorf("Error writing file to gcs bucket %s\n Here's why: %w", bucketName, err)
+	}
+
+	// close the object writer
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("Error closing writer while uploading file %s to gcs bucket %s \n Here's why: %w", fileName, bucketName, err)
+	}
+	if attrs := writer.Attrs(); attrs.Size != checksums.Size || attrs.CRC32C != checksums.CRC32C {
+		return fmt.Errorf("Checksum mismatch for file %s uploaded to gcs bucket %s. Local size = %d , CRC32C = %d , Remote size = %d , CRC32C = %d", fileName, bucketName, checksums.Size, checksums.CRC32C, attrs.Size, attrs.CRC32C)
+	}
+	logger.Info("File uploaded to gcs bucket", "bucket", bucketName, "size", checksums.Size)
+	return nil
+}
+
+// DownloadFile downloads the provided files from the gcs bucket to the location
+func (g *gcpClient) DownloadFile(fileNames []string, bucketName string) error {
+
+	parentBucketName, prefix := common.SplitBucketName(bucketName)
 	location := os.Getenv("LOCATION")
 	for _, fileName := range fileNames {
 
+		name := common.ObjectName(prefix, fileName)
 		filePath := fmt.Sprintf("%s/%s", location, fileName)
-		file, err := os.Open(filePath)
+		file, err := os.Create(filePath)
 		if err != nil {
-			return fmt.Errorf("Couldn't open file %v to upload. Here's why: %v\n", filePath, err)
+			return fmt.Errorf("Couldn't create file %v to download. Here's why: %v\n", filePath, err)
 		}
 
-		log.Printf("Starting upload of file %s", filePath)
-		name := fileName
-		if prefix != "" {
-			name = fmt.Sprintf("%s/%s", prefix, fileName)
+		logger := logging.ForFile(fileName)
+		logger.Info("Starting download of file", "object", name)
+		reader, err := g.storageClient.Bucket(parentBucketName).Object(name).NewReader(context.Background())
+		if err != nil {
+			file.Close()
+			return fmt.Errorf("Error reading file %s from gcs bucket %s\n Here's why: %v", fileName, bucketName, err)
 		}
-		// create a new object handle
-		object := g.storageClient.Bucket(parentBucketName).Object(name)
+		_, err = io.Copy(file, reader)
+		reader.Close()
+		file.Close()
+		if err != nil {
+			return fmt.Errorf("Error downloading file %s from gcs bucket %s\n Here's why: %v", fileName, bucketName, err)
+		}
+		logger.Info("File downloaded from gcs bucket", "bucket", bucketName, "path", filePath)
+	}
+	return nil
+}
 
-		// create a new writer for the object
-		writer := object.NewWriter(context.Background())
+// ListFiles returns the files present directly under the prefix of the provided gcs bucket
+func (g *gcpClient) ListFiles(bucketName string) ([]common.FileInfo, error) {
+
+	parentBucketName, prefix := common.SplitBucketName(bucketName)
+	query := &storage.Query{
+		Delimiter: "/",
+	}
+	if prefix != "" {
+		query.Prefix = fmt.Sprintf("%s/", prefix)
+	}
 
-		// copy the file contents to the object writer
-		if _, err = io.Copy(writer, file); err != nil {
-			return fmt.Errorf("Error writing file to gcs bucket %s\n Here's why: %v", bucketName, err)
+	var files []common.FileInfo
+	objects := g.storageClient.Bucket(parentBucketName).Objects(context.Background(), query)
+	for {
+		attrs, err := objects.Next()
+		if errors.Is(err, iterator.Done) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Unable to list objects in gcs bucket %s \n Here's why: %v", bucketName, err)
+		}
+		// prefixes (sub directories) are returned with an empty name
+		if attrs.Name == "" || attrs.Name == query.Prefix {
+			continue
 		}
+		files = append(files, common.FileInfo{
+			Name:         path.Base(attrs.Name),
+			Size:         attrs.Size,
+			LastModified: attrs.Updated,
+		})
+	}
+	return files, nil
+}
 
-		// close the object writer
-		if err := writer.Close(); err != nil {
-			return fmt.Errorf("Error closing writer while uploading file %s to gcs bucket %s \n Here's why: %v", fileName, bucketName, err)
+// DeleteFiles deletes the provided files from the gcs bucket
+func (g *gcpClient) DeleteFiles(fileNames []string, bucketName string) error {
+
+	parentBucketName, prefix := common.SplitBucketName(bucketName)
+	for _, fileName := range fileNames {
+		if err := g.storageClient.Bucket(parentBucketName).Object(common.ObjectName(prefix, fileName)).Delete(context.Background()); err != nil {
+			return fmt.Errorf("Couldn't delete file %s from gcs bucket %s \n Here's why: %v", fileName, bucketName, err)
 		}
-		log.Printf("File %s uploaded to GCS bucket %s !!", fileName, bucketName)
-		file.Close()
+		logging.ForFile(fileName).Info("File deleted from gcs bucket", "bucket", bucketName)
 	}
 	return nil
 }
+
+// StatFile returns the info of the provided file present in the gcs bucket
+func (g *gcpClient) StatFile(fileName string, bucketName string) (common.FileInfo, error) {
+
+	parentBucketName, prefix := common.SplitBucketName(bucketName)
+	attrs, err := g.storageClient.Bucket(parentBucketName).Object(common.ObjectName(prefix, fileName)).Attrs(context.Background())
+	if err != nil {
+		return common.FileInfo{}, fmt.Errorf("Couldn't get info of file %s from gcs bucket %s \n Here's why: %v", fileName, bucketName, err)
+	}
+	return common.FileInfo{
+		Name:         fileName,
+		Size:         attrs.Size,
+		LastModified: attrs.Updated,
+		Metadata:     common.NormalizeMetadata(attrs.Metadata),
+		// gcs computes the CRC32C checksum of every object it stores
+		CRC32C: common.Checksums{CRC32C: attrs.CRC32C}.CRC32CBase64(),
+	}, nil
+}
+
+// storageClasses are the storage classes supported by gcs
+var storageClasses = []string{"STANDARD", "NEARLINE", "COLDLINE", "ARCHIVE"}
+
+// applyUploadOptions sets the storage class (STORAGE_CLASS) and the CMEK key name (KMS_KEY_NAME) on the uploaded object
+// The bucket defaults are used when they are not set
+func applyUploadOptions(attrs *storage.ObjectAttrs) error {
+	if value := strings.TrimSpace(os.Getenv("STORAGE_CLASS")); value != "" {
+		if !slices.Contains(storageClasses, strings.ToUpper(value)) {
+			return fmt.Errorf("Invalid gcs storage class %s. Allowed values are %v", value, storageClasses)
+		}
+		attrs.StorageClass = strings.ToUpper(value)
+	}
+	// ex: projects/my-project/locations/us/keyRings/my-ring/cryptoKeys/my-key
+	if value := strings.TrimSpace(os.Getenv("KMS_KEY_NAME")); value != "" {
+		attrs.KMSKeyName = value
+	}
+	return nil
+}
+
+// isRetryable classifies the errors returned by gcs. Requests rejected by gcs are only retried for timeouts , throttling
+// and server errors while errors without a http response (ex: connection reset) are always retried
+func isRetryable(err error) bool {
+	var apiError *googleapi.Error
+	if errors.As(err, &apiError) {
+		return retry.IsRetryableStatusCode(apiError.Code)
+	}
+	return !errors.Is(err, storage.ErrBucketNotExist) && !errors.Is(err, storage.ErrObjectNotExist)
+}
@@ -1,8 +1,12 @@
 package aws
 
 import (
+	"cloud.google.com/go/storage"
+	"errors"
 	"fmt"
 	"github.com/stretchr/testify/assert"
+	"google.golang.org/api/googleapi"
+	"net/http"
 	"os"
 	"testing"
 )
@@ -47,8 +51,8 @@ func TestCheckBucketAccessForGCP(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 
-			if err := client.CheckBucketAccess(tt.bucketName); (err != nil) != tt.wantErr {
-				t.Errorf("CheckBucketAccess() error = %v, wantErr %v", err, tt.wantErr)
+			if err := client.CheckAccess(tt.bucketName); (err != nil) != tt.wantErr {
+				t.Errorf("CheckAccess() error = %v, wantErr %v", err, tt.wantErr)
 			}
 		})
 	}
@@ -96,3 +100,26 @@ func TestUploadFileForGCP(t *testing.T) {
 		})
 	}
 }
+
+func TestApplyUploadOptionsForGCP(t *testing.T) {
+	t.Setenv("STORAGE_CLASS", "nearline")
+	t.Setenv("KMS_KEY_NAME", "projects/demo/locations/us/keyRings/demo/cryptoKeys/demo")
+	attrs := &storage.ObjectAttrs{}
+	assert.NoError(t, applyUploadOptions(attrs))
+	assert.Equal(t, "NEARLINE", attrs.StorageClass)
+	assert.Equal(t, "projects/demo/locations/us/keyRings/demo/cryptoKeys/demo", attrs.KMSKeyName)
+
+	t.Setenv("STORAGE_CLASS", "GLACIER")
+	assert.Error(t, applyUploadOptions(&storage.ObjectAttrs{}))
+}
+
+func TestIsRetryableForGCP(t *testing.T) {
+	apiError := func(code int) error {
+		return fmt.Errorf("Error closing writer. Here's why: %w", &googleapi.Error{Code: code})
+	}
+	assert.True(t, isRetryable(apiError(http.StatusBadGateway)))
+	assert.True(t, isRetryable(apiError(http.StatusRequestTimeout)))
+	assert.False(t, isRetryable(apiError(http.StatusForbidden)))
+	assert.False(t, isRetryable(storage.ErrBucketNotExist))
+	assert.True(t, isRetryable(errors.New("connection reset by peer")))
+}
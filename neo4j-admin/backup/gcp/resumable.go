@@ -0,0 +1,194 @@
+package aws
+
+import (
+	"bytes"
+	"cloud.google.com/go/storage"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/common"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/logging"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/retry"
+	"google.golang.org/api/googleapi"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+)
+
+// resumableObject is the object resource sent when starting a resumable upload session
+// gcs rejects the upload if the received contents do not match the provided CRC32C and MD5 checksums
+type resumableObject struct {
+	Name         string            `json:"name"`
+	StorageClass string            `json:"storageClass,omitempty"`
+	Metadata     map[string]string `json:"metadata,omitempty"`
+	CRC32C       string            `json:"crc32c"`
+	MD5Hash      string            `json:"md5Hash"`
+}
+
+// resumableUpload uploads the file in chunks of partSize bytes using a gcs resumable upload session
+// The session uri is persisted under the location so that a retry continues from the last chunk persisted by gcs
+func (g *gcpClient) resumableUpload(fileName string, location string, bucketName string, checksums common.Checksums, partSize int64) error {
+	filePath := fmt.Sprintf("%s/%s", location, fileName)
+	parentBucketName, prefix := common.SplitBucketName(bucketName)
+	name := common.ObjectName(prefix, fileName)
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return retry.Permanent(fmt.Errorf("Couldn't open file %v to upload. Here's why: %w\n", filePath, err))
+	}
+	defer file.Close()
+
+	logger := logging.ForFile(fileName)
+	sessionURI, offset, err := g.resumeSession(location, fileName, bucketName, name, checksums, partSize)
+	if err != nil {
+		return err
+	}
+	if sessionURI == "" {
+		logger.Info("Starting resumable upload of file", "path", filePath, "object", name)
+		sessionURI, err = g.startSession(location, fileName, parentBucketName, name, checksums)
+		if err != nil {
+			return err
+		}
+		state := &common.UploadState{Bucket: bucketName, Key: name, SHA256: checksums.SHA256Hex(), PartSize: partSize, SessionURI: sessionURI}
+		if err = common.SaveUploadState(location, fileName, state); err != nil {
+			logger.Warn("Unable to persist upload state , the upload cannot be resumed", "error", err)
+		}
+	} else {
+		logger.Info("Resuming upload of file", "path", filePath, "object", name, "offset", offset)
+	}
+
+	for offset < checksums.Size {
+		end := min(offset+partSize, checksums.Size)
+		request, err := http.NewRequest(http.MethodPut, sessionURI, io.NewSectionReader(file, offset, end-offset))
+		if err != nil {
+			return retry.Permanent(err)
+		}
+		request.ContentLength = end - offset
+		request.Header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", offset, end-1, checksums.Size))
+		if offset, err = g.sessionOffset(request, checksums.Size); err != nil {
+			return fmt.Errorf("Error writing file %s to gcs bucket %s\n Here's why: %w", fileName, bucketName, err)
+		}
+		logger.Debug("Chunk uploaded", "offset", offset, "size", checksums.Size)
+	}
+	if err = common.RemoveUploadState(location, fileName); err != nil {
+		logger.Warn("Unable to remove upload state", "error", err)
+	}
+
+	attrs, err := g.storageClient.Bucket(parentBucketName).Object(name).Attrs(context.Background())
+	if err != nil {
+		return fmt.Errorf("Couldn't get info of file %s from gcs bucket %s \n Here's why: %w", fileName, bucketName, err)
+	}
+	if attrs.Size != checksums.Size || attrs.CRC32C != checksums.CRC32C {
+		return fmt.Errorf("Checksum mismatch for file %s uploaded to gcs bucket %s. Local size = %d , CRC32C = %d , Remote size = %d , CRC32C = %d", fileName, bucketName, checksums.Size, checksums.CRC32C, attrs.Size, attrs.CRC32C)
+	}
+	logger.Info("File uploaded to gcs bucket", "bucket", bucketName, "size", checksums.Size)
+	return nil
+}
+
+// startSession starts a resumable upload session of the object with the storage class and encryption options and returns the session uri
+func (g *gcpClient) startSession(location string, fileName string, parentBucketName string, name string, checksums common.Checksums) (string, error) {
+	attrs := &storage.ObjectAttrs{}
+	if err := applyUploadOptions(attrs); err != nil {
+		return "", retry.Permanent(err)
+	}
+	metadata, err := common.ObjectMetadata(location, fileName, checksums)
+	if err != nil {
+		return "", retry.Permanent(err)
+	}
+	body, err := json.Marshal(resumableObject{
+		Name:         name,
+		StorageClass: attrs.StorageClass,
+		Metadata:     metadata,
+		CRC32C:       checksums.CRC32CBase64(),
+		MD5Hash:      checksums.MD5Base64(),
+	})
+	if err != nil {
+		return "", retry.Permanent(err)
+	}
+
+	query := url.Values{"uploadType": {"resumable"}, "name": {name}}
+	if attrs.KMSKeyName != "" {
+		query.Set("kmsKeyName", attrs.KMSKeyName)
+	}
+	endpoint := fmt.Sprintf("%s/b/%s/o?%s", g.uploadEndpoint, url.PathEscape(parentBucketName), query.Encode())
+	request, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return "", retry.Permanent(err)
+	}
+	request.Header.Set("Content-Type", "application/json; charset=UTF-8")
+	request.Header.Set("X-Upload-Content-Type", "application/octet-stream")
+	request.Header.Set("X-Upload-Content-Length", strconv.FormatInt(checksums.Size, 10))
+	response, err := g.httpClient.Do(request)
+	if err != nil {
+		return "", fmt.Errorf("Couldn't start resumable upload of %s to gcs bucket %s \n Here's why: %w", name, parentBucketName, err)
+	}
+	defer response.Body.Close()
+	if err = googleapi.CheckResponse(response); err != nil {
+		return "", fmt.Errorf("Couldn't start resumable upload of %s to gcs bucket %s \n Here's why: %w", name, parentBucketName, err)
+	}
+	sessionURI := response.Header.Get("Location")
+	if sessionURI == "" {
+		return "", fmt.Errorf("Couldn't start resumable upload of %s to gcs bucket %s \n Here's why: missing session uri", name, parentBucketName)
+	}
+	return sessionURI, nil
+}
+
+// resumeSession returns the session uri persisted for the file and the offset persisted by gcs
+// An empty uri is returned when there is nothing to resume or the session expired
+// Sessions of a previous version of the file are left to expire since they do not create any object
+func (g *gcpClient) resumeSession(location string, fileName string, bucketName string, name string, checksums common.Checksums, partSize int64) (string, int64, error) {
+	logger := logging.ForFile(fileName)
+	state, err := common.LoadUploadState(location, fileName)
+	if err != nil {
+		logger.Warn("Ignoring upload state", "error", err)
+		return "", 0, nil
+	}
+	if !state.Matches(bucketName, name, checksums.SHA256Hex(), partSize) || state.SessionURI == "" {
+		return "", 0, nil
+	}
+
+	// an empty PUT with an unknown range returns the persisted range of the session
+	request, err := http.NewRequest(http.MethodPut, state.SessionURI, nil)
+	if err != nil {
+		return "", 0, retry.Permanent(err)
+	}
+	request.Header.Set("Content-Range", fmt.Sprintf("bytes */%d", checksums.Size))
+	offset, err := g.sessionOffset(request, checksums.Size)
+	if err != nil {
+		var apiError *googleapi.Error
+		if errors.As(err, &apiError) && (apiError.Code == http.StatusNotFound || apiError.Code == http.StatusGone) {
+			logger.Warn("Resumable upload session expired , restarting the upload")
+			return "", 0, nil
+		}
+		return "", 0, fmt.Errorf("Couldn't query resumable upload of file %s. Here's why: %w", fileName, err)
+	}
+	return state.SessionURI, offset, nil
+}
+
+// sessionOffset sends the request to the upload session and returns the offset persisted by gcs i.e. the size once the upload completed
+func (g *gcpClient) sessionOffset(request *http.Request, size int64) (int64, error) {
+	response, err := g.httpClient.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+	switch response.StatusCode {
+	case http.StatusOK, http.StatusCreated:
+		return size, nil
+	case http.StatusPermanentRedirect:
+		// 308 Resume Incomplete. The Range header (ex: bytes=0-42) is missing when nothing was persisted yet
+		persisted := response.Header.Get("Range")
+		if persisted == "" {
+			return 0, nil
+		}
+		var first, last int64
+		if _, err = fmt.Sscanf(persisted, "bytes=%d-%d", &first, &last); err != nil {
+			return 0, fmt.Errorf("unexpected Range header %s. Here's why: %v", persisted, err)
+		}
+		return last + 1, nil
+	}
+	return 0, googleapi.CheckResponse(response)
+}
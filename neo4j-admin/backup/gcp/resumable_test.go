@@ -0,0 +1,116 @@
+package aws
+
+import (
+	"bytes"
+	"cloud.google.com/go/storage"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/common"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/api/option"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// resumableStandIn is a http server standing in for the gcs resumable upload and object metadata endpoints
+type resumableStandIn struct {
+	server        *httptest.Server
+	data          []byte
+	size          int64
+	sessions      int
+	receivedBytes int
+	failChunk     int
+	chunks        int
+}
+
+func newResumableStandIn(t *testing.T) *resumableStandIn {
+	standIn := &resumableStandIn{}
+	standIn.server = httptest.NewServer(http.HandlerFunc(standIn.handle))
+	t.Cleanup(standIn.server.Close)
+	return standIn
+}
+
+func (s *resumableStandIn) handle(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == http.MethodPost && strings.HasPrefix(r.URL.Path, "/upload/storage/v1/b/demo/o"):
+		fmt.Sscanf(r.Header.Get("X-Upload-Content-Length"), "%d", &s.size)
+		s.sessions++
+		w.Header().Set("Location", s.server.URL+"/session")
+		w.WriteHeader(http.StatusOK)
+	case r.Method == http.MethodPut && r.URL.Path == "/session":
+		body, _ := io.ReadAll(r.Body)
+		s.receivedBytes += len(body)
+		if !strings.HasPrefix(r.Header.Get("Content-Range"), "bytes */") {
+			s.chunks++
+			if s.chunks == s.failChunk {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			s.data = append(s.data, body...)
+		}
+		if int64(len(s.data)) == s.size {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		if len(s.data) > 0 {
+			w.Header().Set("Range", fmt.Sprintf("bytes=0-%d", len(s.data)-1))
+		}
+		w.WriteHeader(http.StatusPermanentRedirect)
+	case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/storage/v1/b/demo/o/"):
+		checksums, _ := common.ComputeReaderChecksums(bytes.NewReader(s.data))
+		crc32c := make([]byte, 4)
+		binary.BigEndian.PutUint32(crc32c, checksums.CRC32C)
+		json.NewEncoder(w).Encode(map[string]string{
+			"bucket": "demo",
+			"name":   strings.TrimPrefix(r.URL.Path, "/storage/v1/b/demo/o/"),
+			"size":   fmt.Sprintf("%d", len(s.data)),
+			"crc32c": base64.StdEncoding.EncodeToString(crc32c),
+		})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestResumableUploadForGCP(t *testing.T) {
+	standIn := newResumableStandIn(t)
+	storageClient, err := storage.NewClient(context.Background(), option.WithEndpoint(standIn.server.URL+"/storage/v1/"), option.WithoutAuthentication())
+	assert.NoError(t, err)
+	client := &gcpClient{storageClient: storageClient, httpClient: standIn.server.Client(), uploadEndpoint: standIn.server.URL + "/upload/storage/v1"}
+
+	location := t.TempDir()
+	fileName := "neo4j-2024-03-10T10-00-00.backup"
+	content := make([]byte, 12*1024*1024)
+	_, err = rand.Read(content)
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(filepath.Join(location, fileName), content, 0644))
+	checksums, err := common.ComputeChecksums(filepath.Join(location, fileName))
+	assert.NoError(t, err)
+	partSize := int64(5 * 1024 * 1024)
+
+	// the second chunk fails , the session is persisted and the retry continues after the first chunk
+	standIn.failChunk = 2
+	err = client.resumableUpload(fileName, location, "demo/test", checksums, partSize)
+	assert.Error(t, err)
+	assert.True(t, isRetryable(err))
+	state, err := common.LoadUploadState(location, fileName)
+	assert.NoError(t, err)
+	assert.Equal(t, standIn.server.URL+"/session", state.SessionURI)
+	assert.Equal(t, "test/"+fileName, state.Key)
+
+	assert.NoError(t, client.resumableUpload(fileName, location, "demo/test", checksums, partSize))
+	assert.Equal(t, 1, standIn.sessions, "the upload should have been resumed instead of restarted")
+	assert.True(t, bytes.Equal(content, standIn.data))
+	assert.Equal(t, len(content)+int(partSize), standIn.receivedBytes, "only the failed chunk should have been sent twice")
+	state, err = common.LoadUploadState(location, fileName)
+	assert.NoError(t, err)
+	assert.Nil(t, state, "the upload state should be removed once the upload completed")
+}
@@ -0,0 +1,197 @@
+package hooks
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/notification"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Stages of the backup job at which a hook runs
+const (
+	// StagePreBackup runs once the backup server is reachable and before any database is backed up ex: pause ingestion
+	StagePreBackup = "pre_backup"
+	// StagePostUpload runs once the backup files are uploaded , or kept at /backups without cloud provider , and the
+	// retention policy is applied ex: trigger a downstream copy
+	StagePostUpload = "post_upload"
+	// StageOnFailure runs when the run fails at any step ex: resume the ingestion paused by the pre backup hook
+	StageOnFailure = "on_failure"
+)
+
+// Failure policies of a hook
+const (
+	// PolicyFail fails the run when the hook fails
+	PolicyFail = "fail"
+	// PolicyIgnore logs the failure of the hook and carries on with the run
+	PolicyIgnore = "ignore"
+)
+
+// DefaultTimeout is the time a hook is given to complete when HOOK_TIMEOUT is not set
+const DefaultTimeout = 5 * time.Minute
+
+// waitDelay is the time given to the output of a command to be closed once the command exited or was killed
+const waitDelay = 5 * time.Second
+
+// maxResponseSize is the number of bytes of the response of a http hook written to the run log
+const maxResponseSize = 4096
+
+// Hook is the command and / or http call run at a stage of the backup job
+type Hook struct {
+	Stage string
+	// Command is run with /bin/sh -c along with the environment of the job and the HOOK_* variables describing the run
+	Command string
+	// URL is called with a POST request whose json body is the Event
+	URL     string
+	Policy  string
+	Timeout time.Duration
+}
+
+// Event describes the run to the hook
+type Event struct {
+	Stage   string               `json:"stage"`
+	RunID   string               `json:"runId"`
+	Summary *notification.Result `json:"summary"`
+}
+
+// httpClient has no timeout of its own since every call is bound by the timeout of the hook
+var httpClient = &http.Client{}
+
+// FromEnv returns the hook of the stage configured via HOOK_<STAGE>_COMMAND , HOOK_<STAGE>_URL and HOOK_<STAGE>_FAILURE_POLICY
+// ex: HOOK_PRE_BACKUP_COMMAND. nil is returned when neither a command nor a url is configured
+// The failure policy defaults to fail and is always ignore for the failure hook since the run already failed
+func FromEnv(stage string) (*Hook, error) {
+	prefix := fmt.Sprintf("HOOK_%s_", strings.ToUpper(stage))
+	hook := &Hook{
+		Stage:   stage,
+		Command: strings.TrimSpace(os.Getenv(prefix + "COMMAND")),
+		URL:     strings.TrimSpace(os.Getenv(prefix + "URL")),
+		Policy:  strings.TrimSpace(os.Getenv(prefix + "FAILURE_POLICY")),
+	}
+	if hook.Command == "" && hook.URL == "" {
+		return nil, nil
+	}
+	switch {
+	case stage == StageOnFailure:
+		hook.Policy = PolicyIgnore
+	case hook.Policy == "":
+		hook.Policy = PolicyFail
+	case hook.Policy != PolicyFail && hook.Policy != PolicyIgnore:
+		return nil, fmt.Errorf("invalid %sFAILURE_POLICY %s. Allowed values are fail and ignore", prefix, hook.Policy)
+	}
+	if hook.URL != "" && !strings.HasPrefix(hook.URL, "http://") && !strings.HasPrefix(hook.URL, "https://") {
+		return nil, fmt.Errorf("invalid %sURL %s. Value should be a http or https url", prefix, hook.URL)
+	}
+	timeout, err := timeoutFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	hook.Timeout = timeout
+	return hook, nil
+}
+
+// timeoutFromEnv returns the timeout of every hook as per HOOK_TIMEOUT ex: 30s
+func timeoutFromEnv() (time.Duration, error) {
+	value := strings.TrimSpace(os.Getenv("HOOK_TIMEOUT"))
+	if value == "" {
+		return DefaultTimeout, nil
+	}
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout <= 0 {
+		return 0, fmt.Errorf("invalid HOOK_TIMEOUT %s. Value should be a positive duration ex: 30s", value)
+	}
+	return timeout, nil
+}
+
+// Run runs the command followed by the http call of the hook. The output of the command and the response of the http call
+// are written to the run log. The http call is skipped when the command fails
+func (h *Hook) Run(event Event) error {
+	logger := slog.With("hook", h.Stage)
+	ctx, cancel := context.WithTimeout(context.Background(), h.Timeout)
+	defer cancel()
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	start := time.Now()
+	if h.Command != "" {
+		logger.Info("Running hook command", "command", h.Command)
+		if err = h.runCommand(ctx, logger, event, body); err != nil {
+			return err
+		}
+	}
+	if h.URL != "" {
+		logger.Info("Calling hook url", "url", h.URL)
+		if err = h.call(ctx, logger, body); err != nil {
+			return err
+		}
+	}
+	logger.Info("Hook completed", "duration", time.Since(start).String())
+	return nil
+}
+
+// runCommand runs the command with the event in the HOOK_EVENT variable and logs every line of its output
+func (h *Hook) runCommand(ctx context.Context, logger *slog.Logger, event Event, body []byte) error {
+	command := exec.CommandContext(ctx, "/bin/sh", "-c", h.Command)
+	command.Env = append(os.Environ(),
+		fmt.Sprintf("HOOK_STAGE=%s", h.Stage),
+		fmt.Sprintf("HOOK_RUN_ID=%s", event.RunID),
+		fmt.Sprintf("HOOK_EVENT=%s", body),
+	)
+	if event.Summary != nil {
+		command.Env = append(command.Env,
+			fmt.Sprintf("HOOK_DATABASES=%s", strings.Join(event.Summary.Databases, ",")),
+			fmt.Sprintf("HOOK_FILES=%s", strings.Join(event.Summary.Files, ",")),
+		)
+	}
+	// the command runs in its own process group so that the processes it started are killed along with it on timeout
+	command.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	command.Cancel = func() error {
+		return syscall.Kill(-command.Process.Pid, syscall.SIGKILL)
+	}
+	// processes started in the background by the command would otherwise keep the output open past the timeout
+	command.WaitDelay = waitDelay
+	output, err := command.CombinedOutput()
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		logger.Info("Hook output", "line", scanner.Text())
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%s hook command timed out after %s", h.Stage, h.Timeout)
+	}
+	if err != nil {
+		return fmt.Errorf("%s hook command failed \n Here's why: %v", h.Stage, err)
+	}
+	return nil
+}
+
+// call posts the event to the url of the hook and logs the beginning of the response
+func (h *Hook) call(ctx context.Context, logger *slog.Logger, body []byte) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	response, err := httpClient.Do(request)
+	if err != nil {
+		return fmt.Errorf("%s hook call to %s failed \n Here's why: %v", h.Stage, h.URL, err)
+	}
+	defer response.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(response.Body, maxResponseSize))
+	logger.Info("Hook response", "status", response.Status, "body", string(data))
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("%s hook call to %s failed with status %s", h.Stage, h.URL, response.Status)
+	}
+	return nil
+}
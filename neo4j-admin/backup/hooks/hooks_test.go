@@ -0,0 +1,98 @@
+package hooks
+
+import (
+	"encoding/json"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/notification"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFromEnv(t *testing.T) {
+	tests := []struct {
+		name       string
+		stage      string
+		env        map[string]string
+		wantNil    bool
+		wantPolicy string
+		wantErr    string
+	}{
+		{name: "not configured", stage: StagePreBackup, wantNil: true},
+		{name: "default policy", stage: StagePreBackup, env: map[string]string{"HOOK_PRE_BACKUP_COMMAND": "echo pause"}, wantPolicy: PolicyFail},
+		{name: "ignore policy", stage: StagePostUpload, env: map[string]string{"HOOK_POST_UPLOAD_URL": "https://catalog.example.com", "HOOK_POST_UPLOAD_FAILURE_POLICY": "ignore"}, wantPolicy: PolicyIgnore},
+		{name: "failure hook is ignored", stage: StageOnFailure, env: map[string]string{"HOOK_ON_FAILURE_COMMAND": "echo resume", "HOOK_ON_FAILURE_FAILURE_POLICY": "fail"}, wantPolicy: PolicyIgnore},
+		{name: "invalid policy", stage: StagePreBackup, env: map[string]string{"HOOK_PRE_BACKUP_COMMAND": "echo pause", "HOOK_PRE_BACKUP_FAILURE_POLICY": "retry"}, wantErr: "invalid HOOK_PRE_BACKUP_FAILURE_POLICY retry"},
+		{name: "invalid url", stage: StagePostUpload, env: map[string]string{"HOOK_POST_UPLOAD_URL": "catalog.example.com"}, wantErr: "invalid HOOK_POST_UPLOAD_URL"},
+		{name: "invalid timeout", stage: StagePreBackup, env: map[string]string{"HOOK_PRE_BACKUP_COMMAND": "echo pause", "HOOK_TIMEOUT": "10"}, wantErr: "invalid HOOK_TIMEOUT 10"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, name := range []string{"HOOK_TIMEOUT", "HOOK_PRE_BACKUP_COMMAND", "HOOK_PRE_BACKUP_FAILURE_POLICY", "HOOK_POST_UPLOAD_URL",
+				"HOOK_POST_UPLOAD_FAILURE_POLICY", "HOOK_ON_FAILURE_COMMAND", "HOOK_ON_FAILURE_FAILURE_POLICY"} {
+				t.Setenv(name, tt.env[name])
+			}
+			hook, err := FromEnv(tt.stage)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			if tt.wantNil {
+				assert.Nil(t, hook)
+				return
+			}
+			assert.Equal(t, tt.wantPolicy, hook.Policy)
+			assert.Equal(t, DefaultTimeout, hook.Timeout)
+		})
+	}
+}
+
+func TestRunCommand(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "hook.out")
+	event := Event{Stage: StagePostUpload, RunID: "demo", Summary: &notification.Result{Databases: []string{"neo4j", "system"}}}
+
+	hook := &Hook{Stage: StagePostUpload, Command: "echo \"$HOOK_STAGE $HOOK_RUN_ID $HOOK_DATABASES\" > " + output, Timeout: time.Minute}
+	assert.NoError(t, hook.Run(event))
+	data, err := os.ReadFile(output)
+	assert.NoError(t, err)
+	assert.Equal(t, "post_upload demo neo4j,system\n", string(data))
+
+	hook.Command = "echo pausing ingestion ; exit 3"
+	assert.ErrorContains(t, hook.Run(event), "post_upload hook command failed")
+
+	hook.Command, hook.Timeout = "sleep 10", 100*time.Millisecond
+	assert.ErrorContains(t, hook.Run(event), "post_upload hook command timed out after 100ms")
+}
+
+func TestRunURL(t *testing.T) {
+	var got Event
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		assert.NoError(t, json.Unmarshal(data, &got))
+		if got.Stage == StageOnFailure {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	hook := &Hook{Stage: StagePreBackup, URL: server.URL, Timeout: time.Minute}
+	assert.NoError(t, hook.Run(Event{Stage: StagePreBackup, RunID: "demo", Summary: &notification.Result{Operation: "backup"}}))
+	assert.Equal(t, "demo", got.RunID)
+	assert.Equal(t, "backup", got.Summary.Operation)
+
+	hook.Stage = StageOnFailure
+	assert.ErrorContains(t, hook.Run(Event{Stage: StageOnFailure}), "failed with status 500")
+
+	// the url is not called when the command fails
+	got = Event{}
+	hook.Stage, hook.Command = StagePostUpload, "exit 1"
+	assert.Error(t, hook.Run(Event{Stage: StagePostUpload}))
+	assert.Empty(t, got.Stage)
+}
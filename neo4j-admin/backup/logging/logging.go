@@ -0,0 +1,76 @@
+package logging
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/common"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// runID identifies all the log lines of a single run of the backup binary
+var runID string
+
+// Setup configures the default slog logger as per LOG_FORMAT (text or json) and LOG_LEVEL (debug , info , warn or error)
+// Every line carries the run id (RUN_ID or a generated one) and the configured databases
+// The standard log package output is redirected to the same handler
+func Setup() error {
+	return setup(os.Stderr, os.Getenv("LOG_FORMAT"), os.Getenv("LOG_LEVEL"))
+}
+
+func setup(writer io.Writer, format string, level string) error {
+	var logLevel slog.Level
+	if strings.TrimSpace(level) != "" {
+		if err := logLevel.UnmarshalText([]byte(strings.TrimSpace(level))); err != nil {
+			return fmt.Errorf("invalid LOG_LEVEL %s. Allowed values are debug , info , warn and error", level)
+		}
+	}
+	options := &slog.HandlerOptions{Level: logLevel}
+
+	var handler slog.Handler
+	switch strings.ToLower(strings.TrimSpace(format)) {
+	case "", "text":
+		handler = slog.NewTextHandler(writer, options)
+	case "json":
+		handler = slog.NewJSONHandler(writer, options)
+	default:
+		return fmt.Errorf("invalid LOG_FORMAT %s. Allowed values are text and json", format)
+	}
+
+	runID = os.Getenv("RUN_ID")
+	if runID == "" {
+		runID = newRunID()
+	}
+	slog.SetDefault(slog.New(handler).With("run_id", runID, "databases", os.Getenv("DATABASE")))
+	return nil
+}
+
+// RunID returns the id of the current run
+func RunID() string {
+	return runID
+}
+
+// Fatal logs the error and exits with a non zero exit code
+func Fatal(message string, err error, args ...any) {
+	slog.Error(message, append(args, "error", err)...)
+	os.Exit(1)
+}
+
+func newRunID() string {
+	value := make([]byte, 8)
+	if _, err := rand.Read(value); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(value)
+}
+
+// ForFile returns a logger carrying the file name and , for backup files and their reports , the database name
+func ForFile(fileName string) *slog.Logger {
+	if backup, ok := common.ParseBackupFileName(strings.TrimSuffix(fileName, ".report.tar.gz")); ok {
+		return slog.With("file", fileName, "database", backup.Database)
+	}
+	return slog.With("file", fileName)
+}
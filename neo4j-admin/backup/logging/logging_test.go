@@ -0,0 +1,42 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestSetup(t *testing.T) {
+	defaultLogger := slog.Default()
+	defer slog.SetDefault(defaultLogger)
+
+	t.Setenv("RUN_ID", "demo-run")
+	t.Setenv("DATABASE", "neo4j,system")
+
+	var buffer bytes.Buffer
+	assert.NoError(t, setup(&buffer, "json", "info"))
+	ForFile("neo4j-2024-03-10T10-00-00.backup.report.tar.gz").Info("multi line\nmessage", "bucket", "demo")
+	slog.Debug("not logged")
+
+	lines := strings.Split(strings.TrimSpace(buffer.String()), "\n")
+	assert.Len(t, lines, 1, "a multi line message should be logged as a single line")
+	var line map[string]any
+	assert.NoError(t, json.Unmarshal([]byte(lines[0]), &line))
+	assert.Equal(t, "demo-run", line["run_id"])
+	assert.Equal(t, "neo4j,system", line["databases"])
+	assert.Equal(t, "neo4j", line["database"])
+	assert.Equal(t, "demo", line["bucket"])
+	assert.Equal(t, "INFO", line["level"])
+	assert.Equal(t, "demo-run", RunID())
+
+	buffer.Reset()
+	assert.NoError(t, setup(&buffer, "", "debug"))
+	slog.Debug("logged", "file", "demo")
+	assert.Contains(t, buffer.String(), "level=DEBUG msg=logged run_id=demo-run databases=neo4j,system file=demo")
+
+	assert.Error(t, setup(&buffer, "xml", ""))
+	assert.Error(t, setup(&buffer, "json", "verbose"))
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/aws"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/azure"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/common"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/filesystem"
+	gcp "github.com/neo4j/helm-charts/neo4j-admin/backup/gcp"
+	"os"
+)
+
+// backendConstructor creates a storage backend using the credentials present at the provided path
+type backendConstructor func(credentialPath string) (common.StorageBackend, error)
+
+// backends contains the storage backends keyed by the CLOUD_PROVIDER value
+var backends = map[string]backendConstructor{
+	"aws": func(credentialPath string) (common.StorageBackend, error) {
+		client, err := aws.NewAwsClient(credentialPath)
+		if err != nil {
+			return nil, err
+		}
+		return client, nil
+	},
+	"gcp": func(credentialPath string) (common.StorageBackend, error) {
+		client, err := gcp.NewGCPClient(credentialPath)
+		if err != nil {
+			return nil, err
+		}
+		return client, nil
+	},
+	"azure": func(credentialPath string) (common.StorageBackend, error) {
+		client, err := azure.NewAzureClient(credentialPath)
+		if err != nil {
+			return nil, err
+		}
+		return client, nil
+	},
+	// filesystem copies the backup files to the directory mounted at DESTINATION_PATH (ex: an NFS persistent volume)
+	"filesystem": func(credentialPath string) (common.StorageBackend, error) {
+		client, err := filesystem.NewFilesystemClient(os.Getenv("DESTINATION_PATH"))
+		if err != nil {
+			return nil, err
+		}
+		return client, nil
+	},
+}
+
+// localBucketName refers to the /backups directory when used with the local backend
+const localBucketName = "backups"
+
+// newLocalBackend returns a filesystem backend over the root directory to access the /backups directory via localBucketName
+func newLocalBackend() (common.StorageBackend, error) {
+	client, err := filesystem.NewFilesystemClient("/")
+	if err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// newStorageBackend returns the storage backend registered for the provided cloud provider
+// nil is returned when no cloud provider is set i.e. the backup files are only kept at /backups
+func newStorageBackend(cloudProvider string, credentialPath string) (common.StorageBackend, error) {
+	if cloudProvider == "" {
+		return nil, nil
+	}
+	constructor, present := backends[cloudProvider]
+	if !present {
+		return nil, fmt.Errorf("Incorrect cloud provider %s", cloudProvider)
+	}
+	return constructor(credentialPath)
+}
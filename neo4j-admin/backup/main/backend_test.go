@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/common"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/manifest"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+// fakeBackend is an in memory storage backend keyed by bucket name and file name
+type fakeBackend struct {
+	buckets map[string]map[string]common.FileInfo
+	// contents contains the contents of the uploaded files keyed by bucket name and file name
+	contents map[string][]byte
+}
+
+func newFakeBackend(bucketName string, fileNames ...string) *fakeBackend {
+	files := make(map[string]common.FileInfo)
+	for _, fileName := range fileNames {
+		files[fileName] = common.FileInfo{Name: fileName, LastModified: time.Now()}
+	}
+	return &fakeBackend{buckets: map[string]map[string]common.FileInfo{bucketName: files}, contents: make(map[string][]byte)}
+}
+
+func (f *fakeBackend) bucket(bucketName string) (map[string]common.FileInfo, error) {
+	files, present := f.buckets[bucketName]
+	if !present {
+		return nil, fmt.Errorf("bucket %s does not exist", bucketName)
+	}
+	return files, nil
+}
+
+func (f *fakeBackend) CheckAccess(bucketName string) error {
+	_, err := f.bucket(bucketName)
+	return err
+}
+
+func (f *fakeBackend) UploadFile(fileNames []string, bucketName string) error {
+	files, err := f.bucket(bucketName)
+	if err != nil {
+		return err
+	}
+	for _, fileName := range fileNames {
+		metadata, err := common.LoadFileMetadata(os.Getenv("LOCATION"), fileName)
+		if err != nil {
+			return err
+		}
+		if contents, err := os.ReadFile(filepath.Join(os.Getenv("LOCATION"), fileName)); err == nil {
+			f.contents[filepath.Join(bucketName, fileName)] = contents
+		}
+		files[fileName] = common.FileInfo{Name: fileName, LastModified: time.Now(), Metadata: metadata}
+	}
+	return nil
+}
+
+// DownloadFile writes the uploaded contents , or the file name for the files which were not uploaded , as contents of the
+// downloaded files when LOCATION is set
+func (f *fakeBackend) DownloadFile(fileNames []string, bucketName string) error {
+	for _, fileName := range fileNames {
+		if _, err := f.StatFile(fileName, bucketName); err != nil {
+			return err
+		}
+		contents, present := f.contents[filepath.Join(bucketName, fileName)]
+		if !present {
+			contents = []byte(fileName)
+		}
+		if location := os.Getenv("LOCATION"); location != "" {
+			if err := os.WriteFile(filepath.Join(location, fileName), contents, 0644); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (f *fakeBackend) ListFiles(bucketName string) ([]common.FileInfo, error) {
+	files, err := f.bucket(bucketName)
+	if err != nil {
+		return nil, err
+	}
+	var list []common.FileInfo
+	for _, file := range files {
+		list = append(list, file)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+	return list, nil
+}
+
+func (f *fakeBackend) DeleteFiles(fileNames []string, bucketName string) error {
+	files, err := f.bucket(bucketName)
+	if err != nil {
+		return err
+	}
+	for _, fileName := range fileNames {
+		delete(files, fileName)
+	}
+	return nil
+}
+
+func (f *fakeBackend) StatFile(fileName string, bucketName string) (common.FileInfo, error) {
+	files, err := f.bucket(bucketName)
+	if err != nil {
+		return common.FileInfo{}, err
+	}
+	file, present := files[fileName]
+	if !present {
+		return common.FileInfo{}, fmt.Errorf("file %s does not exist in bucket %s", fileName, bucketName)
+	}
+	return file, nil
+}
+
+func (f *fakeBackend) fileNames(bucketName string) []string {
+	files, _ := f.ListFiles(bucketName)
+	var names []string
+	for _, file := range files {
+		names = append(names, file.Name)
+	}
+	return names
+}
+
+func TestNewStorageBackend(t *testing.T) {
+	backend, err := newStorageBackend("", "")
+	assert.NoError(t, err)
+	assert.Nil(t, backend)
+
+	_, err = newStorageBackend("dropbox", "")
+	assert.ErrorContains(t, err, "Incorrect cloud provider dropbox")
+}
+
+func TestApplyRetentionPolicy(t *testing.T) {
+	backend := newFakeBackend("demo/test",
+		"neo4j-2024-03-10T10-00-00.backup",
+		"neo4j-2024-03-09T10-00-00.backup",
+		"neo4j-2024-03-08T10-00-00.backup",
+		"system-2024-03-08T10-00-00.backup",
+		// the report of the consistency check of the 03-08 backup of neo4j which finished after the 03-09 backup started
+		"neo4j-2024-03-09T11-00-00.backup.report.tar.gz",
+		// a manifest which cannot be parsed is kept
+		"backup-manifest-2024-03-07T10-00-00.json",
+	)
+	location := t.TempDir()
+	t.Setenv("LOCATION", location)
+	m := &manifest.Manifest{
+		StartTime: time.Date(2024, 3, 8, 10, 0, 0, 0, time.UTC),
+		Artifacts: []manifest.Artifact{{Name: "neo4j-2024-03-08T10-00-00.backup", Database: "neo4j"}},
+		ConsistencyChecks: []manifest.ConsistencyCheck{
+			{Database: "neo4j", Report: &manifest.Artifact{Name: "neo4j-2024-03-09T11-00-00.backup.report.tar.gz"}},
+		},
+	}
+	manifestFileName, err := m.Write(location)
+	assert.NoError(t, err)
+	assert.NoError(t, backend.UploadFile([]string{manifestFileName}, "demo/test"))
+	t.Setenv("DATABASE", "neo4j")
+	t.Setenv("RETENTION_KEEP_LAST", "2")
+
+	err = applyRetentionPolicy(backend, "demo/test")
+	assert.NoError(t, err)
+	// the consistency check report and the manifest of the deleted backup are deleted along with it
+	assert.Equal(t, []string{
+		"backup-manifest-2024-03-07T10-00-00.json",
+		"neo4j-2024-03-09T10-00-00.backup",
+		"neo4j-2024-03-10T10-00-00.backup",
+		"system-2024-03-08T10-00-00.backup",
+	}, backend.fileNames("demo/test"))
+	// the manifests are downloaded to a temporary directory which is removed afterwards
+	assert.Equal(t, location, os.Getenv("LOCATION"))
+	entries, err := os.ReadDir(location)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/catalog"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/common"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const catalogUsage = `Usage: backup <command> [flags] [backup file name]
+
+Commands describing the backups present in the bucket:
+  list     lists the backups grouped by database , oldest first
+  inspect  shows the details and the chain of a backup ex: backup inspect neo4j-2023-05-04T17-21-27.backup
+  latest   shows the latest good backup , restorable and without inconsistency report , of every database
+
+Run backup <command> -h to list the flags of the command
+The backup job runs the OPERATION (backup , restore or drill) when no command is given`
+
+// catalogOptions are the flags of the catalog commands. The storage flags default to the env variables of the backup job
+type catalogOptions struct {
+	provider       string
+	credentialPath string
+	bucketName     string
+	database       string
+	output         string
+}
+
+// inspection is the json output of the inspect command
+type inspection struct {
+	catalog.Entry
+	// Links are the backups of the chain of the backup , starting with its full backup
+	Links []catalog.Entry `json:"links"`
+}
+
+// runCatalogCommand runs the catalog command named by the first argument and writes its output to stdout
+func runCatalogCommand(args []string, stdout io.Writer, stderr io.Writer) error {
+	command := args[0]
+	if command != "list" && command != "inspect" && command != "latest" {
+		return fmt.Errorf("unknown command %s\n%s", command, catalogUsage)
+	}
+	var options catalogOptions
+	flags := flag.NewFlagSet(command, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.StringVar(&options.provider, "provider", os.Getenv("CLOUD_PROVIDER"), "cloud provider: aws , gcp , azure or filesystem. The backups present at /backups are described when empty")
+	flags.StringVar(&options.bucketName, "bucket", os.Getenv("BUCKET_NAME"), "bucket (container for azure) along with the optional prefix ex: demo/neo4j")
+	flags.StringVar(&options.credentialPath, "credentials", os.Getenv("CREDENTIAL_PATH"), "path of the credentials file of the cloud provider")
+	flags.StringVar(&options.database, "database", "*", "database whose backups are described. * describes all the databases")
+	flags.StringVar(&options.output, "output", "text", "output format: text or json")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+	if options.output != "text" && options.output != "json" {
+		return fmt.Errorf("invalid output %s. Allowed values are text and json", options.output)
+	}
+
+	var fileName string
+	if command == "inspect" {
+		if flags.NArg() != 1 {
+			return fmt.Errorf("inspect expects the name of a backup file ex: backup inspect neo4j-2023-05-04T17-21-27.backup")
+		}
+		fileName = flags.Arg(0)
+		backup, ok := common.ParseBackupFileName(fileName)
+		if !ok {
+			return fmt.Errorf("%s is not a backup file name", fileName)
+		}
+		options.database = backup.Database
+	}
+
+	backend, err := newStorageBackend(options.provider, options.credentialPath)
+	if err != nil {
+		return err
+	}
+	bucketName := options.bucketName
+	if backend == nil {
+		if backend, err = newLocalBackend(); err != nil {
+			return err
+		}
+		bucketName = localBucketName
+	}
+	c, err := buildCatalog(backend, bucketName, options.database)
+	if err != nil {
+		return err
+	}
+
+	switch command {
+	case "list":
+		return printBackups(stdout, c, c.Databases(), false, options.output)
+	case "latest":
+		return printBackups(stdout, c, c.Databases(), true, options.output)
+	}
+	return printInspection(stdout, c, fileName, options.output)
+}
+
+// buildCatalog returns the catalog of the backups of the database present in the bucket
+func buildCatalog(backend common.StorageBackend, bucketName string, database string) (*catalog.Catalog, error) {
+	files, err := backend.ListFiles(bucketName)
+	if err != nil {
+		return nil, err
+	}
+	return catalog.Build(files, backend.StatFile, bucketName, database)
+}
+
+// printBackups writes the backups of the databases , or only the latest good backup of every database when latest is set
+// An error is returned by latest when a database has no good backup so that scripts can rely on the exit status
+func printBackups(writer io.Writer, c *catalog.Catalog, databases []string, latest bool, output string) error {
+	entries := []catalog.Entry{}
+	var missing []string
+	for _, database := range databases {
+		if !latest {
+			entries = append(entries, c.Entries(database)...)
+			continue
+		}
+		entry, ok := c.Latest(database)
+		if !ok {
+			missing = append(missing, database)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	if output == "json" {
+		if err := writeJSON(writer, entries); err != nil {
+			return err
+		}
+	} else {
+		table := tabwriter.NewWriter(writer, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(table, "DATABASE\tBACKUP\tTYPE\tSIZE\tSTATUS\tREPORTS")
+		for _, entry := range entries {
+			fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%s\t%s\n", entry.Database, entry.FileName, entry.Type, formatSize(entry.Size), entry.Status(), strings.Join(entry.Reports, ","))
+		}
+		if err := table.Flush(); err != nil {
+			return err
+		}
+	}
+	if len(missing) != 0 {
+		return fmt.Errorf("no good backup found for database(s) %s", strings.Join(missing, ","))
+	}
+	return nil
+}
+
+// printInspection writes the details of the backup file along with its chain
+func printInspection(writer io.Writer, c *catalog.Catalog, fileName string, output string) error {
+	entry, ok := c.Find(fileName)
+	if !ok {
+		return fmt.Errorf("backup %s not found", fileName)
+	}
+	links := c.Chain(fileName)
+	if output == "json" {
+		return writeJSON(writer, inspection{Entry: entry, Links: links})
+	}
+
+	var chainNames []string
+	for _, link := range links {
+		chainNames = append(chainNames, fmt.Sprintf("%s (%s)", link.FileName, link.Type))
+	}
+	table := tabwriter.NewWriter(writer, 0, 0, 1, ' ', 0)
+	fmt.Fprintf(table, "Backup:\t%s\n", entry.FileName)
+	fmt.Fprintf(table, "Database:\t%s\n", entry.Database)
+	fmt.Fprintf(table, "Created:\t%s\n", entry.Timestamp.Format(time.RFC3339))
+	fmt.Fprintf(table, "Uploaded:\t%s\n", entry.LastModified.UTC().Format(time.RFC3339))
+	fmt.Fprintf(table, "Size:\t%s (%d bytes)\n", formatSize(entry.Size), entry.Size)
+	fmt.Fprintf(table, "SHA-256:\t%s\n", entry.SHA256)
+	fmt.Fprintf(table, "Type:\t%s\n", entry.Type)
+	fmt.Fprintf(table, "Chain:\t%s\n", strings.Join(chainNames, " -> "))
+	fmt.Fprintf(table, "Status:\t%s\n", entry.Status())
+	fmt.Fprintf(table, "Reports:\t%s\n", strings.Join(entry.Reports, ","))
+	return table.Flush()
+}
+
+func writeJSON(writer io.Writer, value interface{}) error {
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
+
+// formatSize returns the size in the largest binary unit ex: 1.5 GiB
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
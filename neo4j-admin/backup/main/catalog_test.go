@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/chain"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/common"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"testing"
+)
+
+func TestCatalogCommands(t *testing.T) {
+	t.Parallel()
+
+	backend := newFakeBackend("demo")
+	files := backend.buckets["demo"]
+	full := chain.Next("neo4j-2024-03-08T02-00-00.backup", "neo4j", "AUTO", nil)
+	diff := chain.Next("neo4j-2024-03-09T02-00-00.backup", "neo4j", "AUTO", &full)
+	for _, link := range []chain.Link{full, diff} {
+		files[link.FileName] = common.FileInfo{Name: link.FileName, Size: 1536, Metadata: link.Metadata()}
+	}
+	files["system-2024-03-09T02-00-00.backup"] = common.FileInfo{Name: "system-2024-03-09T02-00-00.backup", Size: 512}
+	files["system-2024-03-09T02-00-00.backup.drill.report.tar.gz"] = common.FileInfo{Name: "system-2024-03-09T02-00-00.backup.drill.report.tar.gz"}
+
+	c, err := buildCatalog(backend, "demo", "*")
+	assert.NoError(t, err)
+
+	var output bytes.Buffer
+	assert.NoError(t, printBackups(&output, c, c.Databases(), false, "text"))
+	assert.Regexp(t, `neo4j-2024-03-09T02-00-00.backup +DIFF +1.5 KiB +ok`, output.String())
+	assert.Regexp(t, `system-2024-03-09T02-00-00.backup +FULL +512 B +inconsistent +system-2024-03-09T02-00-00.backup.drill.report.tar.gz`, output.String())
+
+	output.Reset()
+	err = printBackups(&output, c, c.Databases(), true, "json")
+	assert.ErrorContains(t, err, "no good backup found for database(s) system")
+	var latest []map[string]interface{}
+	assert.NoError(t, json.Unmarshal(output.Bytes(), &latest))
+	if assert.Len(t, latest, 1) {
+		assert.Equal(t, diff.FileName, latest[0]["fileName"])
+	}
+
+	output.Reset()
+	assert.NoError(t, printInspection(&output, c, diff.FileName, "text"))
+	assert.Contains(t, output.String(), "Chain:    neo4j-2024-03-08T02-00-00.backup (FULL) -> neo4j-2024-03-09T02-00-00.backup (DIFF)")
+	assert.ErrorContains(t, printInspection(&output, c, "neo4j-2024-03-01T02-00-00.backup", "text"), "not found")
+
+	for _, tt := range []struct {
+		args []string
+		want string
+	}{
+		{args: []string{"diff"}, want: "unknown command diff"},
+		{args: []string{"list", "-output", "yaml"}, want: "invalid output yaml"},
+		{args: []string{"inspect"}, want: "inspect expects the name of a backup file"},
+		{args: []string{"inspect", "backup-manifest-2024-03-09T02-00-00.json"}, want: "is not a backup file name"},
+		{args: []string{"latest", "-all"}, want: "flag provided but not defined"},
+	} {
+		assert.ErrorContains(t, runCatalogCommand(tt.args, io.Discard, io.Discard), tt.want)
+	}
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"fmt"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/chain"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/common"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/logging"
+	"k8s.io/utils/strings/slices"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// chainCacheDirectory is the directory of the chain cache under /backups so that the cached files can be hard linked
+const chainCacheDirectory = ".chains"
+
+// backupChains makes the backup chain of every database available to neo4j-admin so that AUTO and DIFF backups are
+// differential , and records the chain of every new backup file in its object metadata
+type backupChains struct {
+	mode       chain.Mode
+	cache      *chain.Cache
+	backend    common.StorageBackend
+	bucketName string
+	location   string
+	backupType string
+	// mutex serializes the downloads of the chains when databases are backed up in parallel
+	mutex sync.Mutex
+}
+
+// newBackupChains returns the backup chains configured via BACKUP_CHAIN_CACHE. nil is returned when the chain cache is disabled
+func newBackupChains(backend common.StorageBackend, bucketName string) (*backupChains, error) {
+	mode, err := chain.ModeFromEnv()
+	if err != nil || mode == chain.ModeNone {
+		return nil, err
+	}
+	if mode == chain.ModeDownload && backend == nil {
+		slog.Warn("Downloading the backup chain requires a cloud provider. Only the local chain cache is used")
+		mode = chain.ModeLocal
+	}
+	location := os.Getenv("LOCATION")
+	cache, err := chain.NewCache(filepath.Join(location, chainCacheDirectory))
+	if err != nil {
+		return nil, err
+	}
+	return &backupChains{
+		mode:       mode,
+		cache:      cache,
+		backend:    backend,
+		bucketName: bucketName,
+		location:   location,
+		backupType: os.Getenv("TYPE"),
+	}, nil
+}
+
+// prepare places the chain of the database (every cached , kept or uploaded database for *) at the location before its
+// backup. The chains are returned by database along with the files to delete from the location once the backup finished
+// Nothing is prepared for FULL backups. A database whose chain cannot be prepared is only logged since neo4j-admin then
+// takes a full backup , unless a backup file is kept at the location
+func (b *backupChains) prepare(database string) (map[string][]chain.Link, []string, error) {
+	chains := make(map[string][]chain.Link)
+	if strings.ToUpper(b.backupType) == chain.TypeFull {
+		return chains, nil, nil
+	}
+	databases := []string{database}
+	if database == "*" {
+		cached, err := b.cache.Databases()
+		if err != nil {
+			return chains, nil, err
+		}
+		databases = cached
+	}
+	kept, err := b.keptBackups(database)
+	if err != nil {
+		return chains, nil, err
+	}
+	var uploaded map[string]string
+	if b.mode == chain.ModeDownload {
+		files, err := b.backend.ListFiles(b.bucketName)
+		if err != nil {
+			slog.Warn("Unable to list the backup chains present in the bucket", "bucket", b.bucketName, "error", err)
+		}
+		uploaded = latestBackups(files, database)
+	}
+	if database == "*" {
+		for _, latest := range []map[string]string{kept, uploaded} {
+			for other := range latest {
+				if !slices.Contains(databases, other) {
+					databases = append(databases, other)
+				}
+			}
+		}
+	}
+
+	var created []string
+	for _, database := range databases {
+		links, placed, err := b.chainOf(database, kept[database], uploaded[database])
+		if err != nil {
+			slog.Warn("Unable to prepare the backup chain of database", "database", database, "error", err)
+			b.removePrepared(placed)
+			if kept[database] == "" {
+				continue
+			}
+			links, placed = b.keptChain(kept[database]), nil
+		}
+		if links == nil {
+			continue
+		}
+		created = append(created, placed...)
+		if err = b.cache.Store(b.location, database, links); err != nil {
+			slog.Warn("Unable to cache the backup chain of database", "database", database, "error", err)
+		}
+		slog.Info("Backup chain prepared", "database", database, "tip", chain.Tip(links).FileName, "length", len(links))
+		chains[database] = links
+	}
+	return chains, created, nil
+}
+
+// chainOf places the chain of the database at the location and returns it along with the files placed at the location
+// The chain is the cached one , else the chain of the backup file kept at the location , else the chain of the uploaded
+// backup file. nil is returned when there is no chain
+func (b *backupChains) chainOf(database string, kept string, uploaded string) ([]chain.Link, []string, error) {
+	links, linked, err := b.cache.Link(database, b.location)
+	switch {
+	case err != nil || links != nil:
+		return links, linked, err
+	case kept != "":
+		return b.keptChain(kept), nil, nil
+	case uploaded != "":
+		return b.download(uploaded)
+	}
+	return nil, nil, nil
+}
+
+// keptBackups returns the latest backup file name per database kept at the location ex: with KEEP_BACKUP_FILES
+func (b *backupChains) keptBackups(database string) (map[string]string, error) {
+	entries, err := os.ReadDir(b.location)
+	if err != nil {
+		return nil, err
+	}
+	var files []common.FileInfo
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			files = append(files, common.FileInfo{Name: entry.Name()})
+		}
+	}
+	return latestBackups(files, database), nil
+}
+
+// keptChain returns the chain of the backup file kept at the location as recorded in the metadata files of the chain
+// neo4j-admin takes the differential backup on top of the kept backup file so it is the tip even when its chain is incomplete
+func (b *backupChains) keptChain(fileName string) []chain.Link {
+	links, err := chain.Resolve(b.statKeptFile, "", fileName)
+	if err != nil {
+		logging.ForFile(fileName).Warn("Incomplete backup chain kept at the location", "error", err)
+		info, _ := b.statKeptFile(fileName, "")
+		links = []chain.Link{chain.LinkFromMetadata(fileName, info.Metadata)}
+	}
+	return links
+}
+
+// statKeptFile returns the info of the file kept at the location along with the metadata recorded for it
+func (b *backupChains) statKeptFile(fileName string, _ string) (common.FileInfo, error) {
+	info, err := os.Stat(filepath.Join(b.location, fileName))
+	if err != nil {
+		return common.FileInfo{}, err
+	}
+	metadata, err := common.LoadFileMetadata(b.location, fileName)
+	if err != nil {
+		return common.FileInfo{}, err
+	}
+	return common.FileInfo{Name: fileName, Size: info.Size(), LastModified: info.ModTime(), Metadata: metadata}, nil
+}
+
+// download downloads the chain of the backup file from the bucket to the location and decrypts it
+// The chain is returned along with the names of the downloaded files
+func (b *backupChains) download(fileName string) ([]chain.Link, []string, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	links, err := chain.Resolve(b.backend.StatFile, b.bucketName, fileName)
+	if err != nil {
+		return nil, nil, err
+	}
+	var downloaded []string
+	for _, link := range links {
+		if _, err = os.Stat(filepath.Join(b.location, link.FileName)); err == nil {
+			continue
+		}
+		logging.ForFile(link.FileName).Info("Downloading backup chain file", "bucket", b.bucketName)
+		downloaded = append(downloaded, link.FileName)
+		if err = b.backend.DownloadFile([]string{link.FileName}, b.bucketName); err != nil {
+			return nil, downloaded, err
+		}
+	}
+	if err = decryptFiles(downloaded); err != nil {
+		return nil, downloaded, err
+	}
+	return links, downloaded, nil
+}
+
+// record writes the chain metadata of the backup files , attached to them on upload , and caches their chains
+// The backup files must still be unencrypted
+func (b *backupChains) record(backupFileNames []string, chains map[string][]chain.Link) error {
+	for _, fileName := range backupFileNames {
+		backup, ok := common.ParseBackupFileName(fileName)
+		if !ok {
+			continue
+		}
+		link := chain.Next(fileName, backup.Database, b.backupType, chain.Tip(chains[backup.Database]))
+		if err := common.SaveFileMetadata(b.location, fileName, link.Metadata()); err != nil {
+			return err
+		}
+		if err := b.cache.Store(b.location, backup.Database, chain.Extend(chains[backup.Database], link)); err != nil {
+			return fmt.Errorf("Unable to cache the backup chain of database %s \n Here's why: %v", backup.Database, err)
+		}
+	}
+	return nil
+}
+
+// removePrepared deletes the chain files placed at the location by prepare. The cached files are kept
+func (b *backupChains) removePrepared(fileNames []string) {
+	for _, fileName := range fileNames {
+		if err := os.Remove(filepath.Join(b.location, fileName)); err != nil && !os.IsNotExist(err) {
+			logging.ForFile(fileName).Warn("Unable to remove backup chain file", "error", err)
+		}
+	}
+}
+
+// latestBackups returns the latest backup file name per database among the files. "*" returns all the databases
+func latestBackups(files []common.FileInfo, database string) map[string]string {
+	latest := make(map[string]common.Backup)
+	for _, file := range files {
+		backup, ok := common.ParseBackupFileName(file.Name)
+		if !ok || (database != "*" && backup.Database != database) {
+			continue
+		}
+		if current, present := latest[backup.Database]; !present || backup.Timestamp.After(current.Timestamp) {
+			latest[backup.Database] = backup
+		}
+	}
+	fileNames := make(map[string]string, len(latest))
+	for database, backup := range latest {
+		fileNames[database] = backup.FileName
+	}
+	return fileNames
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/chain"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/common"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBackupChains(t *testing.T) {
+	location := t.TempDir()
+	t.Setenv("LOCATION", location)
+	t.Setenv("TYPE", "AUTO")
+	t.Setenv("BACKUP_CHAIN_CACHE", "download")
+	t.Setenv("ENCRYPTION_KEY_PATH", "")
+	backend := newFakeBackend("demo")
+	full := "neo4j-2024-03-08T02-00-00.backup"
+	diff := "neo4j-2024-03-09T02-00-00.backup"
+
+	// backup takes the backup file with the prepared chains , uploads it and deletes it like the uploader does
+	backup := func(chains *backupChains, fileName string) {
+		links, prepared, err := chains.prepare("neo4j")
+		assert.NoError(t, err)
+		assert.NoError(t, os.WriteFile(filepath.Join(location, fileName), []byte(fileName), 0644))
+		assert.NoError(t, chains.record([]string{fileName}, links))
+		assert.NoError(t, backend.UploadFile([]string{fileName}, "demo"))
+		deleteUploadedFiles([]string{fileName})
+		chains.removePrepared(prepared)
+	}
+
+	chains, err := newBackupChains(backend, "demo")
+	assert.NoError(t, err)
+	backup(chains, full)
+	backup(chains, diff)
+	info, err := backend.StatFile(diff, "demo")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{chain.TypeMetadataKey: chain.TypeDiff, chain.ParentMetadataKey: full, chain.ChainMetadataKey: full}, info.Metadata)
+	assert.NoFileExists(t, filepath.Join(location, full), "the prepared chain is removed after the backup")
+
+	// a new backup pod without cache downloads the chain from the bucket
+	assert.NoError(t, os.RemoveAll(filepath.Join(location, chainCacheDirectory)))
+	chains, err = newBackupChains(backend, "demo")
+	assert.NoError(t, err)
+	links, prepared, err := chains.prepare("*")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{full, diff}, prepared)
+	assert.Len(t, links["neo4j"], 2)
+	assert.FileExists(t, filepath.Join(location, diff))
+	chains.removePrepared(prepared)
+
+	// full backups start a new chain
+	t.Setenv("TYPE", "FULL")
+	chains, err = newBackupChains(backend, "demo")
+	assert.NoError(t, err)
+	links, prepared, err = chains.prepare("neo4j")
+	assert.NoError(t, err)
+	assert.Empty(t, links)
+	assert.Empty(t, prepared)
+
+	t.Setenv("BACKUP_CHAIN_CACHE", "none")
+	chains, err = newBackupChains(backend, "demo")
+	assert.NoError(t, err)
+	assert.Nil(t, chains)
+}
+
+func TestResolveChains(t *testing.T) {
+	backend := newFakeBackend("demo")
+	files := backend.buckets["demo"]
+	full := chain.Next("neo4j-2024-03-08T02-00-00.backup", "neo4j", "AUTO", nil)
+	diff := chain.Next("neo4j-2024-03-09T02-00-00.backup", "neo4j", "AUTO", &full)
+	for _, link := range []chain.Link{full, diff} {
+		files[link.FileName] = common.FileInfo{Name: link.FileName, Metadata: link.Metadata()}
+	}
+	files["system-2024-03-09T02-00-00.backup"] = common.FileInfo{Name: "system-2024-03-09T02-00-00.backup"}
+
+	backups := []common.Backup{{FileName: diff.FileName, Database: "neo4j"}, {FileName: "system-2024-03-09T02-00-00.backup", Database: "system"}}
+	chains, err := resolveChains(backend.StatFile, "demo", backups)
+	assert.NoError(t, err)
+	assert.Equal(t, []chain.Link{full, diff}, chains[diff.FileName])
+	assert.Len(t, chains["system-2024-03-09T02-00-00.backup"], 1)
+
+	delete(files, full.FileName)
+	_, err = resolveChains(backend.StatFile, "demo", backups)
+	assert.ErrorContains(t, err, "is broken")
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"fmt"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/common"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/consistency"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/logging"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/manifest"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/metrics"
+	neo4jAdmin "github.com/neo4j/helm-charts/neo4j-admin/backup/neo4j-admin"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/restore"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// defaultDrillScratchPath is the directory the backups are downloaded to and restored into when DRILL_SCRATCH_PATH is not set
+const defaultDrillScratchPath = "/backups/drill"
+
+// drillFunc restores the backup , whose chain is present at the location , into the scratch directory and checks the restored
+// database. The name of the inconsistency report archive written to the location is returned along with the findings of the report ,
+// empty when no inconsistencies were found
+type drillFunc func(backup common.Backup, scratchDirectory string, reportName string) (string, consistency.Findings, error)
+
+// performDrill downloads the latest backup of every database from the bucket , restores it into a scratch directory and checks
+// its consistency so that what is actually in the bucket is proven restorable. The run fails when the drill of any database fails
+func performDrill() {
+
+	publishMetrics = true
+	scratchPath := os.Getenv("DRILL_SCRATCH_PATH")
+	if scratchPath == "" {
+		scratchPath = defaultDrillScratchPath
+	}
+	os.Setenv("LOCATION", scratchPath)
+	err := os.MkdirAll(scratchPath, 0755)
+	handleError(err)
+
+	options, err := restore.OptionsFromEnv()
+	handleError(err)
+
+	backend, err := newStorageBackend(os.Getenv("CLOUD_PROVIDER"), os.Getenv("CREDENTIAL_PATH"))
+	handleError(err)
+	if backend == nil {
+		handleError(fmt.Errorf("The backup drill verifies the backups present in the bucket. Please set CLOUD_PROVIDER"))
+	}
+
+	bucketName := os.Getenv("BUCKET_NAME")
+	err = backend.CheckAccess(bucketName)
+	handleError(err)
+	summary.Bucket = bucketName
+
+	stopPhase := metrics.StartPhase("drill")
+	err = drillBackups(backend, bucketName, options, restoreAndCheck)
+	stopPhase()
+	handleError(err)
+	finishRun(nil)
+	metrics.Publish(true)
+}
+
+// drillBackups drills the selected backups one after the other so that only one restored database uses the scratch space at a time
+// The failure of one database does not stop the drill of the others. An error listing the failed databases is returned
+func drillBackups(backend common.StorageBackend, bucketName string, options restore.Options, drill drillFunc) error {
+	backups, err := selectBackups(backend.ListFiles, bucketName, options)
+	if err != nil {
+		return err
+	}
+	failures := make(map[string]string)
+	for _, backup := range backups {
+		consistencyCheck, err := drillBackup(backend, bucketName, backup, drill)
+		metrics.RecordDrillResult(backup.Database, backup.Timestamp, err == nil && consistencyCheck.Consistent)
+		if err != nil {
+			logging.ForFile(backup.FileName).Error("Backup drill failed", "database", backup.Database, "error", err)
+			failures[backup.Database] = err.Error()
+			continue
+		}
+		metrics.RecordConsistencyCheck(backup.Database, consistencyCheck.Consistent)
+		if consistencyCheck.Findings != nil {
+			metrics.RecordConsistencyFindings(backup.Database, *consistencyCheck.Findings)
+		} else {
+			metrics.RecordConsistencyFindings(backup.Database, consistency.Findings{})
+		}
+		summary.ConsistencyChecks = append(summary.ConsistencyChecks, consistencyCheck)
+		if !consistencyCheck.Consistent {
+			logging.ForFile(backup.FileName).Error("Backup drill found inconsistencies", "database", backup.Database, "report", consistencyCheck.Report.Name, "findings", consistencyCheck.Findings.String())
+			failures[backup.Database] = fmt.Sprintf("inconsistencies found in backup %s , %s , report %s", backup.FileName, consistencyCheck.Findings, consistencyCheck.Report.Name)
+			continue
+		}
+		logging.ForFile(backup.FileName).Info("Backup drill succeeded", "database", backup.Database)
+	}
+	if len(failures) == 0 {
+		return nil
+	}
+	summary.FailedDatabases = failures
+	var messages []string
+	for database, failure := range failures {
+		messages = append(messages, fmt.Sprintf("%s: %s", database, failure))
+	}
+	sort.Strings(messages)
+	return fmt.Errorf("Backup drill failed for %d database(s) !! %s", len(failures), strings.Join(messages, " ; "))
+}
+
+// drillBackup downloads the chain of the backup to the location , decrypts it and drills it in the scratch directory of its database
+// The inconsistency report is uploaded to the bucket. The downloaded files , the scratch directory and the report are removed afterwards
+func drillBackup(backend common.StorageBackend, bucketName string, backup common.Backup, drill drillFunc) (manifest.ConsistencyCheck, error) {
+	consistencyCheck := manifest.ConsistencyCheck{Database: backup.Database}
+	chains, err := resolveChains(backend.StatFile, bucketName, []common.Backup{backup})
+	if err != nil {
+		return consistencyCheck, err
+	}
+	var fileNames []string
+	for _, link := range chains[backup.FileName] {
+		fileNames = append(fileNames, link.FileName)
+	}
+
+	location := os.Getenv("LOCATION")
+	scratchDirectory := filepath.Join(location, backup.Database)
+	reportName := fmt.Sprintf("%s.drill", backup.FileName)
+	defer removeDrillFiles(location, append(fileNames, backup.Database, reportName+".report", reportName+".report.tar.gz"))
+
+	stopPhase := metrics.StartDatabasePhase(backup.Database, "drill_download")
+	err = backend.DownloadFile(fileNames, bucketName)
+	stopPhase()
+	if err != nil {
+		return consistencyCheck, err
+	}
+	if err = decryptFiles(fileNames); err != nil {
+		return consistencyCheck, err
+	}
+	if err = os.RemoveAll(scratchDirectory); err != nil {
+		return consistencyCheck, err
+	}
+	if err = os.MkdirAll(scratchDirectory, 0755); err != nil {
+		return consistencyCheck, err
+	}
+
+	stopPhase = metrics.StartDatabasePhase(backup.Database, "drill")
+	reportArchiveName, findings, err := drill(backup, scratchDirectory, reportName)
+	stopPhase()
+	if err != nil {
+		return consistencyCheck, err
+	}
+	consistencyCheck.Consistent = reportArchiveName == ""
+	if consistencyCheck.Consistent {
+		return consistencyCheck, nil
+	}
+	report, err := manifest.NewArtifact(location, reportArchiveName)
+	if err != nil {
+		return consistencyCheck, err
+	}
+	consistencyCheck.Report = &report
+	consistencyCheck.Findings = &findings
+	metrics.RecordArtifact(backup.Database, "report", report.Size)
+	if err = uploadFiles(backend, []string{reportArchiveName}, bucketName); err != nil {
+		return consistencyCheck, fmt.Errorf("unable to upload the drill report %s \n Here's why: %v", reportArchiveName, err)
+	}
+	summary.Files = append(summary.Files, reportArchiveName)
+	return consistencyCheck, nil
+}
+
+// restoreAndCheck restores the backup present at the location into the scratch directory and checks the restored database
+func restoreAndCheck(backup common.Backup, scratchDirectory string, reportName string) (string, consistency.Findings, error) {
+	location := os.Getenv("LOCATION")
+	err := neo4jAdmin.PerformScratchRestore(backup.Database, filepath.Join(location, backup.FileName), scratchDirectory)
+	if err != nil {
+		return "", consistency.Findings{}, err
+	}
+	return neo4jAdmin.PerformScratchConsistencyCheck(backup.Database, scratchDirectory, location, reportName)
+}
+
+// removeDrillFiles deletes the files and directories created at the location by the drill of a backup
+func removeDrillFiles(location string, fileNames []string) {
+	for _, fileName := range fileNames {
+		if err := os.RemoveAll(filepath.Join(location, fileName)); err != nil {
+			logging.ForFile(fileName).Warn("Unable to remove backup drill file", "error", err)
+		}
+	}
+}
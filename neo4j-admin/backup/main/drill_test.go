@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/chain"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/common"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/consistency"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/notification"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/restore"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDrillBackups(t *testing.T) {
+	location := t.TempDir()
+	t.Setenv("LOCATION", location)
+	previousSummary := summary
+	summary = &notification.Result{}
+	defer func() { summary = previousSummary }()
+
+	backend := newFakeBackend("demo")
+	files := backend.buckets["demo"]
+	full := chain.Next("neo4j-2024-03-08T02-00-00.backup", "neo4j", "AUTO", nil)
+	diff := chain.Next("neo4j-2024-03-09T02-00-00.backup", "neo4j", "AUTO", &full)
+	orphan := chain.Next("movies-2024-03-09T02-00-00.backup", "movies", "AUTO", &chain.Link{FileName: "movies-2024-03-08T02-00-00.backup", Chain: "movies-2024-03-08T02-00-00.backup"})
+	for _, link := range []chain.Link{full, diff, orphan} {
+		files[link.FileName] = common.FileInfo{Name: link.FileName, Metadata: link.Metadata()}
+	}
+	files["system-2024-03-09T02-00-00.backup"] = common.FileInfo{Name: "system-2024-03-09T02-00-00.backup"}
+
+	var drilled []string
+	err := drillBackups(backend, "demo", restore.Options{Databases: []string{"*"}}, func(backup common.Backup, scratchDirectory string, reportName string) (string, consistency.Findings, error) {
+		drilled = append(drilled, backup.FileName)
+		assert.DirExists(t, scratchDirectory)
+		if backup.Database == "neo4j" {
+			assert.FileExists(t, filepath.Join(location, full.FileName), "the whole chain should be downloaded")
+			assert.FileExists(t, filepath.Join(location, diff.FileName))
+			return "", consistency.Findings{}, nil
+		}
+		reportArchiveName := fmt.Sprintf("%s.report.tar.gz", reportName)
+		assert.NoError(t, os.WriteFile(filepath.Join(location, reportArchiveName), []byte("inconsistent"), 0644))
+		return reportArchiveName, consistency.Findings{Errors: 1, ByType: map[string]int{"counts": 1}}, nil
+	})
+
+	assert.ErrorContains(t, err, "Backup drill failed for 2 database(s)")
+	assert.ErrorContains(t, err, "movies: unable to resolve the backup chain")
+	assert.ErrorContains(t, err, "system: inconsistencies found in backup system-2024-03-09T02-00-00.backup , 1 errors , 0 warnings (counts=1)")
+	assert.Equal(t, []string{diff.FileName, "system-2024-03-09T02-00-00.backup"}, drilled, "broken chains should not be drilled")
+	assert.Len(t, summary.FailedDatabases, 2)
+	if assert.Len(t, summary.ConsistencyChecks, 2) {
+		assert.True(t, summary.ConsistencyChecks[0].Consistent)
+		assert.False(t, summary.ConsistencyChecks[1].Consistent)
+		assert.Equal(t, "system-2024-03-09T02-00-00.backup.drill.report.tar.gz", summary.ConsistencyChecks[1].Report.Name)
+		assert.Equal(t, 1, summary.ConsistencyChecks[1].Findings.Errors)
+	}
+	assert.Contains(t, backend.fileNames("demo"), "system-2024-03-09T02-00-00.backup.drill.report.tar.gz", "the report should be uploaded")
+
+	entries, err := os.ReadDir(location)
+	assert.NoError(t, err)
+	assert.Empty(t, entries, "the downloaded files , scratch directories and reports should be removed")
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/common"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/encryption"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/logging"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// encryptionKey returns the key present at ENCRYPTION_KEY_PATH. nil is returned when encryption is not configured
+func encryptionKey() ([]byte, error) {
+	keyPath := os.Getenv("ENCRYPTION_KEY_PATH")
+	if keyPath == "" {
+		return nil, nil
+	}
+	return encryption.KeyFromFile(keyPath)
+}
+
+// uploadDirectory is the directory under /backups holding the encrypted copies of the files to upload so that the files
+// kept at /backups stay in plaintext and can be used as the parent of the next differential backup
+const uploadDirectory = ".upload"
+
+// encryptFiles encrypts the provided files present at the location in place
+// Only used for files which are deleted right after their upload ex: files uploaded per database
+func encryptFiles(key []byte, fileNames []string) error {
+	location := os.Getenv("LOCATION")
+	for _, fileName := range fileNames {
+		filePath := fmt.Sprintf("%s/%s", location, fileName)
+		logging.ForFile(fileName).Info("Encrypting file", "path", filePath)
+		if err := encryption.EncryptFile(key, filePath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encryptFilesForUpload writes the encrypted copies of the provided files present at the location , along with their
+// recorded metadata , to the upload directory under the location and returns the upload directory
+// The files at the location are left untouched. Every encryption uses a new random nonce so the encrypted copy left by an
+// interrupted run is reused , along with its upload state , when it is newer than the file and encrypted with the same key.
+// Its upload is then resumed rather than restarted. The other files left in the upload directory are removed
+func encryptFilesForUpload(key []byte, fileNames []string) (string, error) {
+	location := os.Getenv("LOCATION")
+	uploadLocation := filepath.Join(location, uploadDirectory)
+	if err := os.MkdirAll(uploadLocation, 0755); err != nil {
+		return "", err
+	}
+	reused := make(map[string]bool)
+	for _, fileName := range fileNames {
+		current, err := isCurrentCopy(key, filepath.Join(location, fileName), filepath.Join(uploadLocation, fileName))
+		if err != nil {
+			return "", err
+		}
+		reused[fileName] = current
+	}
+	if err := removeStaleUploadFiles(uploadLocation, reused); err != nil {
+		return "", err
+	}
+	for _, fileName := range fileNames {
+		filePath := filepath.Join(location, fileName)
+		uploadPath := filepath.Join(uploadLocation, fileName)
+		logger := logging.ForFile(fileName)
+		if reused[fileName] {
+			logger.Info("Reusing encrypted copy of file left by an interrupted upload", "path", filePath, "copy", uploadPath)
+		} else {
+			logger.Info("Encrypting copy of file for upload", "path", filePath, "copy", uploadPath)
+			if err := encryption.EncryptFileTo(key, filePath, uploadPath); err != nil {
+				return "", err
+			}
+		}
+		metadata, err := common.LoadFileMetadata(location, fileName)
+		if err != nil {
+			return "", err
+		}
+		if metadata != nil {
+			if err = common.SaveFileMetadata(uploadLocation, fileName, metadata); err != nil {
+				return "", err
+			}
+		}
+	}
+	return uploadLocation, nil
+}
+
+// isCurrentCopy returns true if the encrypted copy exists , was written after the file was last modified and was
+// encrypted with the key. The copy is written to a temporary file first so an existing copy is complete
+func isCurrentCopy(key []byte, filePath string, uploadPath string) (bool, error) {
+	uploadInfo, err := os.Stat(uploadPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return false, err
+	}
+	if uploadInfo.ModTime().Before(info.ModTime()) {
+		return false, nil
+	}
+	return encryption.IsEncryptedWith(key, uploadPath)
+}
+
+// removeStaleUploadFiles removes the files of the upload directory except the reused copies along with their metadata and upload state
+func removeStaleUploadFiles(uploadLocation string, reused map[string]bool) error {
+	entries, err := os.ReadDir(uploadLocation)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if reused[entry.Name()] || isSidecarOfReusedCopy(entry.Name(), reused) {
+			continue
+		}
+		slog.Debug("Deleting file left in the upload directory", "path", filepath.Join(uploadLocation, entry.Name()))
+		if err = os.RemoveAll(filepath.Join(uploadLocation, entry.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isSidecarOfReusedCopy returns true for the metadata and upload state files of a reused copy ex: .neo4j-2024-03-10T10-00-00.backup.upload.json
+func isSidecarOfReusedCopy(name string, reused map[string]bool) bool {
+	for fileName, current := range reused {
+		if current && strings.HasPrefix(name, fmt.Sprintf(".%s.", fileName)) {
+			return true
+		}
+	}
+	return false
+}
+
+// removeUploadDirectory moves the provided files , which are not encrypted ex: the backup manifest , from the upload
+// directory back to the location and deletes the encrypted copies
+func removeUploadDirectory(uploadLocation string, location string, fileNames []string) error {
+	for _, fileName := range fileNames {
+		if err := os.Rename(filepath.Join(uploadLocation, fileName), filepath.Join(location, fileName)); err != nil {
+			return fmt.Errorf("Couldn't move file %s from %s to %s. Here's why: %v", fileName, uploadLocation, location, err)
+		}
+	}
+	slog.Debug("Deleting encrypted copies of the uploaded files", "path", uploadLocation)
+	return os.RemoveAll(uploadLocation)
+}
+
+// decryptFiles decrypts the provided files present at the location in place
+// Files which are not encrypted are left untouched so that backups taken before enabling encryption can still be restored
+func decryptFiles(fileNames []string) error {
+	location := os.Getenv("LOCATION")
+	for _, fileName := range fileNames {
+		filePath := fmt.Sprintf("%s/%s", location, fileName)
+		encrypted, err := encryption.IsEncrypted(filePath)
+		if err != nil {
+			return err
+		}
+		if !encrypted {
+			continue
+		}
+		key, err := encryptionKey()
+		if err != nil {
+			return err
+		}
+		if key == nil {
+			return fmt.Errorf("File %s is encrypted but no encryption key is configured. Please set ENCRYPTION_KEY_PATH", filePath)
+		}
+		logging.ForFile(fileName).Info("Decrypting file", "path", filePath)
+		if err = encryption.DecryptFile(key, filePath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
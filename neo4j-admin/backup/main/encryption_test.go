@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/common"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/encryption"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestEncryptDecryptFiles(t *testing.T) {
+	location := t.TempDir()
+	t.Setenv("LOCATION", location)
+	keyPath := filepath.Join(t.TempDir(), "key")
+	assert.NoError(t, os.WriteFile(keyPath, []byte(base64.StdEncoding.EncodeToString(make([]byte, 32))), 0600))
+	fileName := "neo4j-2024-03-10T10-00-00.backup"
+	assert.NoError(t, os.WriteFile(filepath.Join(location, fileName), []byte("demo"), 0644))
+
+	t.Setenv("ENCRYPTION_KEY_PATH", "")
+	key, err := encryptionKey()
+	assert.NoError(t, err)
+	assert.Nil(t, key)
+
+	t.Setenv("ENCRYPTION_KEY_PATH", keyPath)
+	key, err = encryptionKey()
+	assert.NoError(t, err)
+	assert.NoError(t, encryptFiles(key, []string{fileName}))
+	data, err := os.ReadFile(filepath.Join(location, fileName))
+	assert.NoError(t, err)
+	assert.NotEqual(t, "demo", string(data))
+
+	t.Setenv("ENCRYPTION_KEY_PATH", "")
+	assert.ErrorContains(t, decryptFiles([]string{fileName}), "no encryption key is configured")
+
+	t.Setenv("ENCRYPTION_KEY_PATH", keyPath)
+	assert.NoError(t, decryptFiles([]string{fileName}))
+	data, err = os.ReadFile(filepath.Join(location, fileName))
+	assert.NoError(t, err)
+	assert.Equal(t, "demo", string(data))
+
+	// plaintext files are left untouched
+	assert.NoError(t, decryptFiles([]string{fileName}))
+}
+
+func TestEncryptFilesForUpload(t *testing.T) {
+	location := t.TempDir()
+	t.Setenv("LOCATION", location)
+	key := make([]byte, 32)
+	fileName, manifestFileName := "neo4j-2024-03-10T10-00-00.backup", "backup-manifest-2024-03-10T10-00-00.json"
+	assert.NoError(t, os.WriteFile(filepath.Join(location, fileName), []byte("demo"), 0644))
+	assert.NoError(t, common.SaveFileMetadata(location, fileName, map[string]string{"chain": "neo4j-2024-03-09T10-00-00.backup"}))
+
+	uploadLocation, err := encryptFilesForUpload(key, []string{fileName})
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(location, uploadDirectory), uploadLocation)
+
+	// the file kept at the location stays in plaintext
+	data, err := os.ReadFile(filepath.Join(location, fileName))
+	assert.NoError(t, err)
+	assert.Equal(t, "demo", string(data))
+	encrypted, err := encryption.IsEncrypted(filepath.Join(uploadLocation, fileName))
+	assert.NoError(t, err)
+	assert.True(t, encrypted)
+	metadata, err := common.LoadFileMetadata(uploadLocation, fileName)
+	assert.NoError(t, err)
+	assert.Equal(t, "neo4j-2024-03-09T10-00-00.backup", metadata["chain"])
+
+	assert.NoError(t, os.WriteFile(filepath.Join(uploadLocation, manifestFileName), []byte("{}"), 0644))
+	assert.NoError(t, removeUploadDirectory(uploadLocation, location, []string{manifestFileName}))
+	assert.NoDirExists(t, uploadLocation)
+	assert.FileExists(t, filepath.Join(location, manifestFileName))
+}
+
+func TestEncryptFilesForUploadAfterInterruptedUpload(t *testing.T) {
+	location := t.TempDir()
+	t.Setenv("LOCATION", location)
+	key := make([]byte, 32)
+	fileName := "neo4j-2024-03-10T10-00-00.backup"
+	filePath := filepath.Join(location, fileName)
+	assert.NoError(t, os.WriteFile(filePath, []byte("demo"), 0644))
+	past := time.Now().Add(-time.Hour)
+	assert.NoError(t, os.Chtimes(filePath, past, past))
+
+	// the multipart upload of the encrypted copy is interrupted and the run is aborted before the upload directory is removed
+	uploadLocation, err := encryptFilesForUpload(key, []string{fileName})
+	assert.NoError(t, err)
+	encrypted, err := os.ReadFile(filepath.Join(uploadLocation, fileName))
+	assert.NoError(t, err)
+	checksums, err := common.ComputeChecksums(filepath.Join(uploadLocation, fileName))
+	assert.NoError(t, err)
+	state := &common.UploadState{Bucket: "demo", Key: fileName, SHA256: checksums.SHA256Hex(), PartSize: 5 * 1024 * 1024, UploadID: "demo-upload"}
+	assert.NoError(t, common.SaveUploadState(uploadLocation, fileName, state))
+	assert.NoError(t, os.WriteFile(filepath.Join(uploadLocation, "backup-manifest-2024-03-10T10-00-00.json"), []byte("{}"), 0644))
+
+	// the next attempt reuses the encrypted copy so that the persisted upload state still matches and the upload is resumed
+	uploadLocation, err = encryptFilesForUpload(key, []string{fileName})
+	assert.NoError(t, err)
+	data, err := os.ReadFile(filepath.Join(uploadLocation, fileName))
+	assert.NoError(t, err)
+	assert.Equal(t, encrypted, data)
+	checksums, err = common.ComputeChecksums(filepath.Join(uploadLocation, fileName))
+	assert.NoError(t, err)
+	persisted, err := common.LoadUploadState(uploadLocation, fileName)
+	assert.NoError(t, err)
+	assert.True(t, persisted.Matches("demo", fileName, checksums.SHA256Hex(), 5*1024*1024))
+	assert.NoFileExists(t, filepath.Join(uploadLocation, "backup-manifest-2024-03-10T10-00-00.json"))
+
+	// a copy encrypted with a different key is encrypted again and the upload restarted
+	uploadLocation, err = encryptFilesForUpload(bytes.Repeat([]byte{1}, 32), []string{fileName})
+	assert.NoError(t, err)
+	data, err = os.ReadFile(filepath.Join(uploadLocation, fileName))
+	assert.NoError(t, err)
+	assert.NotEqual(t, encrypted, data)
+	persisted, err = common.LoadUploadState(uploadLocation, fileName)
+	assert.NoError(t, err)
+	assert.Nil(t, persisted)
+
+	// a copy older than the file is encrypted again
+	encrypted = data
+	assert.NoError(t, os.Chtimes(filePath, time.Now().Add(time.Hour), time.Now().Add(time.Hour)))
+	uploadLocation, err = encryptFilesForUpload(bytes.Repeat([]byte{1}, 32), []string{fileName})
+	assert.NoError(t, err)
+	data, err = os.ReadFile(filepath.Join(uploadLocation, fileName))
+	assert.NoError(t, err)
+	assert.NotEqual(t, encrypted, data)
+}
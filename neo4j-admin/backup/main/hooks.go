@@ -0,0 +1,35 @@
+package main
+
+import (
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/hooks"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/logging"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/metrics"
+	"log/slog"
+)
+
+// runHook runs the hook configured for the stage , if any , with the summary of the run
+// An error is returned when the hook failed and its failure policy is fail
+func runHook(stage string) error {
+	hook, err := hooks.FromEnv(stage)
+	if err != nil || hook == nil {
+		return err
+	}
+	stopPhase := metrics.StartPhase("hook_" + stage)
+	err = hook.Run(hooks.Event{Stage: stage, RunID: logging.RunID(), Summary: summary})
+	stopPhase()
+	if err != nil && hook.Policy == hooks.PolicyIgnore {
+		slog.Warn("Hook failed. Ignored as per its failure policy", "hook", stage, "error", err)
+		return nil
+	}
+	return err
+}
+
+// checkHooks validates the hooks of all the stages so that a misconfigured hook fails the run before the backup starts
+func checkHooks() error {
+	for _, stage := range []string{hooks.StagePreBackup, hooks.StagePostUpload, hooks.StageOnFailure} {
+		if _, err := hooks.FromEnv(stage); err != nil {
+			return err
+		}
+	}
+	return nil
+}
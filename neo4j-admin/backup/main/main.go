@@ -1,30 +1,74 @@
 package main
 
 import (
-	"log"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/hooks"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/logging"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/metrics"
 	"os"
 )
 
 func main() {
 
-	startupOperations()
-
-	cloudProvider := os.Getenv("CLOUD_PROVIDER")
-	switch cloudProvider {
-	case "aws":
-		awsOperations()
-		break
-	case "azure":
-		azureOperations()
+	if err := logging.Setup(); err != nil {
+		logging.Fatal("Unable to setup logging", err)
+	}
+	if len(os.Args) > 1 {
+		runCommand(os.Args[1:])
+		return
+	}
+	operation := os.Getenv("OPERATION")
+	if operation == "" {
+		operation = "backup"
+	}
+	summary.Operation = operation
+	switch operation {
+	case "backup":
+		performBackup()
 		break
-	case "gcp":
-		gcpOperations()
+	case "restore":
+		performRestore()
 		break
-	case "":
-		onPrem()
+	case "drill":
+		performDrill()
 		break
 	default:
-		log.Fatalf("Incorrect cloud provider %s", cloudProvider)
+		handleError(fmt.Errorf("Incorrect operation %s", operation))
+	}
+}
+
+// runCommand runs the catalog command given on the command line and exits with a non zero status on failure
+func runCommand(args []string) {
+	if args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		fmt.Println(catalogUsage)
+		return
 	}
+	err := runCatalogCommand(args, os.Stdout, os.Stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// publishMetrics is set when the backup metrics are to be published at the end of the run (or on failure)
+var publishMetrics bool
+
+func performBackup() {
+
+	publishMetrics = true
+	startupOperations()
+
+	backend, err := newStorageBackend(os.Getenv("CLOUD_PROVIDER"), os.Getenv("CREDENTIAL_PATH"))
+	handleError(err)
 
+	handleError(runHook(hooks.StagePreBackup))
+	backupPipeline(backend)
+	handleError(runHook(hooks.StagePostUpload))
+	finishRun(nil)
+	metrics.Publish(true)
 }
@@ -1,161 +1,611 @@
 package main
 
 import (
+	"context"
 	"fmt"
-	"github.com/neo4j/helm-charts/neo4j-admin/backup/aws"
-	"github.com/neo4j/helm-charts/neo4j-admin/backup/azure"
-	gcp "github.com/neo4j/helm-charts/neo4j-admin/backup/gcp"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/chain"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/common"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/consistency"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/discovery"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/logging"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/manifest"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/metrics"
 	neo4jAdmin "github.com/neo4j/helm-charts/neo4j-admin/backup/neo4j-admin"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/probe"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/retention"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/retry"
 	"k8s.io/utils/strings/slices"
-	"log"
+	"log/slog"
+	"net"
 	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
-func awsOperations() {
+// backupResult contains the files generated by the backup and consistency check operations
+type backupResult struct {
+	address                 string
+	backupFileNames         []string
+	consistencyCheckReports []string
+	// consistencyChecks contains the report archive name per checked database. An empty name means no inconsistencies were found
+	consistencyChecks map[string]string
+	// findings contains the inconsistencies found per database whose consistency check generated a report
+	findings map[string]consistency.Findings
+	// encrypted is true when the backup files and consistency check reports are encrypted
+	encrypted bool
+	// failures contains the error per failed entry of DATABASE
+	failures map[string]error
+	// artifacts contains the manifest artifacts computed before the files were uploaded per database and deleted , by file name
+	artifacts map[string]manifest.Artifact
+	// backupTypes contains the type (FULL or DIFF) of the backup files reported by neo4j-admin , by file name
+	backupTypes map[string]string
+}
 
-	awsClient, err := aws.NewAwsClient(os.Getenv("CREDENTIAL_PATH"))
-	handleError(err)
+// backupPipeline takes the backup , uploads the backup files , consistency check reports and the backup manifest to the storage backend
+// and applies the retention policy. A nil backend keeps the backup files only at /backups
+// The backup files and consistency check reports are encrypted before the upload when ENCRYPTION_KEY_PATH is set
+// With BACKUP_UPLOAD_PER_DATABASE the files of every database are uploaded and deleted as soon as its backup finished
+func backupPipeline(backend common.StorageBackend) {
 
+	startTime := time.Now()
 	bucketName := os.Getenv("BUCKET_NAME")
-	err = awsClient.CheckBucketAccess(bucketName)
-	handleError(err)
-
-	backupFileNames, consistencyCheckReports, err := backupOperations()
-	handleError(err)
-
-	err = awsClient.UploadFile(backupFileNames, bucketName)
-	handleError(err)
-
-	enableConsistencyCheck := os.Getenv("CONSISTENCY_CHECK_ENABLE")
-	if enableConsistencyCheck == "true" {
-		err = awsClient.UploadFile(consistencyCheckReports, bucketName)
+	if backend != nil {
+		summary.Bucket = bucketName
+		err := backend.CheckAccess(bucketName)
 		handleError(err)
 	}
-	err = deleteBackupFiles(backupFileNames, consistencyCheckReports)
+	replicator, err := newReplicatorFromEnv(backend)
 	handleError(err)
-}
 
-func gcpOperations() {
-	gcpClient, err := gcp.NewGCPClient(os.Getenv("CREDENTIAL_PATH"))
-	handleError(err)
-
-	bucketName := os.Getenv("BUCKET_NAME")
-	err = gcpClient.CheckBucketAccess(bucketName)
+	key, err := encryptionKey()
 	handleError(err)
+	var uploader *databaseUploader
+	if uploadPerDatabaseEnabled() {
+		if backend != nil {
+			uploader = &databaseUploader{backend: backend, bucketName: bucketName, key: key, replicator: replicator}
+			if slices.Contains(strings.Split(os.Getenv("DATABASE"), ","), "*") {
+				slog.Warn("Uploading per database with DATABASE * only starts once every database is backed up. /backups must fit all the databases , list them explicitly to upload them one by one")
+			}
+		} else {
+			slog.Warn("Uploading per database requires a cloud provider. The backup files are kept at /backups")
+		}
+	}
 
-	backupFileNames, consistencyCheckReports, err := backupOperations()
+	chains, err := newBackupChains(backend, bucketName)
 	handleError(err)
 
-	err = gcpClient.UploadFile(backupFileNames, bucketName)
+	result, err := backupOperations(uploader, chains)
 	handleError(err)
 
-	enableConsistencyCheck := os.Getenv("CONSISTENCY_CHECK_ENABLE")
-	if enableConsistencyCheck == "true" {
-		err = gcpClient.UploadFile(consistencyCheckReports, bucketName)
-		handleError(err)
+	// the files are encrypted before the manifest is written so that the manifest describes the uploaded files
+	// the encrypted copies are uploaded from the upload directory so that the files kept at /backups stay in plaintext
+	location := os.Getenv("LOCATION")
+	var uploadLocation string
+	if backend != nil && key != nil {
+		if uploader == nil {
+			stopPhase := metrics.StartPhase("encryption")
+			uploadLocation, err = encryptFilesForUpload(key, append(result.backupFileNames, result.consistencyCheckReports...))
+			handleError(err)
+			stopPhase()
+			os.Setenv("LOCATION", uploadLocation)
+		}
+		result.encrypted = true
 	}
-	err = deleteBackupFiles(backupFileNames, consistencyCheckReports)
-	handleError(err)
-}
 
-func azureOperations() {
-	azureClient, err := azure.NewAzureClient(os.Getenv("CREDENTIAL_PATH"))
+	m, manifestFileName, err := writeManifest(result, startTime)
 	handleError(err)
+	for database, failure := range result.failures {
+		if summary.FailedDatabases == nil {
+			summary.FailedDatabases = make(map[string]string)
+		}
+		summary.FailedDatabases[database] = failure.Error()
+	}
+	summary.Artifacts = m.Artifacts
+	summary.ConsistencyChecks = m.ConsistencyChecks
+	summary.Files = append(append(append([]string{}, result.backupFileNames...), result.consistencyCheckReports...), manifestFileName)
+
+	if backend != nil {
+		stopPhase := metrics.StartPhase("upload")
+		// the files were already uploaded per database
+		if uploader == nil {
+			err = uploadFiles(backend, result.backupFileNames, bucketName)
+			handleError(err)
+
+			enableConsistencyCheck := os.Getenv("CONSISTENCY_CHECK_ENABLE")
+			if enableConsistencyCheck == "true" {
+				err = uploadFiles(backend, result.consistencyCheckReports, bucketName)
+				handleError(err)
+			}
+		}
+		err = uploadFiles(backend, []string{manifestFileName}, bucketName)
+		handleError(err)
+		stopPhase()
 
-	containerName := os.Getenv("BUCKET_NAME")
-	err = azureClient.CheckContainerAccess(containerName)
-	handleError(err)
+		stopPhase = metrics.StartPhase("retention")
+		err = applyRetentionPolicy(backend, bucketName)
+		handleError(err)
+		stopPhase()
+	}
 
-	backupFileNames, consistencyCheckReports, err := backupOperations()
-	handleError(err)
+	if replicator != nil {
+		stopPhase := metrics.StartPhase("replication")
+		// the files were already replicated per database
+		replicatedFiles := []string{manifestFileName}
+		if uploader == nil {
+			replicatedFiles = append(append(append([]string{}, result.backupFileNames...), result.consistencyCheckReports...), manifestFileName)
+		}
+		replicator.replicate(replicatedFiles, func(fileName string) (manifest.Artifact, error) {
+			return result.artifact(os.Getenv("LOCATION"), fileName)
+		})
+		replicator.applyRetentionPolicy()
+		stopPhase()
+	}
 
-	err = azureClient.UploadFile(backupFileNames, containerName)
-	handleError(err)
+	if uploadLocation != "" {
+		os.Setenv("LOCATION", location)
+		err = removeUploadDirectory(uploadLocation, location, []string{manifestFileName})
+		handleError(err)
+	}
 
-	enableConsistencyCheck := os.Getenv("CONSISTENCY_CHECK_ENABLE")
-	if enableConsistencyCheck == "true" {
-		err = azureClient.UploadFile(consistencyCheckReports, containerName)
+	if uploader != nil {
+		deleteUploadedFiles([]string{manifestFileName})
+	} else {
+		err = deleteBackupFiles(result.backupFileNames, append(result.consistencyCheckReports, manifestFileName))
 		handleError(err)
 	}
-	err = deleteBackupFiles(backupFileNames, consistencyCheckReports)
-	handleError(err)
-}
 
-func onPrem() {
-	backupFileNames, consistencyCheckReports, err := backupOperations()
+	err = applyLocalRetentionPolicy()
 	handleError(err)
 
-	err = deleteBackupFiles(backupFileNames, consistencyCheckReports)
-	handleError(err)
+	for _, backupFileName := range result.backupFileNames {
+		if backup, ok := common.ParseBackupFileName(backupFileName); ok && result.failures[backup.Database] == nil {
+			metrics.RecordSuccess(backup.Database, time.Now())
+		}
+	}
+
+	// the job fails only after the backups of the other databases are uploaded and replicated
+	replicationErr := replicator.finish()
+	handleError(result.failureError())
+	handleError(replicationErr)
+}
 
+// uploadFiles uploads the files present at the location to the bucket and records the number of uploaded bytes
+func uploadFiles(backend common.StorageBackend, fileNames []string, bucketName string) error {
+	if err := backend.UploadFile(fileNames, bucketName); err != nil {
+		return err
+	}
+	location := os.Getenv("LOCATION")
+	for _, fileName := range fileNames {
+		info, err := os.Stat(fmt.Sprintf("%s/%s", location, fileName))
+		if err != nil {
+			return err
+		}
+		metrics.RecordUpload(info.Size())
+	}
+	return nil
 }
 
-func backupOperations() ([]string, []string, error) {
+// backupOperations backs up the databases independently so that the failure of one database does not stop the backup of the others
+// An error is returned only when no backup file was generated. A non nil uploader uploads the files of every database once its backup finished
+// Non nil chains make the backup chains available to neo4j-admin and record the chain of the backup files
+func backupOperations(uploader *databaseUploader, chains *backupChains) (*backupResult, error) {
 
 	address, err := generateAddress()
 	if err != nil {
-		return nil, nil, err
+		return nil, err
+	}
+	concurrency, err := backupConcurrency()
+	if err != nil {
+		return nil, err
 	}
 	databases := strings.Split(os.Getenv("DATABASE"), ",")
-	consistencyCheckDBs := strings.Split(os.Getenv("CONSISTENCY_CHECK_DATABASE"), ",")
-	consistencyCheckEnabled := os.Getenv("CONSISTENCY_CHECK_ENABLE")
 
-	var consistencyCheckReports []string
-	backupFileNames, err := neo4jAdmin.PerformBackup(address)
+	result := &backupResult{
+		address:           address,
+		consistencyChecks: make(map[string]string),
+		findings:          make(map[string]consistency.Findings),
+		failures:          make(map[string]error),
+		artifacts:         make(map[string]manifest.Artifact),
+		backupTypes:       make(map[string]string),
+	}
+	stopPhase := metrics.StartPhase("backup")
+	results := backupDatabases(databases, concurrency, func(database string) databaseResult {
+		result := backupDatabase(address, database, chains)
+		if uploader == nil {
+			return result
+		}
+		if err := uploader.upload(&result); err != nil {
+			// the files were not uploaded and are already deleted so they must not appear in the manifest
+			slog.Error("Upload of the backup files of the database failed", "database", database, "error", err)
+			result.backupFileNames = nil
+			result.consistencyChecks = make(map[string]string)
+			result.findings = nil
+			if result.err == nil {
+				result.err = err
+			}
+		}
+		return result
+	})
+	stopPhase()
+
+	for _, databaseResult := range results {
+		metrics.RecordDatabaseResult(databaseResult.database, databaseResult.err == nil)
+		if databaseResult.err != nil {
+			slog.Error("Backup of database failed", "database", databaseResult.database, "error", databaseResult.err)
+			result.failures[databaseResult.database] = databaseResult.err
+		}
+		// backup files are kept even if the consistency check failed afterwards
+		result.backupFileNames = append(result.backupFileNames, databaseResult.backupFileNames...)
+		for fileName, artifact := range databaseResult.artifacts {
+			result.artifacts[fileName] = artifact
+		}
+		for fileName, backupType := range databaseResult.backupTypes {
+			result.backupTypes[fileName] = backupType
+		}
+		for database, reportArchiveName := range databaseResult.consistencyChecks {
+			result.consistencyChecks[database] = reportArchiveName
+			if len(reportArchiveName) != 0 {
+				result.consistencyCheckReports = append(result.consistencyCheckReports, reportArchiveName)
+			}
+		}
+		for database, findings := range databaseResult.findings {
+			result.findings[database] = findings
+		}
+	}
+	slog.Info("Backup files generated", "files", result.backupFileNames)
+	for _, backupFileName := range result.backupFileNames {
+		if backup, ok := common.ParseBackupFileName(backupFileName); ok {
+			summary.Databases = append(summary.Databases, backup.Database)
+		}
+	}
+	if len(result.backupFileNames) == 0 {
+		return nil, result.failureError()
+	}
+	return result, nil
+}
+
+// databaseResult is the outcome of the backup and consistency check of a single entry of DATABASE
+type databaseResult struct {
+	database        string
+	backupFileNames []string
+	// backupTypes contains the type (FULL or DIFF) of the backup files reported by neo4j-admin , by file name
+	backupTypes map[string]string
+	// consistencyChecks contains the report archive name per checked database. An empty name means no inconsistencies were found
+	consistencyChecks map[string]string
+	// findings contains the inconsistencies found per database whose consistency check generated a report
+	findings map[string]consistency.Findings
+	// artifacts contains the manifest artifacts of the files uploaded per database by file name
+	artifacts map[string]manifest.Artifact
+	err       error
+}
+
+// backupDatabase takes the backup of the database followed by its consistency check if enabled
+// database * takes the backup of all the databases in a single neo4j-admin run
+// The backup fails when the inconsistencies found reach CONSISTENCY_CHECK_FAILURE_THRESHOLD but its files are kept
+func backupDatabase(address string, database string, chains *backupChains) databaseResult {
+	result := databaseResult{
+		database:          database,
+		consistencyChecks: make(map[string]string),
+		findings:          make(map[string]consistency.Findings),
+	}
+	var links map[string][]chain.Link
+	if chains != nil {
+		var prepared []string
+		var err error
+		links, prepared, err = chains.prepare(database)
+		if err != nil {
+			result.err = err
+			return result
+		}
+		// the chain stays at /backups until the consistency check of the differential backup finished
+		defer chains.removePrepared(prepared)
+	}
+	stopPhase := metrics.StartDatabasePhase(database, "backup")
+	result.err = retry.Do("backup", neo4jAdmin.IsRetryableBackupError, func() error {
+		output, err := neo4jAdmin.PerformBackup(address, database)
+		result.backupFileNames, result.backupTypes = output.FileNames(), output.Types()
+		return err
+	})
+	stopPhase()
+	// the chain of the databases backed up before a failure is recorded as well since their backup files are kept
+	if len(result.backupFileNames) != 0 && chains != nil {
+		if err := chains.record(result.backupFileNames, links); err != nil && result.err == nil {
+			result.err = err
+		}
+	}
+	if result.err != nil || os.Getenv("CONSISTENCY_CHECK_ENABLE") != "true" {
+		return result
+	}
+
+	threshold, err := consistency.FailureThresholdFromEnv()
 	if err != nil {
-		return nil, nil, err
-	}
-	log.Printf("Backup File Name(s) %v", backupFileNames)
-
-	if consistencyCheckEnabled == "true" {
-		for _, consistencyCheckDB := range consistencyCheckDBs {
-			if slices.Contains(databases, consistencyCheckDB) || slices.Contains(databases, "*") {
-				reportArchiveName, err := neo4jAdmin.PerformConsistencyCheck(consistencyCheckDB)
-				if err != nil {
-					return nil, nil, err
-				}
-				if len(reportArchiveName) != 0 {
-					consistencyCheckReports = append(consistencyCheckReports, reportArchiveName)
-				}
+		result.err = err
+		return result
+	}
+	stopPhase = metrics.StartDatabasePhase(database, "consistency_check")
+	defer stopPhase()
+	var exceeded []string
+	for _, consistencyCheckDB := range strings.Split(os.Getenv("CONSISTENCY_CHECK_DATABASE"), ",") {
+		if consistencyCheckDB != database && database != "*" {
+			continue
+		}
+		reportArchiveName, findings, err := neo4jAdmin.PerformConsistencyCheck(consistencyCheckDB)
+		if err != nil {
+			result.err = err
+			return result
+		}
+		result.consistencyChecks[consistencyCheckDB] = reportArchiveName
+		if reportArchiveName == "" {
+			continue
+		}
+		result.findings[consistencyCheckDB] = findings
+		if findings.Exceeds(threshold) {
+			exceeded = append(exceeded, fmt.Sprintf("%s: %s", consistencyCheckDB, findings))
+		}
+	}
+	// the remaining databases are checked before failing so that all the findings are reported
+	if len(exceeded) != 0 {
+		result.err = fmt.Errorf("Inconsistencies found reached the failure threshold of %d errors !! %s", threshold, strings.Join(exceeded, " ; "))
+	}
+	return result
+}
+
+// backupDatabases runs backup for every database with at most concurrency backups running at a time
+// The results are returned in the order of the databases
+func backupDatabases(databases []string, concurrency int, backup func(database string) databaseResult) []databaseResult {
+	results := make([]databaseResult, len(databases))
+	semaphore := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, database := range databases {
+		wg.Add(1)
+		go func(i int, database string) {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+			results[i] = backup(database)
+		}(i, database)
+	}
+	wg.Wait()
+	return results
+}
+
+// backupConcurrency returns the number of databases backed up in parallel as per BACKUP_CONCURRENCY. Default is 1
+func backupConcurrency() (int, error) {
+	value := strings.TrimSpace(os.Getenv("BACKUP_CONCURRENCY"))
+	if value == "" {
+		return 1, nil
+	}
+	concurrency, err := strconv.Atoi(value)
+	if err != nil || concurrency < 1 {
+		return 0, fmt.Errorf("invalid BACKUP_CONCURRENCY %s. Value should be a positive number", value)
+	}
+	return concurrency, nil
+}
+
+// failureError returns an error listing the databases whose backup failed. nil is returned if there were no failures
+func (b *backupResult) failureError() error {
+	if len(b.failures) == 0 {
+		return nil
+	}
+	var messages []string
+	for database, err := range b.failures {
+		messages = append(messages, fmt.Sprintf("%s: %v", database, err))
+	}
+	sort.Strings(messages)
+	return fmt.Errorf("Backup failed for %d database(s) !! %s", len(b.failures), strings.Join(messages, " ; "))
+}
+
+// artifact returns the manifest artifact of the file computed before it was uploaded per database or computes it from the file present at the location
+// The type of a backup file reported by neo4j-admin takes precedence over the type recorded in its chain , a full backup
+// taken instead of a differential one has no parent
+func (b *backupResult) artifact(location string, fileName string) (manifest.Artifact, error) {
+	artifact, ok := b.artifacts[fileName]
+	if !ok {
+		var err error
+		if artifact, err = manifest.NewArtifact(location, fileName); err != nil {
+			return manifest.Artifact{}, err
+		}
+	}
+	if backupType := b.backupTypes[fileName]; backupType != "" {
+		artifact.Type = backupType
+		if backupType == chain.TypeFull {
+			artifact.Parent = ""
+		}
+	}
+	return artifact, nil
+}
+
+// writeManifest writes the backup manifest of the run to /backups and returns it along with its file name
+func writeManifest(result *backupResult, startTime time.Time) (*manifest.Manifest, string, error) {
+	location := os.Getenv("LOCATION")
+	m := &manifest.Manifest{
+		ServerAddress: result.address,
+		RequestedType: os.Getenv("TYPE"),
+		StartTime:     startTime,
+		Encrypted:     result.encrypted,
+	}
+	for _, backupFileName := range result.backupFileNames {
+		artifact, err := result.artifact(location, backupFileName)
+		if err != nil {
+			return nil, "", err
+		}
+		m.Artifacts = append(m.Artifacts, artifact)
+		metrics.RecordArtifact(artifact.Database, "backup", artifact.Size)
+		if !slices.Contains(m.Databases, artifact.Database) {
+			m.Databases = append(m.Databases, artifact.Database)
+		}
+	}
+	for database, reportArchiveName := range result.consistencyChecks {
+		consistencyCheck := manifest.ConsistencyCheck{
+			Database:   database,
+			Consistent: reportArchiveName == "",
+		}
+		if reportArchiveName != "" {
+			report, err := result.artifact(location, reportArchiveName)
+			if err != nil {
+				return nil, "", err
 			}
+			consistencyCheck.Report = &report
+			metrics.RecordArtifact(database, "report", report.Size)
+			findings := result.findings[database]
+			consistencyCheck.Findings = &findings
 		}
+		metrics.RecordConsistencyCheck(database, consistencyCheck.Consistent)
+		metrics.RecordConsistencyFindings(database, result.findings[database])
+		m.ConsistencyChecks = append(m.ConsistencyChecks, consistencyCheck)
 	}
+	sort.Slice(m.ConsistencyChecks, func(i, j int) bool {
+		return m.ConsistencyChecks[i].Database < m.ConsistencyChecks[j].Database
+	})
+	m.EndTime = time.Now()
 
-	return backupFileNames, consistencyCheckReports, nil
+	fileName, err := m.Write(location)
+	if err != nil {
+		return nil, "", err
+	}
+	slog.Info("Backup manifest written", "file", fileName)
+	return m, fileName, nil
 }
 
 // startupOperations includes the following
 func startupOperations() {
 	dir, err := os.Getwd()
 	handleError(err)
-	log.Printf("printing current directory %s", dir)
+	slog.Debug("Current directory", "directory", dir)
 
 	address, err := generateAddress()
 	handleError(err)
 
-	err = neo4jAdmin.CheckDatabaseConnectivity(address)
+	// fail fast on an invalid retry policy instead of at the first retried step
+	_, err = retry.PolicyFromEnv()
 	handleError(err)
 
+	timeout, err := probe.TimeoutFromEnv()
+	handleError(err)
+
+	_, err = consistency.FailureThresholdFromEnv()
+	handleError(err)
+
+	err = checkHooks()
+	handleError(err)
+
+	stopPhase := metrics.StartPhase("connectivity")
+	err = retry.Do("connectivity", retry.Always, func() error {
+		return checkConnectivity(address, timeout)
+	})
+	handleError(err)
+	stopPhase()
+
 	os.Setenv("LOCATION", "/backups")
 }
 
+// checkConnectivity dials the backup port of the address and , when PROBE_BOLT_PORT or PROBE_HTTP_PORT are set , performs the
+// bolt handshake and the http discovery against the same host to ensure a neo4j server (and not just any process) is listening
+func checkConnectivity(address string, timeout time.Duration) error {
+	if err := neo4jAdmin.CheckDatabaseConnectivity(address, timeout); err != nil {
+		return err
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return retry.Permanent(fmt.Errorf("invalid backup address %s. Here's why: %w", address, err))
+	}
+	if port := os.Getenv("PROBE_BOLT_PORT"); port != "" {
+		version, err := probe.Bolt(net.JoinHostPort(host, port), timeout)
+		if err != nil {
+			return err
+		}
+		slog.Info("Bolt handshake succeeded", "address", net.JoinHostPort(host, port), "bolt_version", version)
+	}
+	if port := os.Getenv("PROBE_HTTP_PORT"); port != "" {
+		version, err := probe.HTTP(net.JoinHostPort(host, port), timeout)
+		if err != nil {
+			return err
+		}
+		slog.Info("Http discovery succeeded", "address", net.JoinHostPort(host, port), "neo4j_version", version)
+	}
+	return nil
+}
+
+// handleError logs the failed run summary , publishes the metrics and exits if err is not nil
 func handleError(err error) {
 	if err != nil {
-		log.Fatal(err.Error())
+		finishRun(err)
+		if publishMetrics {
+			metrics.Publish(false)
+		}
+		os.Exit(1)
 	}
 }
 
+// discoveredAddress caches the backup address selected among the endpoints or the discovered servers so that the selection
+// happens only once per run
+var discoveredAddress string
+
+// discoverAddress returns the backup address of the preferred server hosting all the databases. The candidates are the
+// ordered DATABASE_BACKUP_ENDPOINTS when set or the servers of the release discovered via the kubernetes api otherwise
+func discoverAddress() (string, error) {
+	if discoveredAddress != "" {
+		return discoveredAddress, nil
+	}
+	timeout, err := probe.TimeoutFromEnv()
+	if err != nil {
+		return "", err
+	}
+	var selectServer func(databases []string) (discovery.Server, error)
+	if os.Getenv("DATABASE_BACKUP_ENDPOINTS") != "" {
+		servers, err := discovery.EndpointsFromEnv()
+		if err != nil {
+			return "", err
+		}
+		checker := discovery.NewHTTPChecker(timeout)
+		selectServer = func(databases []string) (discovery.Server, error) {
+			return discovery.Select(checker, servers, databases)
+		}
+	} else {
+		config, err := discovery.ConfigFromEnv()
+		if err != nil {
+			return "", err
+		}
+		discoverer, err := discovery.NewInCluster(config, timeout)
+		if err != nil {
+			return "", err
+		}
+		selectServer = func(databases []string) (discovery.Server, error) {
+			return discoverer.Discover(context.TODO(), databases)
+		}
+	}
+
+	stopPhase := metrics.StartPhase("discovery")
+	defer stopPhase()
+	err = retry.Do("discovery", retry.Always, func() error {
+		server, err := selectServer(strings.Split(os.Getenv("DATABASE"), ","))
+		if err != nil {
+			return err
+		}
+		discoveredAddress = server.BackupAddress()
+		return nil
+	})
+	return discoveredAddress, err
+}
+
 // generateAddress returns the backup address in the format <hostip:port> or <standalone-admin.default.svc.cluster.local:port>
+// When DATABASE_BACKUP_ENDPOINTS is set or BACKUP_DISCOVERY is true the address of the preferred server is returned instead
 func generateAddress() (string, error) {
+	if os.Getenv("DATABASE_BACKUP_ENDPOINTS") != "" || discovery.Enabled() {
+		return discoverAddress()
+	}
 	if ip := os.Getenv("DATABASE_SERVICE_IP"); len(ip) > 0 {
 		address := fmt.Sprintf("%s:%s", ip, os.Getenv("DATABASE_BACKUP_PORT"))
-		log.Printf("Address := %s", address)
+		slog.Debug("Backup address generated", "address", address)
 		return address, nil
 	}
 	if serviceName := os.Getenv("DATABASE_SERVICE_NAME"); len(serviceName) > 0 {
 		address := fmt.Sprintf("%s.%s.svc.%s:%s", serviceName, os.Getenv("DATABASE_NAMESPACE"), os.Getenv("DATABASE_CLUSTER_DOMAIN"), os.Getenv("DATABASE_BACKUP_PORT"))
-		log.Printf("Address := %s", address)
+		slog.Debug("Backup address generated", "address", address)
 		return address, nil
 	}
 	return "", fmt.Errorf("cannot generate address. Invalid DATABASE_SERVICE_IP = %s or DATABASE_SERVICE_NAME = %s", os.Getenv("DATABASE_SERVICE_IP"), os.Getenv("DATABASE_SERVICE_NAME"))
@@ -164,14 +614,17 @@ func generateAddress() (string, error) {
 func deleteBackupFiles(backupFileNames, consistencyCheckReports []string) error {
 	if value, present := os.LookupEnv("KEEP_BACKUP_FILES"); present && value == "false" {
 		for _, backupFileName := range backupFileNames {
-			log.Printf("Deleting file /backups/%s", backupFileName)
+			logging.ForFile(backupFileName).Info("Deleting local file", "path", fmt.Sprintf("/backups/%s", backupFileName))
 			err := os.Remove(fmt.Sprintf("/backups/%s", backupFileName))
 			if err != nil {
 				return err
 			}
+			if err = common.RemoveFileMetadata("/backups", backupFileName); err != nil {
+				return err
+			}
 		}
 		for _, consistencyCheckReportName := range consistencyCheckReports {
-			log.Printf("Deleting file /backups/%s", consistencyCheckReportName)
+			logging.ForFile(consistencyCheckReportName).Info("Deleting local file", "path", fmt.Sprintf("/backups/%s", consistencyCheckReportName))
 			err := os.Remove(fmt.Sprintf("/backups/%s", consistencyCheckReportName))
 			if err != nil {
 				return err
@@ -180,3 +633,84 @@ func deleteBackupFiles(backupFileNames, consistencyCheckReports []string) error
 	}
 	return nil
 }
+
+// applyRetentionPolicy deletes the backup files present in the bucket which are not retained by the configured retention policy
+// The metadata of the backup files is fetched with StatFile so that the chains of the retained differential backups are kept
+// and the manifests are downloaded so that the reports and manifests of the deleted backups are deleted along with them
+func applyRetentionPolicy(backend common.StorageBackend, bucketName string) error {
+	policy, err := retention.PolicyFromEnv()
+	if err != nil {
+		return err
+	}
+	if !policy.IsEnabled() {
+		return nil
+	}
+	files, err := backend.ListFiles(bucketName)
+	if err != nil {
+		return err
+	}
+	databases := strings.Split(os.Getenv("DATABASE"), ",")
+	for i, file := range files {
+		backup, ok := common.ParseBackupFileName(file.Name)
+		if !ok || (!slices.Contains(databases, "*") && !slices.Contains(databases, backup.Database)) {
+			continue
+		}
+		info, err := backend.StatFile(file.Name, bucketName)
+		if err != nil {
+			return err
+		}
+		files[i].Metadata = info.Metadata
+	}
+	manifests, err := readManifests(backend, bucketName, files)
+	if err != nil {
+		return err
+	}
+	fileNames := retention.FilesToDelete(files, manifests, databases, policy, time.Now())
+	slog.Info("Backup files to be deleted as per retention policy", "bucket", bucketName, "files", fileNames)
+	return backend.DeleteFiles(fileNames, bucketName)
+}
+
+// readManifests downloads the manifests among the files to a temporary directory under the location and returns them by file name
+// A manifest which cannot be downloaded or parsed is skipped so that the reports and the manifest itself are kept
+func readManifests(backend common.StorageBackend, bucketName string, files []common.FileInfo) (map[string]*manifest.Manifest, error) {
+	location := os.Getenv("LOCATION")
+	directory, err := os.MkdirTemp(location, ".manifests-")
+	if err != nil {
+		return nil, fmt.Errorf("Unable to create a directory to download the backup manifests \n Here's why: %v", err)
+	}
+	defer os.RemoveAll(directory)
+	os.Setenv("LOCATION", directory)
+	defer os.Setenv("LOCATION", location)
+
+	manifests := make(map[string]*manifest.Manifest)
+	for _, file := range files {
+		if !manifest.IsManifestFile(file.Name) {
+			continue
+		}
+		logger := logging.ForFile(file.Name)
+		if err = backend.DownloadFile([]string{file.Name}, bucketName); err != nil {
+			logger.Warn("Unable to download backup manifest , the files it describes are not pruned", "error", err)
+			continue
+		}
+		m, err := manifest.Read(filepath.Join(directory, file.Name))
+		if err != nil {
+			logger.Warn("Unable to read backup manifest , the files it describes are not pruned", "error", err)
+			continue
+		}
+		manifests[file.Name] = m
+	}
+	return manifests, nil
+}
+
+// applyLocalRetentionPolicy deletes the backup files present at /backups which are not retained by the configured retention policy
+// It is applied only when the backup files are kept i.e. KEEP_BACKUP_FILES is true
+func applyLocalRetentionPolicy() error {
+	if os.Getenv("KEEP_BACKUP_FILES") != "true" {
+		return nil
+	}
+	local, err := newLocalBackend()
+	if err != nil {
+		return err
+	}
+	return applyRetentionPolicy(local, localBucketName)
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/chain"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/common"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/manifest"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBackupDatabases(t *testing.T) {
+	t.Parallel()
+
+	databases := []string{"neo4j", "corrupted", "system", "movies"}
+	var running, maxRunning int32
+	results := backupDatabases(databases, 2, func(database string) databaseResult {
+		current := atomic.AddInt32(&running, 1)
+		for {
+			previous := atomic.LoadInt32(&maxRunning)
+			if current <= previous || atomic.CompareAndSwapInt32(&maxRunning, previous, current) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		if database == "corrupted" {
+			return databaseResult{database: database, err: errors.New("exit status 1")}
+		}
+		return databaseResult{database: database, backupFileNames: []string{fmt.Sprintf("%s-2024-03-10T10-00-00.backup", database)}}
+	})
+
+	assert.LessOrEqual(t, maxRunning, int32(2), "at most 2 backups should run at a time")
+	assert.Len(t, results, len(databases))
+	for i, result := range results {
+		assert.Equal(t, databases[i], result.database, "results should be in the order of the databases")
+	}
+	assert.Error(t, results[1].err)
+	assert.Equal(t, []string{"movies-2024-03-10T10-00-00.backup"}, results[3].backupFileNames)
+}
+
+func TestBackupConcurrency(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    int
+		wantErr bool
+	}{
+		{value: "", want: 1},
+		{value: "3", want: 3},
+		{value: "0", wantErr: true},
+		{value: "two", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			t.Setenv("BACKUP_CONCURRENCY", tt.value)
+			got, err := backupConcurrency()
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestFailureError(t *testing.T) {
+	t.Parallel()
+
+	result := &backupResult{failures: map[string]error{}}
+	assert.NoError(t, result.failureError())
+
+	result.failures["system"] = errors.New("exit status 1")
+	result.failures["corrupted"] = errors.New("exit status 2")
+	assert.EqualError(t, result.failureError(), "Backup failed for 2 database(s) !! corrupted: exit status 2 ; system: exit status 1")
+}
+
+func TestBackupResultArtifactType(t *testing.T) {
+	location := t.TempDir()
+	full, diff, chained := "neo4j-2024-03-10T10-00-00.backup", "movies-2024-03-10T10-00-00.backup", "system-2024-03-10T10-00-00.backup"
+	for _, fileName := range []string{full, diff, chained} {
+		assert.NoError(t, os.WriteFile(filepath.Join(location, fileName), []byte(fileName), 0644))
+	}
+	// the chain expected a differential backup but neo4j-admin took a full one
+	assert.NoError(t, common.SaveFileMetadata(location, full, map[string]string{chain.TypeMetadataKey: chain.TypeDiff, chain.ParentMetadataKey: "neo4j-2024-03-09T10-00-00.backup"}))
+	assert.NoError(t, common.SaveFileMetadata(location, chained, map[string]string{chain.TypeMetadataKey: chain.TypeDiff, chain.ParentMetadataKey: "system-2024-03-09T10-00-00.backup"}))
+
+	result := &backupResult{
+		artifacts:   map[string]manifest.Artifact{diff: {Name: diff, Database: "movies"}},
+		backupTypes: map[string]string{full: "FULL", diff: "DIFF"},
+	}
+	tests := []struct {
+		fileName   string
+		wantType   string
+		wantParent string
+	}{
+		{fileName: full, wantType: "FULL"},
+		{fileName: diff, wantType: "DIFF"},
+		{fileName: chained, wantType: "DIFF", wantParent: "system-2024-03-09T10-00-00.backup"},
+	}
+	for _, tt := range tests {
+		artifact, err := result.artifact(location, tt.fileName)
+		assert.NoError(t, err)
+		assert.Equal(t, tt.wantType, artifact.Type, tt.fileName)
+		assert.Equal(t, tt.wantParent, artifact.Parent, tt.fileName)
+	}
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/common"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/filesystem"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/logging"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/manifest"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/metrics"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// replicaDestination is a secondary destination the files uploaded to the primary bucket are copied to
+type replicaDestination struct {
+	// Provider is aws , gcp , azure or filesystem
+	Provider string `json:"provider"`
+	// Bucket is the bucket (container for azure) along with the optional prefix , or the directory path inside Path for filesystem
+	Bucket         string `json:"bucket"`
+	CredentialPath string `json:"credentialPath,omitempty"`
+	// Path is the directory the volume of a filesystem destination is mounted at
+	Path string `json:"path,omitempty"`
+}
+
+// String returns the name of the destination used in the logs , metrics and run summary ex: aws:dr-backups/neo4j
+func (d replicaDestination) String() string {
+	if d.Provider == "filesystem" {
+		return fmt.Sprintf("filesystem:%s", filepath.Join(d.Path, d.Bucket))
+	}
+	return fmt.Sprintf("%s:%s", d.Provider, d.Bucket)
+}
+
+// replicaDestinationsFromEnv returns the destinations configured via REPLICATION_DESTINATIONS , a json list of destinations
+// Ex: [{"provider":"aws","bucket":"dr-backups/neo4j","credentialPath":"/replica-credentials/0/credentials"},{"provider":"filesystem","path":"/replicas/1","bucket":"neo4j"}]
+func replicaDestinationsFromEnv() ([]replicaDestination, error) {
+	value := strings.TrimSpace(os.Getenv("REPLICATION_DESTINATIONS"))
+	if value == "" {
+		return nil, nil
+	}
+	var destinations []replicaDestination
+	if err := json.Unmarshal([]byte(value), &destinations); err != nil {
+		return nil, fmt.Errorf("invalid REPLICATION_DESTINATIONS %s \n Here's why: %v", value, err)
+	}
+	for _, destination := range destinations {
+		if _, present := backends[destination.Provider]; !present {
+			return nil, fmt.Errorf("invalid REPLICATION_DESTINATIONS. Incorrect cloud provider %s", destination.Provider)
+		}
+		if strings.TrimSpace(destination.Bucket) == "" {
+			return nil, fmt.Errorf("invalid REPLICATION_DESTINATIONS. Empty bucket for the %s destination", destination.Provider)
+		}
+		if destination.Provider == "filesystem" && strings.TrimSpace(destination.Path) == "" {
+			return nil, fmt.Errorf("invalid REPLICATION_DESTINATIONS. Empty path for the filesystem destination %s", destination.Bucket)
+		}
+	}
+	return destinations, nil
+}
+
+// newReplicaBackend returns the storage backend of the destination. A filesystem destination stores the files under its own path
+// instead of DESTINATION_PATH so that it can be used along with a filesystem primary
+func newReplicaBackend(destination replicaDestination) (common.StorageBackend, error) {
+	if destination.Provider == "filesystem" {
+		client, err := filesystem.NewFilesystemClient(destination.Path)
+		if err != nil {
+			return nil, err
+		}
+		return client, nil
+	}
+	return newStorageBackend(destination.Provider, destination.CredentialPath)
+}
+
+// replica is a replica destination along with its storage backend
+type replica struct {
+	destination replicaDestination
+	backend     common.StorageBackend
+	// err is the first failure of the replication to the destination. A failed destination is skipped for the rest of the run
+	err error
+}
+
+// replicator copies the files uploaded to the primary bucket to the replica destinations. The size and the checksum reported
+// by the destination for every copy are compared with the uploaded file. The failure of a destination does not stop the replication to the others nor the backup
+// The uploader replicates the files of different databases concurrently
+type replicator struct {
+	replicas []*replica
+	mutex    sync.Mutex
+}
+
+// newReplicatorFromEnv returns the replicator of the destinations configured via REPLICATION_DESTINATIONS after checking the
+// access to every destination. nil is returned when no destination is configured
+func newReplicatorFromEnv(primary common.StorageBackend) (*replicator, error) {
+	destinations, err := replicaDestinationsFromEnv()
+	if err != nil || len(destinations) == 0 {
+		return nil, err
+	}
+	if primary == nil {
+		return nil, fmt.Errorf("Backup replication copies the files uploaded to the primary bucket. Please set CLOUD_PROVIDER")
+	}
+	return newReplicator(destinations, newReplicaBackend)
+}
+
+// newReplicator returns the replicator of the destinations whose backends are created by newBackend
+func newReplicator(destinations []replicaDestination, newBackend func(replicaDestination) (common.StorageBackend, error)) (*replicator, error) {
+	r := &replicator{}
+	for _, destination := range destinations {
+		backend, err := newBackend(destination)
+		if err != nil {
+			return nil, err
+		}
+		if err = backend.CheckAccess(destination.Bucket); err != nil {
+			return nil, fmt.Errorf("Unable to access replica destination %s \n Here's why: %v", destination, err)
+		}
+		r.replicas = append(r.replicas, &replica{destination: destination, backend: backend})
+	}
+	return r, nil
+}
+
+// replicate copies the files present at the location to every destination which did not fail yet and verifies the copies
+// against the artifacts computed for the uploaded files. Failures are recorded per destination and returned by finish
+func (r *replicator) replicate(fileNames []string, artifact func(fileName string) (manifest.Artifact, error)) {
+	if r == nil || len(fileNames) == 0 {
+		return
+	}
+	for _, replica := range r.replicas {
+		if r.failed(replica) {
+			continue
+		}
+		logger := slog.With("destination", replica.destination.String())
+		logger.Info("Replicating files", "files", fileNames)
+		if err := r.copyFiles(replica, fileNames, artifact); err != nil {
+			logger.Error("Replication failed", "error", err)
+			r.fail(replica, err)
+			continue
+		}
+		logger.Info("Files replicated", "files", fileNames)
+	}
+}
+
+// copyFiles uploads the files to the destination and verifies the copies against the artifacts of the files. The checksums
+// recorded in the object metadata are computed locally and are not trusted , the copies are compared with the checksums
+// computed or validated by the destination instead
+func (r *replicator) copyFiles(replica *replica, fileNames []string, artifact func(fileName string) (manifest.Artifact, error)) error {
+	if err := replica.backend.UploadFile(fileNames, replica.destination.Bucket); err != nil {
+		return err
+	}
+	for _, fileName := range fileNames {
+		expected, err := artifact(fileName)
+		if err != nil {
+			return err
+		}
+		info, err := replica.backend.StatFile(fileName, replica.destination.Bucket)
+		if err != nil {
+			return err
+		}
+		if info.Size != expected.Size {
+			return fmt.Errorf("Size mismatch for file %s replicated to %s. Local size = %d , Replica size = %d",
+				fileName, replica.destination, expected.Size, info.Size)
+		}
+		if err = verifyReplicaChecksum(fileName, replica.destination, expected, info); err != nil {
+			return err
+		}
+		logging.ForFile(fileName).Debug("Replica verified", "destination", replica.destination.String(), "size", info.Size)
+	}
+	return nil
+}
+
+// verifyReplicaChecksum compares the SHA-256 checksum of the copy with the checksum of the artifact in the backup manifest
+// or , when the destination only provides it (gcs) , the CRC32C checksum of the copy with the CRC32C checksum of the local file
+// The copies whose checksum is not provided by the destination were verified by its upload (azure transactional checksums ,
+// s3 part checksums , filesystem copy checksum)
+func verifyReplicaChecksum(fileName string, destination replicaDestination, expected manifest.Artifact, info common.FileInfo) error {
+	switch {
+	case info.SHA256 != "":
+		value, err := base64.StdEncoding.DecodeString(info.SHA256)
+		if err != nil {
+			return fmt.Errorf("Invalid SHA-256 checksum %s for file %s replicated to %s \n Here's why: %v", info.SHA256, fileName, destination, err)
+		}
+		if hex.EncodeToString(value) != expected.SHA256 {
+			return fmt.Errorf("Checksum mismatch for file %s replicated to %s. Manifest SHA-256 = %s , Replica SHA-256 = %s",
+				fileName, destination, expected.SHA256, hex.EncodeToString(value))
+		}
+	case info.CRC32C != "":
+		checksums, err := common.FileChecksums(filepath.Join(os.Getenv("LOCATION"), fileName))
+		if err != nil {
+			return err
+		}
+		if checksums.SHA256Hex() != expected.SHA256 {
+			return fmt.Errorf("Local file %s does not match its manifest SHA-256 %s", fileName, expected.SHA256)
+		}
+		if info.CRC32C != checksums.CRC32CBase64() {
+			return fmt.Errorf("Checksum mismatch for file %s replicated to %s. Local CRC32C = %s , Replica CRC32C = %s",
+				fileName, destination, checksums.CRC32CBase64(), info.CRC32C)
+		}
+	}
+	return nil
+}
+
+// applyRetentionPolicy applies the retention policy of the primary bucket to every destination which did not fail
+func (r *replicator) applyRetentionPolicy() {
+	if r == nil {
+		return
+	}
+	for _, replica := range r.replicas {
+		if r.failed(replica) {
+			continue
+		}
+		if err := applyRetentionPolicy(replica.backend, replica.destination.Bucket); err != nil {
+			slog.Error("Retention policy of replica destination failed", "destination", replica.destination.String(), "error", err)
+			r.fail(replica, err)
+		}
+	}
+}
+
+// finish records the outcome of the replication to every destination in the metrics and the run summary and returns an error
+// listing the failed destinations. nil is returned if there were no failures
+func (r *replicator) finish() error {
+	if r == nil {
+		return nil
+	}
+	var messages []string
+	for _, replica := range r.replicas {
+		metrics.RecordReplication(replica.destination.String(), replica.err == nil)
+		if replica.err != nil {
+			messages = append(messages, fmt.Sprintf("%s: %v", replica.destination, replica.err))
+			continue
+		}
+		summary.Replicas = append(summary.Replicas, replica.destination.String())
+	}
+	if len(messages) == 0 {
+		return nil
+	}
+	sort.Strings(messages)
+	return fmt.Errorf("Replication failed for %d destination(s) !! %s", len(messages), strings.Join(messages, " ; "))
+}
+
+func (r *replicator) failed(replica *replica) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return replica.err != nil
+}
+
+func (r *replicator) fail(replica *replica, err error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if replica.err == nil {
+		replica.err = err
+	}
+}
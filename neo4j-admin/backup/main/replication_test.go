@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/common"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/manifest"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/notification"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReplicaDestinationsFromEnv(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    []string
+		wantErr string
+	}{
+		{value: "", want: nil},
+		{
+			value: `[{"provider":"aws","bucket":"dr-backups/neo4j","credentialPath":"/replica-credentials/0/credentials"},{"provider":"filesystem","path":"/replicas/1","bucket":"neo4j"}]`,
+			want:  []string{"aws:dr-backups/neo4j", "filesystem:/replicas/1/neo4j"},
+		},
+		{value: `{"provider":"aws"}`, wantErr: "invalid REPLICATION_DESTINATIONS"},
+		{value: `[{"provider":"s3","bucket":"dr-backups"}]`, wantErr: "Incorrect cloud provider s3"},
+		{value: `[{"provider":"gcp"}]`, wantErr: "Empty bucket for the gcp destination"},
+		{value: `[{"provider":"filesystem","bucket":"neo4j"}]`, wantErr: "Empty path for the filesystem destination neo4j"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			t.Setenv("REPLICATION_DESTINATIONS", tt.value)
+			destinations, err := replicaDestinationsFromEnv()
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			var names []string
+			for _, destination := range destinations {
+				names = append(names, destination.String())
+			}
+			assert.Equal(t, tt.want, names)
+		})
+	}
+}
+
+func TestReplicator(t *testing.T) {
+	location := t.TempDir()
+	t.Setenv("LOCATION", location)
+	previousSummary := summary
+	summary = &notification.Result{}
+	defer func() { summary = previousSummary }()
+
+	fileNames := []string{"neo4j-2024-03-10T10-00-00.backup", "backup-manifest-2024-03-10T10-00-00.json"}
+	artifacts := make(map[string]manifest.Artifact)
+	for _, fileName := range fileNames {
+		assert.NoError(t, os.WriteFile(filepath.Join(location, fileName), []byte(fileName), 0644))
+		artifact, err := manifest.NewArtifact(location, fileName)
+		assert.NoError(t, err)
+		artifacts[fileName] = artifact
+	}
+	artifact := func(fileName string) (manifest.Artifact, error) {
+		return artifacts[fileName], nil
+	}
+
+	replicaPath := t.TempDir()
+	assert.NoError(t, os.Mkdir(filepath.Join(replicaPath, "neo4j"), 0755))
+	broken := newFakeBackend("dr-backups")
+	destinations := []replicaDestination{
+		{Provider: "filesystem", Path: replicaPath, Bucket: "neo4j"},
+		{Provider: "aws", Bucket: "dr-backups"},
+	}
+	_, err := newReplicator(destinations, func(destination replicaDestination) (common.StorageBackend, error) {
+		return newFakeBackend("other"), nil
+	})
+	assert.ErrorContains(t, err, "Unable to access replica destination filesystem:")
+
+	r, err := newReplicator(destinations, func(destination replicaDestination) (common.StorageBackend, error) {
+		if destination.Provider == "filesystem" {
+			return newReplicaBackend(destination)
+		}
+		// the fake backend does not record the size of the files so the size of the copies does not match
+		return broken, nil
+	})
+	assert.NoError(t, err)
+	r.replicate(fileNames, artifact)
+	r.applyRetentionPolicy()
+
+	for _, fileName := range fileNames {
+		copied, err := os.ReadFile(filepath.Join(replicaPath, "neo4j", fileName))
+		assert.NoError(t, err)
+		assert.Equal(t, fileName, string(copied))
+	}
+	err = r.finish()
+	assert.ErrorContains(t, err, "Replication failed for 1 destination(s) !! aws:dr-backups: Size mismatch for file neo4j-2024-03-10T10-00-00.backup")
+	assert.Equal(t, []string{fmt.Sprintf("filesystem:%s/neo4j", replicaPath)}, summary.Replicas)
+
+	// a failed destination is skipped for the rest of the run
+	delete(broken.buckets["dr-backups"], fileNames[0])
+	r.replicate(fileNames[:1], artifact)
+	assert.NotContains(t, broken.fileNames("dr-backups"), fileNames[0])
+
+	var nilReplicator *replicator
+	nilReplicator.replicate(fileNames, artifact)
+	assert.NoError(t, nilReplicator.finish())
+}
+
+func TestVerifyReplicaChecksum(t *testing.T) {
+	location := t.TempDir()
+	t.Setenv("LOCATION", location)
+	fileName := "neo4j-2024-03-10T10-00-00.backup"
+	assert.NoError(t, os.WriteFile(filepath.Join(location, fileName), []byte("demo"), 0644))
+	expected, err := manifest.NewArtifact(location, fileName)
+	assert.NoError(t, err)
+	local, err := common.FileChecksums(filepath.Join(location, fileName))
+	assert.NoError(t, err)
+	other, err := common.ComputeReaderChecksums(strings.NewReader("dem0"))
+	assert.NoError(t, err)
+	destination := replicaDestination{Provider: "gcp", Bucket: "dr-backups"}
+
+	tests := []struct {
+		name    string
+		info    common.FileInfo
+		wantErr string
+	}{
+		{"matching sha256", common.FileInfo{SHA256: local.SHA256Base64()}, ""},
+		{"mismatching sha256", common.FileInfo{SHA256: other.SHA256Base64()}, "Checksum mismatch for file neo4j-2024-03-10T10-00-00.backup replicated to gcp:dr-backups. Manifest SHA-256"},
+		{"invalid sha256", common.FileInfo{SHA256: "not base64"}, "Invalid SHA-256 checksum"},
+		{"matching crc32c", common.FileInfo{CRC32C: local.CRC32CBase64()}, ""},
+		{"mismatching crc32c", common.FileInfo{CRC32C: other.CRC32CBase64()}, "Local CRC32C = " + local.CRC32CBase64()},
+		// the sha256 checksum is preferred since it is compared with the manifest
+		{"sha256 and crc32c", common.FileInfo{SHA256: other.SHA256Base64(), CRC32C: local.CRC32CBase64()}, "Manifest SHA-256"},
+		// the copy was verified by the upload
+		{"no checksum", common.FileInfo{}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifyReplicaChecksum(fileName, destination, expected, tt.info)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tt.wantErr)
+			}
+		})
+	}
+
+	// the crc32c checksum is only compared with a local file which still matches the manifest
+	assert.NoError(t, os.WriteFile(filepath.Join(location, fileName), []byte("dem0 changed"), 0644))
+	err = verifyReplicaChecksum(fileName, destination, expected, common.FileInfo{CRC32C: local.CRC32CBase64()})
+	assert.ErrorContains(t, err, "does not match its manifest SHA-256")
+}
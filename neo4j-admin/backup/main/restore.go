@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/chain"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/common"
+	neo4jAdmin "github.com/neo4j/helm-charts/neo4j-admin/backup/neo4j-admin"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/restore"
+	"log/slog"
+	"os"
+)
+
+// performRestore restores the databases from the backups present in the configured bucket (or /backups when no cloud provider is set)
+func performRestore() {
+	os.Setenv("LOCATION", "/backups")
+
+	options, err := restore.OptionsFromEnv()
+	handleError(err)
+
+	backend, err := newStorageBackend(os.Getenv("CLOUD_PROVIDER"), os.Getenv("CREDENTIAL_PATH"))
+	handleError(err)
+
+	if backend == nil {
+		local, err := newLocalBackend()
+		handleError(err)
+		backups, err := selectBackups(local.ListFiles, localBucketName, options)
+		handleError(err)
+		chains, err := resolveChains(local.StatFile, localBucketName, backups)
+		handleError(err)
+		err = restoreBackups(backups, chains)
+		handleError(err)
+		finishRun(nil)
+		return
+	}
+
+	bucketName := os.Getenv("BUCKET_NAME")
+	err = backend.CheckAccess(bucketName)
+	handleError(err)
+	summary.Bucket = bucketName
+	err = restoreFromBucket(backend, bucketName, options)
+	handleError(err)
+	finishRun(nil)
+}
+
+// restoreFromBucket downloads the selected backups along with their chains from the bucket to /backups and restores them
+func restoreFromBucket(backend common.StorageBackend, bucketName string, options restore.Options) error {
+	backups, err := selectBackups(backend.ListFiles, bucketName, options)
+	if err != nil {
+		return err
+	}
+	chains, err := resolveChains(backend.StatFile, bucketName, backups)
+	if err != nil {
+		return err
+	}
+	var fileNames []string
+	for _, backup := range backups {
+		for _, link := range chains[backup.FileName] {
+			fileNames = append(fileNames, link.FileName)
+		}
+	}
+	if err = backend.DownloadFile(fileNames, bucketName); err != nil {
+		return err
+	}
+	if err = restoreBackups(backups, chains); err != nil {
+		return err
+	}
+	return deleteBackupFiles(fileNames, nil)
+}
+
+// resolveChains returns the chain of every selected backup by file name as recorded in the metadata of the backup files
+// A differential backup can only be restored when the backup files of its whole chain are present next to it
+func resolveChains(statFile func(string, string) (common.FileInfo, error), bucketName string, backups []common.Backup) (map[string][]chain.Link, error) {
+	chains := make(map[string][]chain.Link)
+	for _, backup := range backups {
+		links, err := chain.Resolve(statFile, bucketName, backup.FileName)
+		if err != nil {
+			return nil, fmt.Errorf("unable to resolve the backup chain of %s \n Here's why: %v", backup.FileName, err)
+		}
+		if len(links) > 1 {
+			slog.Info("Differential backup selected for restore , restoring its chain", "file", backup.FileName, "chain", links[0].FileName, "length", len(links))
+		}
+		chains[backup.FileName] = links
+	}
+	return chains, nil
+}
+
+func selectBackups(listFiles func(string) ([]common.FileInfo, error), bucketName string, options restore.Options) ([]common.Backup, error) {
+	files, err := listFiles(bucketName)
+	if err != nil {
+		return nil, err
+	}
+	backups, err := restore.SelectBackups(files, options)
+	if err != nil {
+		return nil, fmt.Errorf("unable to select the backups to restore from %s \n Here's why: %v", bucketName, err)
+	}
+	for _, backup := range backups {
+		slog.Info("Backup selected for restore", "file", backup.FileName, "database", backup.Database)
+		summary.Databases = append(summary.Databases, backup.Database)
+		summary.Files = append(summary.Files, backup.FileName)
+	}
+	return backups, nil
+}
+
+// restoreBackups restores the backups present at /backups along with their chains decrypting them first if required
+func restoreBackups(backups []common.Backup, chains map[string][]chain.Link) error {
+	for _, backup := range backups {
+		fileNames := []string{backup.FileName}
+		if links, present := chains[backup.FileName]; present {
+			fileNames = nil
+			for _, link := range links {
+				fileNames = append(fileNames, link.FileName)
+			}
+		}
+		if err := decryptFiles(fileNames); err != nil {
+			return err
+		}
+		err := neo4jAdmin.PerformRestore(backup.Database, fmt.Sprintf("/backups/%s", backup.FileName))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/hooks"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/logging"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/notification"
+	"log/slog"
+	"time"
+)
+
+// summary is filled in by the backup and restore operations as the run progresses
+var summary = &notification.Result{StartTime: time.Now()}
+
+// finishRun logs the summary of the run , runs the failure hook when the run failed and sends the summary to the configured
+// notifiers. A nil error means the run succeeded
+func finishRun(err error) {
+	logSummary(err)
+	if err != nil {
+		// the failure hook is always ignored on failure so only its configuration can fail
+		if hookErr := runHook(hooks.StageOnFailure); hookErr != nil {
+			slog.Warn("Unable to run the failure hook", "error", hookErr)
+		}
+	}
+	notification.NotifyAll(summary)
+}
+
+// logSummary logs the summary of the run as a single line so that the outcome can be parsed by log pipelines
+func logSummary(err error) {
+	summary.RunID = logging.RunID()
+	summary.DurationSeconds = time.Since(summary.StartTime).Seconds()
+	if err != nil {
+		summary.Status = notification.StatusFailed
+		summary.Error = err.Error()
+		slog.Error("Run summary", "summary", summary)
+		return
+	}
+	summary.Status = notification.StatusSucceeded
+	slog.Info("Run summary", "summary", summary)
+}
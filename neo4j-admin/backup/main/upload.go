@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/common"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/logging"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/manifest"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/metrics"
+	"os"
+)
+
+// uploadPerDatabaseEnabled returns true when BACKUP_UPLOAD_PER_DATABASE is true. The artifacts of every entry of DATABASE are
+// then uploaded as soon as its backup (and consistency check) finished and deleted right after , so /backups only holds the
+// artifacts of the entries in flight (at most BACKUP_CONCURRENCY) instead of the artifacts of all the databases
+// neo4j-admin writes the whole backup of a database under /backups before the artifact can be uploaded so /backups must
+// still fit the largest database , and all the databases for * which is backed up by a single neo4j-admin run
+func uploadPerDatabaseEnabled() bool {
+	return os.Getenv("BACKUP_UPLOAD_PER_DATABASE") == "true"
+}
+
+// databaseUploader encrypts , uploads and deletes the artifacts of a single database once its backup finished
+type databaseUploader struct {
+	backend    common.StorageBackend
+	bucketName string
+	// key encrypts the artifacts before the upload. nil disables the encryption
+	key []byte
+	// replicator copies the uploaded artifacts to the replica destinations before they are deleted. nil disables the replication
+	replicator *replicator
+}
+
+// upload uploads the backup files and consistency check reports of the database result and deletes them locally
+// The manifest artifacts are computed before the files are deleted and stored in the result
+// The local files are deleted even if the upload failed so that the following backups still fit in /backups
+func (u *databaseUploader) upload(result *databaseResult) error {
+	fileNames := append([]string{}, result.backupFileNames...)
+	for _, reportArchiveName := range result.consistencyChecks {
+		if reportArchiveName != "" {
+			fileNames = append(fileNames, reportArchiveName)
+		}
+	}
+	if len(fileNames) == 0 {
+		return nil
+	}
+	defer deleteUploadedFiles(fileNames)
+
+	if u.key != nil {
+		stopPhase := metrics.StartDatabasePhase(result.database, "encryption")
+		err := encryptFiles(u.key, fileNames)
+		stopPhase()
+		if err != nil {
+			return err
+		}
+	}
+
+	location := os.Getenv("LOCATION")
+	result.artifacts = make(map[string]manifest.Artifact)
+	for _, fileName := range fileNames {
+		artifact, err := manifest.NewArtifact(location, fileName)
+		if err != nil {
+			return err
+		}
+		result.artifacts[fileName] = artifact
+	}
+
+	stopPhase := metrics.StartDatabasePhase(result.database, "upload")
+	err := uploadFiles(u.backend, fileNames, u.bucketName)
+	stopPhase()
+	if err != nil || u.replicator == nil {
+		return err
+	}
+	// replication failures are recorded by the replicator since the artifacts are safe in the primary bucket
+	stopPhase = metrics.StartDatabasePhase(result.database, "replication")
+	u.replicator.replicate(fileNames, func(fileName string) (manifest.Artifact, error) {
+		return result.artifacts[fileName], nil
+	})
+	stopPhase()
+	return nil
+}
+
+// deleteUploadedFiles deletes the uploaded files from /backups. Failures are only logged since they do not affect the uploaded files
+func deleteUploadedFiles(fileNames []string) {
+	location := os.Getenv("LOCATION")
+	for _, fileName := range fileNames {
+		filePath := fmt.Sprintf("%s/%s", location, fileName)
+		logger := logging.ForFile(fileName)
+		logger.Info("Deleting uploaded file", "path", filePath)
+		if err := os.Remove(filePath); err != nil {
+			logger.Warn("Unable to delete uploaded file", "path", filePath, "error", err)
+		}
+		if err := common.RemoveFileMetadata(location, fileName); err != nil {
+			logger.Warn("Unable to delete metadata of uploaded file", "error", err)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDatabaseUploader(t *testing.T) {
+	location := t.TempDir()
+	t.Setenv("LOCATION", location)
+	backupFileName := "neo4j-2024-03-10T10-00-00.backup"
+	reportFileName := "neo4j-2024-03-10T10-05-00.backup.report.tar.gz"
+	writeFiles := func() {
+		assert.NoError(t, os.WriteFile(filepath.Join(location, backupFileName), []byte("backup"), 0644))
+		assert.NoError(t, os.WriteFile(filepath.Join(location, reportFileName), []byte("report"), 0644))
+	}
+	newResult := func() *databaseResult {
+		return &databaseResult{
+			database:          "neo4j",
+			backupFileNames:   []string{backupFileName},
+			consistencyChecks: map[string]string{"neo4j": reportFileName},
+		}
+	}
+
+	writeFiles()
+	backend := newFakeBackend("demo")
+	uploader := &databaseUploader{backend: backend, bucketName: "demo", key: make([]byte, 32)}
+	result := newResult()
+	assert.NoError(t, uploader.upload(result))
+	for _, fileName := range []string{backupFileName, reportFileName} {
+		_, err := backend.StatFile(fileName, "demo")
+		assert.NoError(t, err, "%s should be uploaded", fileName)
+		assert.NoFileExists(t, filepath.Join(location, fileName), "%s should be deleted once uploaded", fileName)
+		// the artifacts describe the encrypted files
+		assert.Greater(t, result.artifacts[fileName].Size, int64(len("backup")))
+	}
+	assert.Equal(t, "neo4j", result.artifacts[backupFileName].Database)
+
+	// the files are deleted even if the upload failed so that the following backups still fit
+	writeFiles()
+	uploader = &databaseUploader{backend: backend, bucketName: "missing"}
+	assert.Error(t, uploader.upload(newResult()))
+	assert.NoFileExists(t, filepath.Join(location, backupFileName))
+	assert.NoFileExists(t, filepath.Join(location, reportFileName))
+
+	// nothing to upload when the backup failed
+	assert.NoError(t, uploader.upload(&databaseResult{database: "movies"}))
+}
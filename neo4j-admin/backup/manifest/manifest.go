@@ -0,0 +1,113 @@
+package manifest
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/chain"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/common"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/consistency"
+	"os"
+	"strings"
+	"time"
+)
+
+// filePrefix is the prefix of the manifest file names
+// Ex: backup-manifest-2023-05-04T17-21-27.json
+const filePrefix = "backup-manifest-"
+
+// Manifest records what a backup run produced
+type Manifest struct {
+	ServerAddress string `json:"serverAddress"`
+	// RequestedType is the backup type requested for the run ex: AUTO. The type actually taken is recorded per artifact
+	RequestedType string    `json:"requestedType"`
+	StartTime     time.Time `json:"startTime"`
+	EndTime       time.Time `json:"endTime"`
+	Databases     []string  `json:"databases"`
+	// Encrypted is true when the artifacts were encrypted before upload. Sizes and checksums are of the encrypted files
+	Encrypted         bool               `json:"encrypted,omitempty"`
+	Artifacts         []Artifact         `json:"artifacts"`
+	ConsistencyChecks []ConsistencyCheck `json:"consistencyChecks,omitempty"`
+}
+
+// Artifact is a file generated by the backup run
+type Artifact struct {
+	Name     string `json:"name"`
+	Database string `json:"database,omitempty"`
+	Size     int64  `json:"size"`
+	SHA256   string `json:"sha256"`
+	// Type is the type (FULL or DIFF) of the backup file as reported by neo4j-admin or recorded in its chain
+	// Parent is the previous backup file of the chain of a differential backup when the chain cache is enabled
+	Type   string `json:"type,omitempty"`
+	Parent string `json:"parent,omitempty"`
+}
+
+// ConsistencyCheck is the outcome of the consistency check of a database
+// Report and Findings are set only when inconsistencies are found
+type ConsistencyCheck struct {
+	Database   string                `json:"database"`
+	Consistent bool                  `json:"consistent"`
+	Report     *Artifact             `json:"report,omitempty"`
+	Findings   *consistency.Findings `json:"findings,omitempty"`
+}
+
+// NewArtifact returns the artifact for the file present at the location along with its size and SHA-256 checksum
+func NewArtifact(location string, fileName string) (Artifact, error) {
+	checksums, err := common.FileChecksums(fmt.Sprintf("%s/%s", location, fileName))
+	if err != nil {
+		return Artifact{}, err
+	}
+	artifact := Artifact{
+		Name:   fileName,
+		Size:   checksums.Size,
+		SHA256: checksums.SHA256Hex(),
+	}
+	if backup, ok := common.ParseBackupFileName(fileName); ok {
+		artifact.Database = backup.Database
+		metadata, err := common.LoadFileMetadata(location, fileName)
+		if err != nil {
+			return Artifact{}, err
+		}
+		if metadata[chain.TypeMetadataKey] != "" {
+			link := chain.LinkFromMetadata(fileName, metadata)
+			artifact.Type, artifact.Parent = link.Type, link.Parent
+		}
+	}
+	return artifact, nil
+}
+
+// IsManifestFile returns true if the given file name is a manifest file name
+func IsManifestFile(fileName string) bool {
+	return strings.HasPrefix(fileName, filePrefix) && strings.HasSuffix(fileName, ".json")
+}
+
+// FileName returns the name of the manifest file derived from the start time of the run
+func (m *Manifest) FileName() string {
+	return fmt.Sprintf("%s%s.json", filePrefix, m.StartTime.UTC().Format(common.BackupTimeLayout))
+}
+
+// Write writes the manifest to the location and returns the file name
+func (m *Manifest) Write(location string) (string, error) {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("unable to marshal backup manifest \n Here's why: %v", err)
+	}
+	fileName := m.FileName()
+	filePath := fmt.Sprintf("%s/%s", location, fileName)
+	if err = os.WriteFile(filePath, data, 0644); err != nil {
+		return "", fmt.Errorf("unable to write backup manifest %s \n Here's why: %v", filePath, err)
+	}
+	return fileName, nil
+}
+
+// Read reads the manifest present at the provided path
+func Read(filePath string) (*Manifest, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read backup manifest %s \n Here's why: %v", filePath, err)
+	}
+	var m Manifest
+	if err = json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("unable to parse backup manifest %s \n Here's why: %v", filePath, err)
+	}
+	return &m, nil
+}
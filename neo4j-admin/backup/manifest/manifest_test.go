@@ -0,0 +1,53 @@
+package manifest
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestNewArtifact(t *testing.T) {
+	t.Parallel()
+
+	location := t.TempDir()
+	assert.NoError(t, os.WriteFile(fmt.Sprintf("%s/neo4j-2023-05-04T17-21-27.backup", location), []byte("demo"), 0644))
+
+	artifact, err := NewArtifact(location, "neo4j-2023-05-04T17-21-27.backup")
+	assert.NoError(t, err)
+	assert.Equal(t, "neo4j", artifact.Database)
+	assert.Equal(t, int64(4), artifact.Size)
+	assert.Equal(t, "2a97516c354b68848cdbd8f54a226a0a55b21ed138e207ad6c5cbb9c00aa5aea", artifact.SHA256)
+
+	_, err = NewArtifact(location, "missing.backup")
+	assert.Error(t, err)
+}
+
+func TestWriteAndRead(t *testing.T) {
+	t.Parallel()
+
+	location := t.TempDir()
+	m := &Manifest{
+		ServerAddress: "standalone-admin.default.svc.cluster.local:6362",
+		RequestedType: "FULL",
+		StartTime:     time.Date(2023, 5, 4, 17, 21, 27, 0, time.UTC),
+		EndTime:       time.Date(2023, 5, 4, 17, 25, 0, 0, time.UTC),
+		Databases:     []string{"neo4j"},
+		Artifacts: []Artifact{
+			{Name: "neo4j-2023-05-04T17-21-27.backup", Database: "neo4j", Size: 4, SHA256: "abc"},
+		},
+		ConsistencyChecks: []ConsistencyCheck{
+			{Database: "neo4j", Consistent: true},
+		},
+	}
+	fileName, err := m.Write(location)
+	assert.NoError(t, err)
+	assert.Equal(t, "backup-manifest-2023-05-04T17-21-27.json", fileName)
+	assert.True(t, IsManifestFile(fileName))
+	assert.False(t, IsManifestFile("neo4j-2023-05-04T17-21-27.backup"))
+
+	read, err := Read(fmt.Sprintf("%s/%s", location, fileName))
+	assert.NoError(t, err)
+	assert.Equal(t, m, read)
+}
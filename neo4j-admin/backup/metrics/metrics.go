@@ -0,0 +1,222 @@
+package metrics
+
+import (
+	"fmt"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/consistency"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+)
+
+// defaultJobName is the pushgateway job name used when METRICS_JOB_NAME is not set
+const defaultJobName = "neo4j_backup"
+
+var (
+	registry = prometheus.NewRegistry()
+
+	lastSuccess = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "neo4j_backup_last_success_timestamp_seconds",
+		Help: "Unix timestamp of the last successful backup of the database",
+	}, []string{"database"})
+
+	lastRun = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "neo4j_backup_last_run_timestamp_seconds",
+		Help: "Unix timestamp of the end of the last backup job run",
+	})
+
+	success = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "neo4j_backup_success",
+		Help: "1 if the last backup job run succeeded , 0 otherwise",
+	})
+
+	phaseDuration = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "neo4j_backup_phase_duration_seconds",
+		Help: "Duration of the phases (discovery , connectivity , backup , encryption , upload , retention , replication , hooks) of the last backup job run",
+	}, []string{"phase"})
+
+	databaseDuration = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "neo4j_backup_database_phase_duration_seconds",
+		Help: "Duration of the backup and consistency check of the database in the last backup job run",
+	}, []string{"database", "phase"})
+
+	databaseSuccess = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "neo4j_backup_database_success",
+		Help: "1 if the backup (and consistency check) of the database succeeded in the last backup job run , 0 otherwise",
+	}, []string{"database"})
+
+	retries = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "neo4j_backup_retries",
+		Help: "Number of retries of the operations (connectivity , backup , upload ...) in the last backup job run",
+	}, []string{"operation"})
+
+	uploadedBytes = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "neo4j_backup_uploaded_bytes",
+		Help: "Number of bytes uploaded to the storage backend by the last backup job run",
+	})
+
+	artifactSize = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "neo4j_backup_artifact_size_bytes",
+		Help: "Size of the artifacts generated by the last backup job run",
+	}, []string{"database", "type"})
+
+	inconsistencies = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "neo4j_backup_consistency_check_inconsistencies_found",
+		Help: "1 if the consistency check of the database found inconsistencies , 0 otherwise",
+	}, []string{"database"})
+
+	findings = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "neo4j_backup_consistency_check_findings",
+		Help: "Number of inconsistencies found by the consistency check of the database by severity (error , warning)",
+	}, []string{"database", "severity"})
+
+	findingsByType = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "neo4j_backup_consistency_check_inconsistencies",
+		Help: "Number of inconsistencies found by the consistency check of the database by type (nodes , relationships , indexes , counts , property_owners , other)",
+	}, []string{"database", "type"})
+
+	replicationSuccess = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "neo4j_backup_replication_success",
+		Help: "1 if the files of the last backup job run were replicated to the destination , 0 otherwise",
+	}, []string{"destination"})
+
+	drillSuccess = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "neo4j_backup_drill_success",
+		Help: "1 if the latest backup of the database was restored and found consistent by the last backup drill , 0 otherwise",
+	}, []string{"database"})
+
+	drillVerifiedBackup = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "neo4j_backup_drill_verified_backup_timestamp_seconds",
+		Help: "Unix timestamp of the creation of the latest backup of the database verified by a backup drill",
+	}, []string{"database"})
+)
+
+func init() {
+	registry.MustRegister(lastSuccess, lastRun, success, phaseDuration, databaseDuration, databaseSuccess, retries, uploadedBytes, artifactSize, inconsistencies, findings, findingsByType, replicationSuccess, drillSuccess, drillVerifiedBackup)
+}
+
+// StartPhase starts timing the given phase and returns the function recording its duration
+// Ex: defer metrics.StartPhase("upload")()
+func StartPhase(phase string) func() {
+	start := time.Now()
+	return func() {
+		phaseDuration.WithLabelValues(phase).Set(time.Since(start).Seconds())
+	}
+}
+
+// StartDatabasePhase starts timing the given phase of the database and returns the function recording its duration
+func StartDatabasePhase(database string, phase string) func() {
+	start := time.Now()
+	return func() {
+		databaseDuration.WithLabelValues(database, phase).Set(time.Since(start).Seconds())
+	}
+}
+
+// RecordDatabaseResult records if the backup of the database succeeded or not
+func RecordDatabaseResult(database string, succeeded bool) {
+	var value float64
+	if succeeded {
+		value = 1
+	}
+	databaseSuccess.WithLabelValues(database).Set(value)
+}
+
+// RecordSuccess records the time of the successful backup of the database
+func RecordSuccess(database string, timestamp time.Time) {
+	lastSuccess.WithLabelValues(database).Set(float64(timestamp.Unix()))
+}
+
+// RecordRetry increments the number of retries of the operation
+func RecordRetry(operation string) {
+	retries.WithLabelValues(operation).Inc()
+}
+
+// RecordUpload adds the number of bytes uploaded to the storage backend
+func RecordUpload(bytes int64) {
+	uploadedBytes.Add(float64(bytes))
+}
+
+// RecordArtifact records the size of an artifact of the given type (backup , report) generated for the database
+func RecordArtifact(database string, artifactType string, size int64) {
+	artifactSize.WithLabelValues(database, artifactType).Set(float64(size))
+}
+
+// RecordConsistencyCheck records the outcome of the consistency check of the database
+func RecordConsistencyCheck(database string, consistent bool) {
+	var value float64
+	if !consistent {
+		value = 1
+	}
+	inconsistencies.WithLabelValues(database).Set(value)
+}
+
+// RecordConsistencyFindings records the inconsistencies found by the consistency check of the database
+// Every type is recorded , 0 when absent , so that alerts can rely on the series being present
+func RecordConsistencyFindings(database string, consistencyFindings consistency.Findings) {
+	findings.WithLabelValues(database, "error").Set(float64(consistencyFindings.Errors))
+	findings.WithLabelValues(database, "warning").Set(float64(consistencyFindings.Warnings))
+	for _, inconsistencyType := range consistency.Types {
+		findingsByType.WithLabelValues(database, inconsistencyType).Set(float64(consistencyFindings.ByType[inconsistencyType]))
+	}
+}
+
+// RecordReplication records if the files of the run were replicated to the destination
+func RecordReplication(destination string, succeeded bool) {
+	var value float64
+	if succeeded {
+		value = 1
+	}
+	replicationSuccess.WithLabelValues(destination).Set(value)
+}
+
+// RecordDrillResult records if the backup of the database created at the given time was restored and found consistent by the drill
+func RecordDrillResult(database string, backupTime time.Time, succeeded bool) {
+	if !succeeded {
+		drillSuccess.WithLabelValues(database).Set(0)
+		return
+	}
+	drillSuccess.WithLabelValues(database).Set(1)
+	drillVerifiedBackup.WithLabelValues(database).Set(float64(backupTime.Unix()))
+}
+
+// Publish records the outcome of the job run and pushes the metrics to the pushgateway present at PUSHGATEWAY_URL
+// and / or writes them in the textfile collector format to METRICS_TEXTFILE_PATH
+// Errors are only logged since failing to publish the metrics should not fail the backup
+func Publish(succeeded bool) {
+	if succeeded {
+		success.Set(1)
+	} else {
+		success.Set(0)
+	}
+	lastRun.SetToCurrentTime()
+
+	if url := strings.TrimSpace(os.Getenv("PUSHGATEWAY_URL")); url != "" {
+		if err := pushMetrics(url); err != nil {
+			slog.Warn("Unable to push metrics to pushgateway", "url", url, "error", err)
+		} else {
+			slog.Info("Metrics pushed to pushgateway", "url", url)
+		}
+	}
+	if path := strings.TrimSpace(os.Getenv("METRICS_TEXTFILE_PATH")); path != "" {
+		if err := prometheus.WriteToTextfile(path, registry); err != nil {
+			slog.Warn("Unable to write metrics to textfile", "path", path, "error", err)
+		} else {
+			slog.Info("Metrics written to textfile", "path", path)
+		}
+	}
+}
+
+// pushMetrics adds the metrics to the pushgateway job group
+// Add (POST) is used instead of Push (PUT) so that the last success timestamps of earlier runs are kept when a run fails
+func pushMetrics(url string) error {
+	jobName := os.Getenv("METRICS_JOB_NAME")
+	if jobName == "" {
+		jobName = defaultJobName
+	}
+	if err := push.New(url, jobName).Gatherer(registry).Add(); err != nil {
+		return fmt.Errorf("push failed: %v", err)
+	}
+	return nil
+}
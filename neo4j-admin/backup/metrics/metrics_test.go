@@ -0,0 +1,82 @@
+package metrics
+
+import (
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/consistency"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPublish(t *testing.T) {
+
+	// pushgateway stand-in recording the received requests
+	var method, path, body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method = r.Method
+		path = r.URL.Path
+		data, _ := io.ReadAll(r.Body)
+		body = string(data)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	textfilePath := filepath.Join(t.TempDir(), "neo4j_backup.prom")
+	t.Setenv("PUSHGATEWAY_URL", server.URL)
+	t.Setenv("METRICS_JOB_NAME", "demo-backup")
+	t.Setenv("METRICS_TEXTFILE_PATH", textfilePath)
+
+	stopPhase := StartPhase("upload")
+	stopPhase()
+	RecordSuccess("neo4j", time.Unix(1710064800, 0))
+	RecordUpload(1024)
+	RecordUpload(1024)
+	RecordArtifact("neo4j", "backup", 4096)
+	RecordConsistencyCheck("neo4j", false)
+	RecordConsistencyFindings("neo4j", consistency.Findings{Errors: 3, Warnings: 1, ByType: map[string]int{"nodes": 2, "counts": 2}})
+	RecordReplication("aws:dr-backups", true)
+	RecordReplication("filesystem:/replicas/1/neo4j", false)
+	RecordDrillResult("neo4j", time.Unix(1710064800, 0), true)
+	RecordDrillResult("system", time.Unix(1710064800, 0), false)
+	Publish(true)
+
+	assert.Equal(t, http.MethodPost, method)
+	assert.Equal(t, "/metrics/job/demo-backup", path)
+	// the push body is protobuf encoded and contains the metric names as is
+	assert.Contains(t, body, "neo4j_backup_last_success_timestamp_seconds")
+
+	data, err := os.ReadFile(textfilePath)
+	assert.NoError(t, err)
+	textfile := string(data)
+	for _, line := range []string{
+		`neo4j_backup_last_success_timestamp_seconds{database="neo4j"} 1.7100648e+09`,
+		`neo4j_backup_uploaded_bytes 2048`,
+		`neo4j_backup_artifact_size_bytes{database="neo4j",type="backup"} 4096`,
+		`neo4j_backup_consistency_check_inconsistencies_found{database="neo4j"} 1`,
+		`neo4j_backup_consistency_check_findings{database="neo4j",severity="error"} 3`,
+		`neo4j_backup_consistency_check_findings{database="neo4j",severity="warning"} 1`,
+		`neo4j_backup_consistency_check_inconsistencies{database="neo4j",type="nodes"} 2`,
+		`neo4j_backup_consistency_check_inconsistencies{database="neo4j",type="relationships"} 0`,
+		`neo4j_backup_replication_success{destination="aws:dr-backups"} 1`,
+		`neo4j_backup_replication_success{destination="filesystem:/replicas/1/neo4j"} 0`,
+		`neo4j_backup_drill_success{database="neo4j"} 1`,
+		`neo4j_backup_drill_success{database="system"} 0`,
+		`neo4j_backup_drill_verified_backup_timestamp_seconds{database="neo4j"} 1.7100648e+09`,
+		`neo4j_backup_success 1`,
+		`neo4j_backup_phase_duration_seconds{phase="upload"}`,
+	} {
+		assert.True(t, strings.Contains(textfile, line), "textfile should contain %s\n%s", line, textfile)
+	}
+
+	// failing to push should not panic or fail the run
+	server.Close()
+	Publish(false)
+	data, err = os.ReadFile(textfilePath)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), "neo4j_backup_success 0")
+}
@@ -3,13 +3,13 @@ package neo4j_admin
 import (
 	"fmt"
 	"os"
-	"regexp"
+	"path/filepath"
 	"strings"
 )
 
 // getBackupCommandFlags returns a slice of string containing all the flags to be passed with the neo4j-admin backup command
-func getBackupCommandFlags(address string) []string {
-	database := os.Getenv("DATABASE")
+// database can be a single database name , a comma separated list of names or *
+func getBackupCommandFlags(address string, database string) []string {
 	flags := []string{"database", "backup"}
 	flags = append(flags, fmt.Sprintf("--from=%s", address))
 	flags = append(flags, fmt.Sprintf("--include-metadata=%s", os.Getenv("INCLUDE_METADATA")))
@@ -40,15 +40,17 @@ func getBackupCommandFlags(address string) []string {
 //	maxOffHeapMemory: ""
 //	threads: ""
 //	verbose: true
-func getConsistencyCheckCommandFlags(fileName string, database string) []string {
+//
+// source selects what is checked ex: --from-path=/backups for the backup artifacts or --additional-config=<file> for a restored database
+func getConsistencyCheckCommandFlags(reportPath string, source string, database string) []string {
 	flags := []string{"database", "check"}
 
 	flags = append(flags, fmt.Sprintf("--check-indexes=%s", os.Getenv("CONSISTENCY_CHECK_INDEXES")))
 	flags = append(flags, fmt.Sprintf("--check-graph=%s", os.Getenv("CONSISTENCY_CHECK_GRAPH")))
 	flags = append(flags, fmt.Sprintf("--check-counts=%s", os.Getenv("CONSISTENCY_CHECK_COUNTS")))
 	flags = append(flags, fmt.Sprintf("--check-property-owners=%s", os.Getenv("CONSISTENCY_CHECK_PROPERTYOWNERS")))
-	flags = append(flags, fmt.Sprintf("--report-path=/backups/%s.report", fileName))
-	flags = append(flags, fmt.Sprintf("--from-path=/backups"))
+	flags = append(flags, fmt.Sprintf("--report-path=%s", reportPath))
+	flags = append(flags, source)
 	if len(strings.TrimSpace(os.Getenv("CONSISTENCY_CHECK_THREADS"))) > 0 {
 		flags = append(flags, fmt.Sprintf("--threads=%s", os.Getenv("CONSISTENCY_CHECK_THREADS")))
 	}
@@ -64,18 +66,40 @@ func getConsistencyCheckCommandFlags(fileName string, database string) []string
 	return flags
 }
 
-// retrieveBackupFileNames takes the backup command output and looks for the below string and retrieves the backup file names
-// Ex: Finished artifact creation 'neo4j-2023-05-04T17-21-27.backup' for database 'neo4j', took 121ms.
-func retrieveBackupFileNames(cmdOutput string) ([]string, error) {
-	re := regexp.MustCompile(`Finished artifact creation (.*).backup`)
-	matches := re.FindAllStringSubmatch(cmdOutput, -1)
-	if !(len(matches) > 1) {
-		return nil, fmt.Errorf("regex failed !! cannot retrieve backup file name \n %v", matches)
+// getRestoreCommandFlags returns a slice of string containing all the flags to be passed with the neo4j-admin database restore command
+func getRestoreCommandFlags(backupPath string, database string) []string {
+	flags := []string{"database", "restore"}
+	flags = append(flags, fmt.Sprintf("--from-path=%s", backupPath))
+	if len(strings.TrimSpace(os.Getenv("RESTORE_OVERWRITE_DESTINATION"))) > 0 {
+		flags = append(flags, fmt.Sprintf("--overwrite-destination=%s", os.Getenv("RESTORE_OVERWRITE_DESTINATION")))
+	}
+	if len(strings.TrimSpace(os.Getenv("RESTORE_TO_PATH_DATA"))) > 0 {
+		flags = append(flags, fmt.Sprintf("--to-path-data=%s", os.Getenv("RESTORE_TO_PATH_DATA")))
+	}
+	if len(strings.TrimSpace(os.Getenv("RESTORE_TO_PATH_TXN"))) > 0 {
+		flags = append(flags, fmt.Sprintf("--to-path-txn=%s", os.Getenv("RESTORE_TO_PATH_TXN")))
+	}
+	if len(strings.TrimSpace(os.Getenv("RESTORE_TEMP_PATH"))) > 0 {
+		flags = append(flags, fmt.Sprintf("--temp-path=%s", os.Getenv("RESTORE_TEMP_PATH")))
 	}
-	var backupFileNames []string
-	for _, match := range matches {
-		name := strings.Replace(match[1], "'", "", -1)
-		backupFileNames = append(backupFileNames, fmt.Sprintf("%s.backup", name))
+	if os.Getenv("VERBOSE") == "true" {
+		flags = append(flags, "--verbose")
 	}
-	return backupFileNames, nil
+	flags = append(flags, database)
+	return flags
+}
+
+// getScratchRestoreCommandFlags returns the flags of the neo4j-admin database restore command restoring the database into
+// the data directory under the scratch directory instead of the data directory of the neo4j installation
+func getScratchRestoreCommandFlags(backupPath string, database string, scratchDirectory string) []string {
+	flags := []string{"database", "restore"}
+	flags = append(flags, fmt.Sprintf("--from-path=%s", backupPath))
+	flags = append(flags, "--overwrite-destination=true")
+	flags = append(flags, fmt.Sprintf("--to-path-data=%s", filepath.Join(scratchDirectory, "data", "databases")))
+	flags = append(flags, fmt.Sprintf("--to-path-txn=%s", filepath.Join(scratchDirectory, "data", "transactions")))
+	if os.Getenv("VERBOSE") == "true" {
+		flags = append(flags, "--verbose")
+	}
+	flags = append(flags, database)
+	return flags
 }
@@ -0,0 +1,36 @@
+package neo4j_admin
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestGetRestoreCommandFlags(t *testing.T) {
+	tests := []struct {
+		name      string
+		overwrite string
+		dataPath  string
+		want      []string
+	}{
+		{
+			name: "defaults",
+			want: []string{"database", "restore", "--from-path=/backups/neo4j.backup", "neo4j"},
+		},
+		{
+			name:      "overwrite destination",
+			overwrite: "true",
+			dataPath:  "/data/databases",
+			want:      []string{"database", "restore", "--from-path=/backups/neo4j.backup", "--overwrite-destination=true", "--to-path-data=/data/databases", "neo4j"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("RESTORE_OVERWRITE_DESTINATION", tt.overwrite)
+			t.Setenv("RESTORE_TO_PATH_DATA", tt.dataPath)
+			t.Setenv("RESTORE_TO_PATH_TXN", "")
+			t.Setenv("RESTORE_TEMP_PATH", "")
+			t.Setenv("VERBOSE", "")
+			assert.Equal(t, tt.want, getRestoreCommandFlags("/backups/neo4j.backup", "neo4j"))
+		})
+	}
+}
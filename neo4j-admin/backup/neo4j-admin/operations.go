@@ -3,72 +3,188 @@ package neo4j_admin
 import (
 	"errors"
 	"fmt"
-	"log"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/consistency"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/probe"
+	"log/slog"
 	"os"
 	"os/exec"
-	"strings"
+	"path/filepath"
 	"time"
 )
 
-// CheckDatabaseConnectivity checks if there is connectivity with the provided backup instance or not
-func CheckDatabaseConnectivity(hostPort string) error {
-	address := strings.Split(hostPort, ":")
-	output, err := exec.Command("nc", "-vz", address[0], address[1]).CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("connectivity cannot be established \n output = %s \n err = %v", string(output), err)
-	}
-	outputString := strings.ToLower(string(output))
-	if !strings.Contains(outputString, "succeeded") && !strings.Contains(outputString, "connected") {
-		return fmt.Errorf("connectivity cannot be established. Missing 'succeeded' in output \n output = %s", string(output))
+// CheckDatabaseConnectivity checks if a tcp connection can be established with the backup port of the provided instance or not
+// The backup (catchup) protocol has no public handshake so a successful dial is the strongest check available
+func CheckDatabaseConnectivity(hostPort string, timeout time.Duration) error {
+	if err := probe.TCP(hostPort, timeout); err != nil {
+		return err
 	}
-	log.Printf("Connectivity established with Database %s!!", hostPort)
+	slog.Info("Connectivity established with database", "address", hostPort)
 	return nil
 }
 
-// PerformBackup performs the backup operation and returns the generated backup file name
-func PerformBackup(address string) ([]string, error) {
+// BackupError is the failure of neo4j-admin database backup along with its parsed output
+// The artifacts of the databases which were backed up before the failure are listed in the output
+type BackupError struct {
+	Database string
+	Output   BackupOutput
+	Err      error
+}
+
+func (b *BackupError) Error() string {
+	message := fmt.Sprintf("Backup Failed for database %s !! err = %v , category = %s", b.Database, b.Err, b.Output.Category)
+	if b.Output.Error != "" {
+		message = fmt.Sprintf("%s , %s", message, b.Output.Error)
+	}
+	for _, failed := range b.Output.Failed() {
+		message = fmt.Sprintf("%s ; %s", message, failed)
+	}
+	return message
+}
+
+func (b *BackupError) Unwrap() error {
+	return b.Err
+}
+
+// IsRetryableBackupError classifies the errors returned by PerformBackup. The backup is not retried when a database does not
+// exist or when some databases were already backed up since retrying would back them up again
+func IsRetryableBackupError(err error) bool {
+	var backupError *BackupError
+	if !errors.As(err, &backupError) {
+		return true
+	}
+	return backupError.Output.Category != CategoryDatabaseNotFound && len(backupError.Output.FileNames()) == 0
+}
+
+// PerformBackup performs the backup operation of the given database and returns the parsed output describing the generated
+// backup files. The output of the databases backed up before a failure is returned along with the *BackupError
+func PerformBackup(address string, database string) (BackupOutput, error) {
 
-	databases := strings.ReplaceAll(os.Getenv("DATABASE"), ",", " ")
-	flags := getBackupCommandFlags(address)
-	log.Printf("Printing backup flags %v", flags)
+	logger := slog.With("database", database)
+	flags := getBackupCommandFlags(address, database)
+	logger.Debug("Backup flags", "flags", flags)
 	output, err := exec.Command("neo4j-admin", flags...).CombinedOutput()
+	result := ParseBackupOutput(string(output), "/backups")
 	if err != nil {
-		return nil, fmt.Errorf("Backup Failed for database %s !! output = %s \n err = %v", databases, string(output), err)
+		logger.Error("neo4j-admin database backup failed", "output", string(output), "category", result.Category)
+		if result.Error == "" && len(result.Failed()) == 0 {
+			result.Error = lastLine(string(output))
+		}
+		return result, &BackupError{Database: database, Output: result, Err: err}
 	}
-	log.Printf("Backup Completed for database %s !!", databases)
-	backupFileNames, err := retrieveBackupFileNames(string(output))
-	if err != nil {
-		return nil, err
+	if len(result.FileNames()) == 0 {
+		return BackupOutput{}, fmt.Errorf("Backup of database %s completed but neo4j-admin reported no backup artifact \n %s", database, string(output))
 	}
-	return backupFileNames, nil
+	for _, backup := range result.Databases {
+		logger.Info("Backup completed", "backup_database", backup.Database, "file", backup.FileName, "type", backup.Type, "duration", backup.Duration.String())
+	}
+	return result, nil
 }
 
 // PerformConsistencyCheck performs the consistency check on the backup taken and returns the generated report tar name
-func PerformConsistencyCheck(database string) (string, error) {
+// along with the findings of the report. An empty name means no inconsistencies were found
+func PerformConsistencyCheck(database string) (string, consistency.Findings, error) {
 	timeStamp := time.Now().Format("2006-01-02T15-04-05")
 	fileName := fmt.Sprintf("%s-%s.backup", database, timeStamp)
-	flags := getConsistencyCheckCommandFlags(fileName, database)
-	log.Printf("Printing consistency check flags %v", flags)
-	output, err := exec.Command("neo4j-admin", flags...).CombinedOutput()
-	if err == nil {
-		log.Printf("No inconsistencies found for %s database !! No Inconsistency report generated.", database)
-		return "", nil
-	}
+	return performConsistencyCheck(database, "/backups", fileName, "--from-path=/backups")
+}
+
+// PerformScratchConsistencyCheck performs the consistency check on the database restored by PerformScratchRestore in the
+// scratch directory and returns the name of the report tar written to the report directory along with the findings of the report
+// The report is named after reportName
+func PerformScratchConsistencyCheck(database string, scratchDirectory string, reportDirectory string, reportName string) (string, consistency.Findings, error) {
+	return performConsistencyCheck(database, reportDirectory, reportName, fmt.Sprintf("--additional-config=%s", scratchConfigPath(scratchDirectory)))
+}
 
+// performConsistencyCheck checks the database selected by the source flag and returns the name of the report tar written to
+// the directory along with the findings when inconsistencies are found. An empty name means no inconsistencies were found
+func performConsistencyCheck(database string, directory string, fileName string, source string) (string, consistency.Findings, error) {
+	directoryName := fmt.Sprintf("%s/%s.report", directory, fileName)
+	flags := getConsistencyCheckCommandFlags(directoryName, source, database)
+	logger := slog.With("database", database)
+	logger.Debug("Consistency check flags", "flags", flags)
+	output, err := exec.Command("neo4j-admin", flags...).CombinedOutput()
+	exitCode := 0
 	var me *exec.ExitError
 	if errors.As(err, &me) {
-		log.Printf("Inconsistencies found for %s database. Exit code was %d\n", database, me.ExitCode())
-		log.Printf("Consistency Check Completed !!")
-
-		tarFileName := fmt.Sprintf("/backups/%s.report.tar.gz", fileName)
-		directoryName := fmt.Sprintf("/backups/%s.report", fileName)
-		log.Printf("tarfileName %s directoryName %s", tarFileName, directoryName)
-		_, err = exec.Command("tar", "-czvf", tarFileName, directoryName, "--absolute-names").CombinedOutput()
-		if err != nil {
-			return "", fmt.Errorf("Unable to create a tar archive of consistency check report for database %s !! \n output = %s \n err = %v", database, string(output), err)
+		exitCode = me.ExitCode()
+	} else if err != nil {
+		logger.Error("neo4j-admin database check could not be run", "output", string(output))
+		return "", consistency.Findings{}, fmt.Errorf("Consistency Check Failed for database %s!! err = %v", database, err)
+	}
+
+	result := ParseCheckOutput(string(output), exitCode)
+	switch result.Outcome {
+	case CheckConsistent:
+		logger.Info("No inconsistencies found. No inconsistency report generated", "duration", result.Duration.String())
+		return "", consistency.Findings{}, nil
+	case CheckFailed:
+		logger.Error("neo4j-admin database check failed", "output", string(output), "exit_code", exitCode, "category", result.Category)
+		return "", consistency.Findings{}, fmt.Errorf("Consistency Check Failed for database %s!! err = %v , category = %s , %s", database, err, result.Category, result.Error)
+	}
+	findings, err := consistency.ReadReports(directoryName)
+	if err != nil {
+		logger.Warn("Unable to read the inconsistency report , using the summary printed by the consistency check", "error", err)
+		findings = summaryFindings(result)
+	}
+	logger.Warn("Inconsistencies found. Consistency check completed", "exit_code", exitCode, "errors", findings.Errors, "warnings", findings.Warnings, "findings", findings.ByType, "report", result.ReportPath)
+
+	tarFileName := fmt.Sprintf("%s/%s.report.tar.gz", directory, fileName)
+	logger.Debug("Creating consistency check report tar archive", "tar_file", tarFileName, "directory", directoryName)
+	tarOutput, err := exec.Command("tar", "-czvf", tarFileName, directoryName, "--absolute-names").CombinedOutput()
+	if err != nil {
+		logger.Error("tar of consistency check report failed", "output", string(tarOutput))
+		return "", consistency.Findings{}, fmt.Errorf("Unable to create a tar archive of consistency check report for database %s !! err = %v", database, err)
+	}
+	logger.Info("Consistency check report tar archive created", "tar_file", tarFileName)
+	return fmt.Sprintf("%s.report.tar.gz", fileName), findings, nil
+}
+
+// summaryFindings returns the findings from the summary statistics printed by the consistency check
+func summaryFindings(result CheckOutput) consistency.Findings {
+	findings := consistency.Findings{Errors: result.Errors, Warnings: result.Warnings}
+	for recordType, count := range result.InconsistentRecords {
+		if findings.ByType == nil {
+			findings.ByType = make(map[string]int)
 		}
-		log.Printf("Consistency Check Report tar archive created for database %s at %s !!", database, tarFileName)
-		return fmt.Sprintf("%s.report.tar.gz", fileName), nil
+		findings.ByType[consistency.TypeOf("", recordType)] += count
 	}
-	return "", fmt.Errorf("Consistency Check Failed for database %s!! \n output = %s \n err = %v", database, string(output), err)
+	return findings
+}
+
+// PerformRestore restores the database from the backup artifact present at the provided path
+func PerformRestore(database string, backupPath string) error {
+	flags := getRestoreCommandFlags(backupPath, database)
+	logger := slog.With("database", database)
+	logger.Debug("Restore flags", "flags", flags)
+	output, err := exec.Command("neo4j-admin", flags...).CombinedOutput()
+	if err != nil {
+		logger.Error("neo4j-admin database restore failed", "output", string(output))
+		return fmt.Errorf("Restore Failed for database %s from %s !! err = %v", database, backupPath, err)
+	}
+	logger.Info("Restore completed", "backup", backupPath)
+	return nil
+}
+
+// PerformScratchRestore restores the database from the backup artifact present at the provided path into the scratch directory
+// A neo4j configuration file pointing at the restored data directory is written next to it for PerformScratchConsistencyCheck
+func PerformScratchRestore(database string, backupPath string, scratchDirectory string) error {
+	config := fmt.Sprintf("server.directories.data=%s\n", filepath.Join(scratchDirectory, "data"))
+	if err := os.WriteFile(scratchConfigPath(scratchDirectory), []byte(config), 0644); err != nil {
+		return fmt.Errorf("Unable to write the neo4j configuration of scratch directory %s !! err = %v", scratchDirectory, err)
+	}
+	flags := getScratchRestoreCommandFlags(backupPath, database, scratchDirectory)
+	logger := slog.With("database", database)
+	logger.Debug("Restore flags", "flags", flags)
+	output, err := exec.Command("neo4j-admin", flags...).CombinedOutput()
+	if err != nil {
+		logger.Error("neo4j-admin database restore failed", "output", string(output))
+		return fmt.Errorf("Restore Failed for database %s from %s into %s !! err = %v", database, backupPath, scratchDirectory, err)
+	}
+	logger.Info("Restore into scratch directory completed", "backup", backupPath, "directory", scratchDirectory)
+	return nil
+}
+
+// scratchConfigPath returns the path of the neo4j configuration file of the database restored in the scratch directory
+func scratchConfigPath(scratchDirectory string) string {
+	return filepath.Join(scratchDirectory, "neo4j.conf")
 }
@@ -0,0 +1,306 @@
+package neo4j_admin
+
+import (
+	"fmt"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/chain"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/common"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrorCategory is the kind of failure reported by neo4j-admin
+type ErrorCategory string
+
+const (
+	CategoryNone             ErrorCategory = ""
+	CategoryConnection       ErrorCategory = "connection"
+	CategoryDatabaseNotFound ErrorCategory = "database_not_found"
+	CategoryDiskFull         ErrorCategory = "disk_full"
+	CategoryOutOfMemory      ErrorCategory = "out_of_memory"
+	CategoryPermission       ErrorCategory = "permission"
+	CategoryInconsistencies  ErrorCategory = "inconsistencies"
+	CategoryUnknown          ErrorCategory = "unknown"
+)
+
+// categoryPatterns are matched in order against the error messages so that the most specific category wins
+// Ex: a database which does not exist is reported along with a failed connection to it
+var categoryPatterns = []struct {
+	category ErrorCategory
+	pattern  *regexp.Regexp
+}{
+	{CategoryOutOfMemory, regexp.MustCompile(`(?i)OutOfMemoryError|Cannot allocate memory`)},
+	{CategoryDiskFull, regexp.MustCompile(`(?i)No space left on device|disk quota exceeded`)},
+	{CategoryDatabaseNotFound, regexp.MustCompile(`(?i)database (?:'[^']*' )?does not exist|DatabaseNotFound|database not found|Unknown database`)},
+	{CategoryPermission, regexp.MustCompile(`(?i)Permission denied|AccessDeniedException|Operation not permitted`)},
+	{CategoryConnection, regexp.MustCompile(`(?i)connection refused|unable to connect|could not connect|UnknownHostException|No route to host|connect timed out|ConnectException`)},
+}
+
+var (
+	// Ex: 2024-03-10 02:00:02.252+0000 INFO  [c.n.b.b.a.BackupArtifactsCreator] Finished artifact creation 'neo4j-2024-03-10T02-00-00.backup' for database 'neo4j', took 121ms.
+	artifactRegex = regexp.MustCompile(`Finished artifact creation '?([^'\s]+\.backup)'?(?: for database '([^']+)')?(?:, took ([\dhms ]+))?`)
+	// Ex: 2024-03-10 02:00:00.734+0000 INFO  [c.n.b.v.OnlineBackupExecutor] Using remote server standalone-0.standalone.default.svc.cluster.local:6362 for database 'neo4j'
+	remoteServerRegex = regexp.MustCompile(`Using remote server \S+ for database '([^']+)'`)
+	// Ex: 2024-03-10 02:00:00.921+0000 INFO  [c.n.b.i.BackupOutputMonitor] Start receiving store files
+	storeFilesRegex = regexp.MustCompile(`Start receiving store files`)
+	// Ex: 2024-03-11 02:00:00.915+0000 INFO  [c.n.b.i.BackupOutputMonitor] Start receiving transactions from 2012
+	transactionsRegex = regexp.MustCompile(`Start receiving transactions from`)
+	// Ex: 2024-03-10 02:00:02.260+0000 INFO  [c.n.b.v.OnlineBackupExecutor] Backup of database 'neo4j' completed, took 1s 748ms.
+	backupCompletedRegex = regexp.MustCompile(`Backup of database '([^']+)' completed(?:, took ([\dhms ]+))?`)
+	// Ex: 2024-03-10 02:00:10.530+0000 ERROR [c.n.b.v.OnlineBackupExecutor] Backup of database 'movies' failed: Database 'movies' does not exist
+	backupFailedRegex = regexp.MustCompile(`Backup of database '([^']+)' failed[:,]?\s*(.*)`)
+	// Ex: org.neo4j.cli.CommandFailedException: Execution of backup failed. Connection refused
+	commandFailedRegex = regexp.MustCompile(`(?:CommandFailedException|Backup command failed|Consistency check failed|command failed):\s*(.*)`)
+	// Ex: See '/backups/neo4j.report/inconsistencies-2024-03-10.02.00.12.report' for details.
+	reportPathRegex = regexp.MustCompile(`See '([^']+\.report)' for details`)
+	// Ex: Number of inconsistent NODE records: 2
+	summaryStatisticRegex = regexp.MustCompile(`Number of (errors|warnings|inconsistent (\w+) records): (\d+)`)
+	// Ex: Consistency check completed in 6s 12ms
+	checkDurationRegex = regexp.MustCompile(`(?i)Consistency check (?:completed|finished)[^\n]* in ([\dhms ]+)`)
+)
+
+// DatabaseBackup is the outcome of the backup of a single database
+type DatabaseBackup struct {
+	Database string
+	// FileName and Path are the name and the path of the backup artifact. Empty when the backup failed
+	FileName string
+	Path     string
+	// Duration is the time taken by the backup of the database , or by the artifact creation when the total is not reported
+	Duration time.Duration
+	// Type is FULL when the store files were copied and DIFF when only the transactions since the previous backup were
+	// received. Empty when neo4j-admin did not report it
+	Type      string
+	Succeeded bool
+	Error     string
+	Category  ErrorCategory
+}
+
+// String returns a one line description of the failed backup ex: movies (database_not_found): Database 'movies' does not exist
+func (d DatabaseBackup) String() string {
+	return fmt.Sprintf("%s (%s): %s", d.Database, d.Category, d.Error)
+}
+
+// BackupOutput is the parsed output of neo4j-admin database backup
+type BackupOutput struct {
+	Databases []DatabaseBackup
+	// Error is the reason of the failure of the command. Category is the kind of the failure
+	Error    string
+	Category ErrorCategory
+}
+
+// FileNames returns the names of the generated backup artifacts
+func (b BackupOutput) FileNames() []string {
+	var fileNames []string
+	for _, database := range b.Databases {
+		if database.FileName != "" {
+			fileNames = append(fileNames, database.FileName)
+		}
+	}
+	return fileNames
+}
+
+// Types returns the type (FULL or DIFF) of the generated backup artifacts by file name. Artifacts of unknown type are omitted
+func (b BackupOutput) Types() map[string]string {
+	types := make(map[string]string)
+	for _, database := range b.Databases {
+		if database.FileName != "" && database.Type != "" {
+			types[database.FileName] = database.Type
+		}
+	}
+	return types
+}
+
+// Failed returns the backups of the databases which failed
+func (b BackupOutput) Failed() []DatabaseBackup {
+	var failed []DatabaseBackup
+	for _, database := range b.Databases {
+		if !database.Succeeded {
+			failed = append(failed, database)
+		}
+	}
+	return failed
+}
+
+// ParseBackupOutput parses the output of neo4j-admin database backup run with --to-path=toPath
+// An artifact line is printed for every database which was backed up so a database succeeded when its artifact was created
+func ParseBackupOutput(output string, toPath string) BackupOutput {
+	var result BackupOutput
+	index := make(map[string]int)
+	database := func(name string) *DatabaseBackup {
+		if i, present := index[name]; present {
+			return &result.Databases[i]
+		}
+		index[name] = len(result.Databases)
+		result.Databases = append(result.Databases, DatabaseBackup{Database: name})
+		return &result.Databases[len(result.Databases)-1]
+	}
+
+	// current is the database whose store files or transactions are being received
+	var current string
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if matches := remoteServerRegex.FindStringSubmatch(line); matches != nil {
+			current = matches[1]
+			continue
+		}
+		if storeFilesRegex.MatchString(line) && current != "" {
+			database(current).Type = chain.TypeFull
+			continue
+		}
+		// a full backup also receives the transactions committed during the copy of the store files
+		if transactionsRegex.MatchString(line) && current != "" {
+			if backup := database(current); backup.Type == "" {
+				backup.Type = chain.TypeDiff
+			}
+			continue
+		}
+		if matches := artifactRegex.FindStringSubmatch(line); matches != nil {
+			name := matches[2]
+			if backup, ok := common.ParseBackupFileName(matches[1]); ok && name == "" {
+				name = backup.Database
+			}
+			backup := database(name)
+			backup.FileName, backup.Path, backup.Succeeded = matches[1], filepath.Join(toPath, matches[1]), true
+			if backup.Duration == 0 {
+				backup.Duration = parseDuration(matches[3])
+			}
+			continue
+		}
+		if matches := backupCompletedRegex.FindStringSubmatch(line); matches != nil {
+			backup := database(matches[1])
+			if duration := parseDuration(matches[2]); duration != 0 {
+				backup.Duration = duration
+			}
+			continue
+		}
+		if matches := backupFailedRegex.FindStringSubmatch(line); matches != nil {
+			backup := database(matches[1])
+			backup.Succeeded, backup.Error = false, strings.TrimSpace(matches[2])
+			backup.Category = categorize(line)
+			continue
+		}
+		if matches := commandFailedRegex.FindStringSubmatch(line); matches != nil && result.Error == "" {
+			result.Error = strings.TrimSpace(matches[1])
+		}
+	}
+	for i := range result.Databases {
+		if !result.Databases[i].Succeeded && result.Databases[i].Error == "" {
+			result.Databases[i].Error = "no backup artifact reported"
+			result.Databases[i].Category = CategoryUnknown
+		}
+	}
+	if result.Error != "" || len(result.Failed()) != 0 {
+		result.Category = categorize(output)
+	}
+	return result
+}
+
+// CheckOutcome is the verdict of neo4j-admin database check
+type CheckOutcome string
+
+const (
+	// CheckConsistent means the check completed without finding inconsistencies
+	CheckConsistent CheckOutcome = "consistent"
+	// CheckInconsistent means the check completed and reported inconsistencies
+	CheckInconsistent CheckOutcome = "inconsistent"
+	// CheckFailed means the check could not complete ex: missing database , out of memory
+	CheckFailed CheckOutcome = "failed"
+)
+
+// CheckOutput is the parsed output of neo4j-admin database check
+type CheckOutput struct {
+	Outcome CheckOutcome
+	// ReportPath is the path of the inconsistency report written by neo4j-admin
+	ReportPath string
+	// Errors , Warnings and InconsistentRecords (by record type ex: NODE) are the summary statistics of the inconsistencies
+	Errors              int
+	Warnings            int
+	InconsistentRecords map[string]int
+	Duration            time.Duration
+	Error               string
+	Category            ErrorCategory
+}
+
+// ParseCheckOutput parses the output of neo4j-admin database check along with its exit code
+// neo4j-admin exits with the same code when inconsistencies are found and when the check crashed , so inconsistencies are
+// only recognized by the summary statistics or the report printed by the check
+func ParseCheckOutput(output string, exitCode int) CheckOutput {
+	result := CheckOutput{Outcome: CheckConsistent}
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if matches := summaryStatisticRegex.FindStringSubmatch(line); matches != nil {
+			count, _ := strconv.Atoi(matches[3])
+			switch {
+			case matches[1] == "errors":
+				result.Errors = count
+			case matches[1] == "warnings":
+				result.Warnings = count
+			default:
+				if result.InconsistentRecords == nil {
+					result.InconsistentRecords = make(map[string]int)
+				}
+				result.InconsistentRecords[matches[2]] = count
+			}
+			continue
+		}
+		if matches := reportPathRegex.FindStringSubmatch(line); matches != nil {
+			result.ReportPath = matches[1]
+		}
+		if matches := checkDurationRegex.FindStringSubmatch(line); matches != nil {
+			result.Duration = parseDuration(matches[1])
+		}
+		if matches := commandFailedRegex.FindStringSubmatch(line); matches != nil && result.Error == "" {
+			result.Error = strings.TrimSpace(matches[1])
+		}
+	}
+	if exitCode == 0 {
+		return result
+	}
+
+	inconsistenciesFound := strings.Contains(output, "Inconsistencies found") || result.Errors > 0 || result.Warnings > 0
+	if inconsistenciesFound {
+		result.Outcome, result.Category = CheckInconsistent, CategoryInconsistencies
+		if result.Error == "" {
+			result.Error = "Inconsistencies found"
+		}
+		return result
+	}
+	result.Outcome, result.Category = CheckFailed, categorize(output)
+	if result.Error == "" {
+		result.Error = lastLine(output)
+	}
+	return result
+}
+
+// categorize returns the category of the failure described by the text
+func categorize(text string) ErrorCategory {
+	for _, categoryPattern := range categoryPatterns {
+		if categoryPattern.pattern.MatchString(text) {
+			return categoryPattern.category
+		}
+	}
+	return CategoryUnknown
+}
+
+// parseDuration parses the durations printed by neo4j-admin ex: 121ms , 1s 748ms , 2m 3s. Zero is returned when unparsable
+func parseDuration(value string) time.Duration {
+	duration, err := time.ParseDuration(strings.ReplaceAll(strings.TrimSpace(value), " ", ""))
+	if err != nil {
+		return 0
+	}
+	return duration
+}
+
+// lastLine returns the last non empty line of the output which is not a stack frame , used as the error message when none was recognized
+func lastLine(output string) string {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		line := strings.TrimSpace(lines[i])
+		if line != "" && !strings.HasPrefix(line, "at ") && !strings.HasPrefix(line, "...") {
+			return line
+		}
+	}
+	return ""
+}
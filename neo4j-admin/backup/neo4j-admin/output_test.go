@@ -0,0 +1,111 @@
+package neo4j_admin
+
+import (
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/consistency"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func readFixture(t *testing.T, name string) string {
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	assert.NoError(t, err)
+	return string(data)
+}
+
+func TestParseBackupOutput(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		fixture       string
+		wantFileNames []string
+		wantFailed    map[string]ErrorCategory
+		wantCategory  ErrorCategory
+	}{
+		{fixture: "backup-single.txt", wantFileNames: []string{"neo4j-2024-03-10T02-00-00.backup"}},
+		{fixture: "backup-duplicate-artifact-line.txt", wantFileNames: []string{"neo4j-2024-03-10T02-00-00.backup"}},
+		{fixture: "backup-all.txt", wantFileNames: []string{"neo4j-2024-03-10T02-00-00.backup", "system-2024-03-10T02-00-05.backup"}},
+		{
+			fixture:       "backup-partial-failure.txt",
+			wantFileNames: []string{"neo4j-2024-03-10T02-00-00.backup"},
+			wantFailed:    map[string]ErrorCategory{"movies": CategoryDatabaseNotFound},
+			wantCategory:  CategoryDatabaseNotFound,
+		},
+		{
+			fixture:      "backup-connection-refused.txt",
+			wantFailed:   map[string]ErrorCategory{"neo4j": CategoryConnection},
+			wantCategory: CategoryConnection,
+		},
+		{fixture: "backup-disk-full.txt", wantCategory: CategoryDiskFull},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			output := ParseBackupOutput(readFixture(t, tt.fixture), "/backups")
+			assert.Equal(t, tt.wantFileNames, output.FileNames())
+			failed := make(map[string]ErrorCategory)
+			for _, database := range output.Failed() {
+				assert.NotEmpty(t, database.Error)
+				failed[database.Database] = database.Category
+			}
+			if tt.wantFailed == nil {
+				tt.wantFailed = map[string]ErrorCategory{}
+			}
+			assert.Equal(t, tt.wantFailed, failed)
+			assert.Equal(t, tt.wantCategory, output.Category)
+			if tt.wantCategory != CategoryNone {
+				assert.NotEmpty(t, output.Error)
+			}
+		})
+	}
+
+	output := ParseBackupOutput(readFixture(t, "backup-single.txt"), "/backups")
+	assert.Equal(t, []DatabaseBackup{{
+		Database:  "neo4j",
+		FileName:  "neo4j-2024-03-10T02-00-00.backup",
+		Path:      "/backups/neo4j-2024-03-10T02-00-00.backup",
+		Duration:  1748 * time.Millisecond,
+		Type:      "FULL",
+		Succeeded: true,
+	}}, output.Databases)
+
+	output = ParseBackupOutput(readFixture(t, "backup-all.txt"), "/backups")
+	assert.Equal(t, map[string]string{"neo4j-2024-03-10T02-00-00.backup": "FULL", "system-2024-03-10T02-00-05.backup": "FULL"}, output.Types())
+	output = ParseBackupOutput(readFixture(t, "backup-differential.txt"), "/backups")
+	assert.Equal(t, map[string]string{"neo4j-2024-03-11T02-00-00.backup": "DIFF"}, output.Types())
+}
+
+func TestParseCheckOutput(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		fixture      string
+		exitCode     int
+		wantOutcome  CheckOutcome
+		wantCategory ErrorCategory
+	}{
+		{fixture: "check-consistent.txt", exitCode: 0, wantOutcome: CheckConsistent, wantCategory: CategoryNone},
+		{fixture: "check-inconsistent.txt", exitCode: 1, wantOutcome: CheckInconsistent, wantCategory: CategoryInconsistencies},
+		{fixture: "check-database-not-found.txt", exitCode: 1, wantOutcome: CheckFailed, wantCategory: CategoryDatabaseNotFound},
+		{fixture: "check-out-of-memory.txt", exitCode: 1, wantOutcome: CheckFailed, wantCategory: CategoryOutOfMemory},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			output := ParseCheckOutput(readFixture(t, tt.fixture), tt.exitCode)
+			assert.Equal(t, tt.wantOutcome, output.Outcome)
+			assert.Equal(t, tt.wantCategory, output.Category)
+		})
+	}
+
+	output := ParseCheckOutput(readFixture(t, "check-inconsistent.txt"), 1)
+	assert.Equal(t, 3, output.Errors)
+	assert.Equal(t, 1, output.Warnings)
+	assert.Equal(t, map[string]int{"NODE": 2, "RELATIONSHIP": 1}, output.InconsistentRecords)
+	assert.Equal(t, "/backups/neo4j-2024-03-10T02-00-05.backup.report/inconsistencies-2024-03-10.02.00.12.report", output.ReportPath)
+	assert.Equal(t, 6012*time.Millisecond, output.Duration)
+	assert.Equal(t, consistency.Findings{Errors: 3, Warnings: 1, ByType: map[string]int{"nodes": 2, "relationships": 1}}, summaryFindings(output))
+
+	output = ParseCheckOutput(readFixture(t, "check-out-of-memory.txt"), 1)
+	assert.Contains(t, output.Error, "OutOfMemoryError")
+}
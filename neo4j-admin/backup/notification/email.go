@@ -0,0 +1,64 @@
+package notification
+
+import (
+	"fmt"
+	"net"
+	"net/smtp"
+	"os"
+	"strings"
+)
+
+// emailNotifier sends the result as a plain text email via smtp
+type emailNotifier struct {
+	address  string
+	from     string
+	to       []string
+	username string
+	password string
+	// sendMail is smtp.SendMail. It is replaced in tests
+	sendMail func(address string, auth smtp.Auth, from string, to []string, message []byte) error
+}
+
+// newEmailNotifierFromEnv returns the email notifier configured via the NOTIFY_SMTP_* and NOTIFY_EMAIL_* env variables
+func newEmailNotifierFromEnv(host string) (*emailNotifier, error) {
+	port := strings.TrimSpace(os.Getenv("NOTIFY_SMTP_PORT"))
+	if port == "" {
+		port = "587"
+	}
+	from := strings.TrimSpace(os.Getenv("NOTIFY_EMAIL_FROM"))
+	var to []string
+	for _, address := range strings.Split(os.Getenv("NOTIFY_EMAIL_TO"), ",") {
+		if address = strings.TrimSpace(address); address != "" {
+			to = append(to, address)
+		}
+	}
+	if from == "" || len(to) == 0 {
+		return nil, fmt.Errorf("NOTIFY_EMAIL_FROM and NOTIFY_EMAIL_TO must be set when NOTIFY_SMTP_HOST is set")
+	}
+	return &emailNotifier{
+		address:  net.JoinHostPort(host, port),
+		from:     from,
+		to:       to,
+		username: os.Getenv("NOTIFY_SMTP_USERNAME"),
+		password: os.Getenv("NOTIFY_SMTP_PASSWORD"),
+		sendMail: smtp.SendMail,
+	}, nil
+}
+
+func (e *emailNotifier) Name() string {
+	return "email"
+}
+
+func (e *emailNotifier) Notify(result *Result) error {
+	var auth smtp.Auth
+	if e.username != "" {
+		host, _, _ := net.SplitHostPort(e.address)
+		auth = smtp.PlainAuth("", e.username, e.password, host)
+	}
+	message := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n%s",
+		e.from, strings.Join(e.to, ", "), result.Title(), strings.ReplaceAll(result.Text(), "\n", "\r\n"))
+	if err := e.sendMail(e.address, auth, e.from, e.to, []byte(message)); err != nil {
+		return fmt.Errorf("Couldn't send email via %s. Here's why: %v", e.address, err)
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package notification
+
+import (
+	"fmt"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/manifest"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+// Result is the outcome of a run of the backup binary. It is logged as the run summary and sent to the notifiers
+// FailedDatabases contains the error per database whose backup failed while the others succeeded
+// Replicas are the destinations the files were replicated to
+type Result struct {
+	RunID             string                      `json:"runId"`
+	Operation         string                      `json:"operation"`
+	Status            string                      `json:"status"`
+	StartTime         time.Time                   `json:"startTime"`
+	DurationSeconds   float64                     `json:"durationSeconds"`
+	Databases         []string                    `json:"databases,omitempty"`
+	Bucket            string                      `json:"bucket,omitempty"`
+	Replicas          []string                    `json:"replicas,omitempty"`
+	Files             []string                    `json:"files,omitempty"`
+	Artifacts         []manifest.Artifact         `json:"artifacts,omitempty"`
+	ConsistencyChecks []manifest.ConsistencyCheck `json:"consistencyChecks,omitempty"`
+	FailedDatabases   map[string]string           `json:"failedDatabases,omitempty"`
+	Error             string                      `json:"error,omitempty"`
+}
+
+// Succeeded returns true if the run succeeded
+func (r *Result) Succeeded() bool {
+	return r.Status == StatusSucceeded
+}
+
+// Title returns a one line description of the result ex: "Neo4j backup succeeded for neo4j,system"
+func (r *Result) Title() string {
+	title := fmt.Sprintf("Neo4j %s %s", r.Operation, r.Status)
+	if len(r.Databases) > 0 {
+		title = fmt.Sprintf("%s for %s", title, strings.Join(r.Databases, ","))
+	}
+	return title
+}
+
+// Text returns a human readable description of the result used by the slack and email notifiers
+func (r *Result) Text() string {
+	var builder strings.Builder
+	builder.WriteString(r.Title())
+	builder.WriteString("\n")
+	fmt.Fprintf(&builder, "Run ID: %s\n", r.RunID)
+	fmt.Fprintf(&builder, "Started: %s , Duration: %s\n", r.StartTime.UTC().Format(time.RFC3339), time.Duration(r.DurationSeconds*float64(time.Second)).Round(time.Second))
+	if r.Bucket != "" {
+		fmt.Fprintf(&builder, "Bucket: %s\n", r.Bucket)
+	}
+	if len(r.Replicas) > 0 {
+		fmt.Fprintf(&builder, "Replicated to: %s\n", strings.Join(r.Replicas, " , "))
+	}
+	for _, artifact := range r.Artifacts {
+		fmt.Fprintf(&builder, "Artifact: %s (%d bytes)\n", artifact.Name, artifact.Size)
+	}
+	for _, consistencyCheck := range r.ConsistencyChecks {
+		verdict := "consistent"
+		if !consistencyCheck.Consistent {
+			verdict = "INCONSISTENT"
+		}
+		if consistencyCheck.Findings != nil {
+			verdict = fmt.Sprintf("%s , %s", verdict, consistencyCheck.Findings)
+		}
+		fmt.Fprintf(&builder, "Consistency check of %s: %s\n", consistencyCheck.Database, verdict)
+	}
+	failedDatabases := make([]string, 0, len(r.FailedDatabases))
+	for database := range r.FailedDatabases {
+		failedDatabases = append(failedDatabases, database)
+	}
+	sort.Strings(failedDatabases)
+	for _, database := range failedDatabases {
+		fmt.Fprintf(&builder, "Backup of %s failed: %s\n", database, r.FailedDatabases[database])
+	}
+	if r.Error != "" {
+		fmt.Fprintf(&builder, "Error: %s\n", r.Error)
+	}
+	return builder.String()
+}
+
+// Notifier sends the result of a run to an external system
+type Notifier interface {
+	Name() string
+	Notify(result *Result) error
+}
+
+// NotifiersFromEnv returns the notifiers configured via the NOTIFY_* env variables
+func NotifiersFromEnv() ([]Notifier, error) {
+	var notifiers []Notifier
+	if url := strings.TrimSpace(os.Getenv("NOTIFY_WEBHOOK_URL")); url != "" {
+		notifiers = append(notifiers, &webhookNotifier{url: url})
+	}
+	if url := strings.TrimSpace(os.Getenv("NOTIFY_SLACK_WEBHOOK_URL")); url != "" {
+		notifiers = append(notifiers, &slackNotifier{url: url})
+	}
+	if host := strings.TrimSpace(os.Getenv("NOTIFY_SMTP_HOST")); host != "" {
+		notifier, err := newEmailNotifierFromEnv(host)
+		if err != nil {
+			return nil, err
+		}
+		notifiers = append(notifiers, notifier)
+	}
+	return notifiers, nil
+}
+
+// shouldNotify returns false when NOTIFY_ON is set to failure and the run succeeded
+func shouldNotify(result *Result) bool {
+	return !(strings.TrimSpace(os.Getenv("NOTIFY_ON")) == "failure" && result.Succeeded())
+}
+
+// NotifyAll sends the result to all the configured notifiers
+// Errors are only logged since failing to notify should not change the outcome of the run
+func NotifyAll(result *Result) {
+	if !shouldNotify(result) {
+		return
+	}
+	notifiers, err := NotifiersFromEnv()
+	if err != nil {
+		slog.Warn("Unable to configure notifiers", "error", err)
+		return
+	}
+	for _, notifier := range notifiers {
+		if err = notifier.Notify(result); err != nil {
+			slog.Warn("Unable to send notification", "notifier", notifier.Name(), "error", err)
+			continue
+		}
+		slog.Info("Notification sent", "notifier", notifier.Name())
+	}
+}
@@ -0,0 +1,132 @@
+package notification
+
+import (
+	"encoding/json"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/consistency"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/manifest"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func demoResult(status string) *Result {
+	result := &Result{
+		RunID:           "demo-run",
+		Operation:       "backup",
+		Status:          status,
+		StartTime:       time.Date(2024, 3, 10, 10, 0, 0, 0, time.UTC),
+		DurationSeconds: 65,
+		Databases:       []string{"neo4j"},
+		Bucket:          "demo",
+		Replicas:        []string{"aws:dr-backups"},
+		Artifacts: []manifest.Artifact{
+			{Name: "neo4j-2024-03-10T10-00-00.backup", Database: "neo4j", Size: 4096},
+		},
+		ConsistencyChecks: []manifest.ConsistencyCheck{
+			{Database: "neo4j", Consistent: false, Findings: &consistency.Findings{Errors: 2, ByType: map[string]int{"nodes": 2}}},
+		},
+	}
+	if status == StatusFailed {
+		result.Error = "Backup Failed for database neo4j !! err = exit status 1"
+	}
+	return result
+}
+
+// newStandIn returns a http server standing in for the webhook endpoints which records the received bodies
+func newStandIn(t *testing.T, status int) (*httptest.Server, *[]string) {
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(data))
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server, &bodies
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	server, bodies := newStandIn(t, http.StatusOK)
+	notifier := &webhookNotifier{url: server.URL}
+	assert.NoError(t, notifier.Notify(demoResult(StatusFailed)))
+
+	assert.Len(t, *bodies, 1)
+	var got Result
+	assert.NoError(t, json.Unmarshal([]byte((*bodies)[0]), &got))
+	assert.Equal(t, StatusFailed, got.Status)
+	assert.Equal(t, "neo4j-2024-03-10T10-00-00.backup", got.Artifacts[0].Name)
+	assert.Equal(t, int64(4096), got.Artifacts[0].Size)
+	assert.False(t, got.ConsistencyChecks[0].Consistent)
+	assert.Equal(t, 2, got.ConsistencyChecks[0].Findings.ByType["nodes"])
+	assert.Contains(t, got.Error, "Backup Failed")
+
+	failing, _ := newStandIn(t, http.StatusInternalServerError)
+	assert.Error(t, (&webhookNotifier{url: failing.URL}).Notify(demoResult(StatusFailed)))
+}
+
+func TestSlackNotifier(t *testing.T) {
+	server, bodies := newStandIn(t, http.StatusOK)
+	notifier := &slackNotifier{url: server.URL}
+	assert.NoError(t, notifier.Notify(demoResult(StatusSucceeded)))
+
+	var got map[string]string
+	assert.NoError(t, json.Unmarshal([]byte((*bodies)[0]), &got))
+	assert.True(t, strings.HasPrefix(got["text"], ":white_check_mark: Neo4j backup succeeded for neo4j\n"), got["text"])
+	assert.Contains(t, got["text"], "Duration: 1m5s")
+	assert.Contains(t, got["text"], "Replicated to: aws:dr-backups")
+	assert.Contains(t, got["text"], "Artifact: neo4j-2024-03-10T10-00-00.backup (4096 bytes)")
+	assert.Contains(t, got["text"], "Consistency check of neo4j: INCONSISTENT , 2 errors , 0 warnings (nodes=2)")
+}
+
+func TestEmailNotifier(t *testing.T) {
+	t.Setenv("NOTIFY_SMTP_PORT", "")
+	t.Setenv("NOTIFY_EMAIL_FROM", "backup@example.com")
+	t.Setenv("NOTIFY_EMAIL_TO", "ops@example.com, dba@example.com")
+	t.Setenv("NOTIFY_SMTP_USERNAME", "demo")
+	t.Setenv("NOTIFY_SMTP_PASSWORD", "secret")
+	notifier, err := newEmailNotifierFromEnv("smtp.example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, "smtp.example.com:587", notifier.address)
+
+	var gotTo []string
+	var gotMessage string
+	notifier.sendMail = func(address string, auth smtp.Auth, from string, to []string, message []byte) error {
+		assert.NotNil(t, auth)
+		gotTo = to
+		gotMessage = string(message)
+		return nil
+	}
+	assert.NoError(t, notifier.Notify(demoResult(StatusFailed)))
+	assert.Equal(t, []string{"ops@example.com", "dba@example.com"}, gotTo)
+	assert.Contains(t, gotMessage, "Subject: Neo4j backup failed for neo4j\r\n")
+	assert.Contains(t, gotMessage, "Error: Backup Failed for database neo4j")
+
+	t.Setenv("NOTIFY_EMAIL_TO", "")
+	_, err = newEmailNotifierFromEnv("smtp.example.com")
+	assert.Error(t, err)
+}
+
+func TestNotifyAll(t *testing.T) {
+	webhook, webhookBodies := newStandIn(t, http.StatusOK)
+	slack, slackBodies := newStandIn(t, http.StatusOK)
+	t.Setenv("NOTIFY_WEBHOOK_URL", webhook.URL)
+	t.Setenv("NOTIFY_SLACK_WEBHOOK_URL", slack.URL)
+	t.Setenv("NOTIFY_SMTP_HOST", "")
+
+	t.Setenv("NOTIFY_ON", "failure")
+	NotifyAll(demoResult(StatusSucceeded))
+	assert.Len(t, *webhookBodies, 0, "successful runs should not be notified when NOTIFY_ON is failure")
+
+	NotifyAll(demoResult(StatusFailed))
+	assert.Len(t, *webhookBodies, 1)
+	assert.Len(t, *slackBodies, 1)
+
+	t.Setenv("NOTIFY_ON", "")
+	NotifyAll(demoResult(StatusSucceeded))
+	assert.Len(t, *webhookBodies, 2)
+	assert.Len(t, *slackBodies, 2)
+}
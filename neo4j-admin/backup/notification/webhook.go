@@ -0,0 +1,62 @@
+package notification
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// httpClient is shared by the webhook notifiers. The timeout prevents an unresponsive endpoint from blocking the job
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// webhookNotifier posts the result as json to a generic http endpoint
+type webhookNotifier struct {
+	url string
+}
+
+func (w *webhookNotifier) Name() string {
+	return "webhook"
+}
+
+func (w *webhookNotifier) Notify(result *Result) error {
+	return postJSON(w.url, result)
+}
+
+// slackNotifier posts the result to a slack compatible incoming webhook
+type slackNotifier struct {
+	url string
+}
+
+func (s *slackNotifier) Name() string {
+	return "slack"
+}
+
+func (s *slackNotifier) Notify(result *Result) error {
+	icon := ":white_check_mark:"
+	if !result.Succeeded() {
+		icon = ":x:"
+	}
+	return postJSON(s.url, map[string]string{
+		"text": fmt.Sprintf("%s %s", icon, result.Text()),
+	})
+}
+
+func postJSON(url string, value any) error {
+	body, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	response, err := httpClient.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("Couldn't post to %s. Here's why: %v", url, err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		data, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+		return fmt.Errorf("Post to %s failed with status %s. Response = %s", url, response.Status, string(data))
+	}
+	return nil
+}
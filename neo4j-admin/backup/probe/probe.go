@@ -0,0 +1,106 @@
+package probe
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// DefaultTimeout is used when CONNECTIVITY_TIMEOUT is not set
+const DefaultTimeout = 10 * time.Second
+
+// boltMagic is sent by the client before the version proposals of the bolt handshake
+var boltMagic = []byte{0x60, 0x60, 0xB0, 0x17}
+
+// boltVersions are the proposed bolt versions , each as [0 , range , minor , major]
+// 5.4 down to 5.0 , 4.4 down to 4.2 , 4.1 and 3.0
+var boltVersions = []byte{
+	0x00, 0x04, 0x04, 0x05,
+	0x00, 0x02, 0x04, 0x04,
+	0x00, 0x00, 0x01, 0x04,
+	0x00, 0x00, 0x00, 0x03,
+}
+
+// TimeoutFromEnv returns the timeout of every probe as per CONNECTIVITY_TIMEOUT ex: 10s
+func TimeoutFromEnv() (time.Duration, error) {
+	value := strings.TrimSpace(os.Getenv("CONNECTIVITY_TIMEOUT"))
+	if value == "" {
+		return DefaultTimeout, nil
+	}
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout <= 0 {
+		return 0, fmt.Errorf("invalid CONNECTIVITY_TIMEOUT %s. Value should be a positive duration ex: 10s", value)
+	}
+	return timeout, nil
+}
+
+// TCP checks a tcp connection can be established with the address (host:port)
+func TCP(address string, timeout time.Duration) error {
+	conn, err := net.DialTimeout("tcp", address, timeout)
+	if err != nil {
+		return fmt.Errorf("connectivity cannot be established with %s \n err = %v", address, err)
+	}
+	return conn.Close()
+}
+
+// Bolt performs the bolt handshake with the address and returns the negotiated bolt version ex: 5.4
+func Bolt(address string, timeout time.Duration) (string, error) {
+	conn, err := net.DialTimeout("tcp", address, timeout)
+	if err != nil {
+		return "", fmt.Errorf("connectivity cannot be established with %s \n err = %v", address, err)
+	}
+	defer conn.Close()
+	if err = conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return "", err
+	}
+	if _, err = conn.Write(append(append([]byte{}, boltMagic...), boltVersions...)); err != nil {
+		return "", fmt.Errorf("bolt handshake with %s failed \n err = %v", address, err)
+	}
+	response := make([]byte, 4)
+	if _, err = io.ReadFull(conn, response); err != nil {
+		return "", fmt.Errorf("bolt handshake with %s failed. No response to the version proposals \n err = %v", address, err)
+	}
+	if bytes.Equal(response, []byte{0, 0, 0, 0}) {
+		return "", fmt.Errorf("bolt handshake with %s failed. None of the proposed bolt versions is supported", address)
+	}
+	// HTTP/1.1 ... when the port serves http instead of bolt
+	if response[0] != 0 || response[1] != 0 {
+		return "", fmt.Errorf("bolt handshake with %s failed. Unexpected response %q", address, response)
+	}
+	return fmt.Sprintf("%d.%d", response[3], response[2]), nil
+}
+
+// HTTP fetches the neo4j discovery document at http://address/ and returns the neo4j version
+func HTTP(address string, timeout time.Duration) (string, error) {
+	client := &http.Client{Timeout: timeout}
+	request, err := http.NewRequest(http.MethodGet, fmt.Sprintf("http://%s/", address), nil)
+	if err != nil {
+		return "", err
+	}
+	request.Header.Set("Accept", "application/json")
+	response, err := client.Do(request)
+	if err != nil {
+		return "", fmt.Errorf("connectivity cannot be established with %s \n err = %v", address, err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("http discovery of %s failed with status %s", address, response.Status)
+	}
+	var discovery struct {
+		Neo4jVersion string `json:"neo4j_version"`
+		BoltDirect   string `json:"bolt_direct"`
+	}
+	if err = json.NewDecoder(response.Body).Decode(&discovery); err != nil {
+		return "", fmt.Errorf("http discovery of %s failed. The response is not a neo4j discovery document \n err = %v", address, err)
+	}
+	if discovery.Neo4jVersion == "" && discovery.BoltDirect == "" {
+		return "", fmt.Errorf("http discovery of %s failed. The response is not a neo4j discovery document", address)
+	}
+	return discovery.Neo4jVersion, nil
+}
@@ -0,0 +1,130 @@
+package probe
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const timeout = 2 * time.Second
+
+// boltServer accepts a single connection , reads the handshake and answers with the given response
+func boltServer(t *testing.T, response []byte) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		handshake := make([]byte, 20)
+		if _, err = io.ReadFull(conn, handshake); err != nil {
+			return
+		}
+		assert.Equal(t, boltMagic, handshake[:4])
+		conn.Write(response)
+	}()
+	return listener.Addr().String()
+}
+
+func TestTimeoutFromEnv(t *testing.T) {
+	t.Setenv("CONNECTIVITY_TIMEOUT", "")
+	timeout, err := TimeoutFromEnv()
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultTimeout, timeout)
+
+	t.Setenv("CONNECTIVITY_TIMEOUT", "30s")
+	timeout, err = TimeoutFromEnv()
+	assert.NoError(t, err)
+	assert.Equal(t, 30*time.Second, timeout)
+
+	for _, value := range []string{"10", "-5s", "0s"} {
+		t.Run(value, func(t *testing.T) {
+			t.Setenv("CONNECTIVITY_TIMEOUT", value)
+			_, err := TimeoutFromEnv()
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestTCP(t *testing.T) {
+	t.Parallel()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	address := listener.Addr().String()
+	assert.NoError(t, TCP(address, timeout))
+
+	listener.Close()
+	assert.Error(t, TCP(address, timeout))
+}
+
+func TestBolt(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		response []byte
+		version  string
+		wantErr  bool
+	}{
+		{name: "bolt 5.4", response: []byte{0, 0, 4, 5}, version: "5.4"},
+		{name: "bolt 4.4", response: []byte{0, 0, 4, 4}, version: "4.4"},
+		{name: "no supported version", response: []byte{0, 0, 0, 0}, wantErr: true},
+		{name: "http server", response: []byte("HTTP/1.1 400 Bad Request\r\n"), wantErr: true},
+		{name: "connection closed", response: nil, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			version, err := Bolt(boltServer(t, tt.response), timeout)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.version, version)
+		})
+	}
+}
+
+func TestHTTP(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		version string
+		wantErr bool
+	}{
+		{name: "discovery", status: http.StatusOK, body: `{"bolt_direct":"bolt://localhost:7687","neo4j_version":"5.17.0","neo4j_edition":"enterprise"}`, version: "5.17.0"},
+		{name: "not neo4j", status: http.StatusOK, body: `{"status":"ok"}`, wantErr: true},
+		{name: "not json", status: http.StatusOK, body: `<html></html>`, wantErr: true},
+		{name: "unavailable", status: http.StatusServiceUnavailable, body: ``, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "application/json", r.Header.Get("Accept"))
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			version, err := HTTP(strings.TrimPrefix(server.URL, "http://"), timeout)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.version, version)
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package restore
+
+import (
+	"fmt"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/common"
+	"k8s.io/utils/strings/slices"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Options decides which backups are to be restored
+type Options struct {
+	// Databases to restore. "*" restores all the databases present in the bucket
+	Databases []string
+	// BackupNames are the backup artifacts to restore. Takes precedence over Databases and PointInTime
+	BackupNames []string
+	// PointInTime restores the latest backup taken at or before the given time. Zero value restores the latest backup
+	PointInTime time.Time
+}
+
+// OptionsFromEnv returns the restore options configured via the DATABASE and RESTORE_* env variables
+func OptionsFromEnv() (Options, error) {
+	options := Options{
+		Databases:   splitAndTrim(os.Getenv("DATABASE")),
+		BackupNames: splitAndTrim(os.Getenv("RESTORE_BACKUP_NAMES")),
+	}
+	if value := strings.TrimSpace(os.Getenv("RESTORE_POINT_IN_TIME")); value != "" {
+		pointInTime, err := ParseTime(value)
+		if err != nil {
+			return Options{}, fmt.Errorf("invalid RESTORE_POINT_IN_TIME %s \n Here's why: %v", value, err)
+		}
+		options.PointInTime = pointInTime
+	}
+	if len(options.Databases) == 0 && len(options.BackupNames) == 0 {
+		return Options{}, fmt.Errorf("either DATABASE or RESTORE_BACKUP_NAMES must be provided for restore")
+	}
+	return options, nil
+}
+
+// ParseTime parses the given time either in RFC3339 format or in the backup artifact timestamp format
+// Ex: 2023-05-04T17:21:27Z , 2023-05-04T17-21-27
+func ParseTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse(common.BackupTimeLayout, value)
+}
+
+// SelectBackups returns the backups to be restored, one per database, as per the provided options
+func SelectBackups(files []common.FileInfo, options Options) ([]common.Backup, error) {
+	var backups []common.Backup
+	for _, file := range files {
+		if backup, ok := common.ParseBackupFileName(file.Name); ok {
+			backups = append(backups, backup)
+		}
+	}
+
+	if len(options.BackupNames) != 0 {
+		var selected []common.Backup
+		for _, name := range options.BackupNames {
+			index := -1
+			for i, backup := range backups {
+				if backup.FileName == name {
+					index = i
+					break
+				}
+			}
+			if index == -1 {
+				return nil, fmt.Errorf("backup %s not found", name)
+			}
+			selected = append(selected, backups[index])
+		}
+		return selected, nil
+	}
+
+	latest := make(map[string]common.Backup)
+	for _, backup := range backups {
+		if !slices.Contains(options.Databases, "*") && !slices.Contains(options.Databases, backup.Database) {
+			continue
+		}
+		if !options.PointInTime.IsZero() && backup.Timestamp.After(options.PointInTime) {
+			continue
+		}
+		if current, present := latest[backup.Database]; !present || backup.Timestamp.After(current.Timestamp) {
+			latest[backup.Database] = backup
+		}
+	}
+	for _, database := range options.Databases {
+		if _, present := latest[database]; !present && database != "*" {
+			return nil, fmt.Errorf("no backup found for database %s", database)
+		}
+	}
+	if len(latest) == 0 {
+		return nil, fmt.Errorf("no backups found to restore")
+	}
+
+	var selected []common.Backup
+	for _, backup := range latest {
+		selected = append(selected, backup)
+	}
+	sort.Slice(selected, func(i, j int) bool {
+		return selected[i].Database < selected[j].Database
+	})
+	return selected, nil
+}
+
+func splitAndTrim(value string) []string {
+	var values []string
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
@@ -0,0 +1,85 @@
+package restore
+
+import (
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/common"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestSelectBackups(t *testing.T) {
+	t.Parallel()
+
+	files := []common.FileInfo{
+		{Name: "neo4j-2024-03-10T10-00-00.backup"},
+		{Name: "neo4j-2024-03-09T10-00-00.backup"},
+		{Name: "neo4j-2024-03-08T10-00-00.backup"},
+		{Name: "system-2024-03-10T10-00-00.backup"},
+		{Name: "system-2024-03-07T10-00-00.backup"},
+		{Name: "neo4j-2024-03-10T10-00-00.backup.report.tar.gz"},
+	}
+
+	tests := []struct {
+		name    string
+		options Options
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:    "latest backup of a database",
+			options: Options{Databases: []string{"neo4j"}},
+			want:    []string{"neo4j-2024-03-10T10-00-00.backup"},
+		},
+		{
+			name:    "latest backup of all databases",
+			options: Options{Databases: []string{"*"}},
+			want:    []string{"neo4j-2024-03-10T10-00-00.backup", "system-2024-03-10T10-00-00.backup"},
+		},
+		{
+			name: "point in time",
+			options: Options{
+				Databases:   []string{"neo4j", "system"},
+				PointInTime: time.Date(2024, 3, 9, 12, 0, 0, 0, time.UTC),
+			},
+			want: []string{"neo4j-2024-03-09T10-00-00.backup", "system-2024-03-07T10-00-00.backup"},
+		},
+		{
+			name: "point in time before any backup",
+			options: Options{
+				Databases:   []string{"system"},
+				PointInTime: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+			},
+			wantErr: true,
+		},
+		{
+			name:    "named backup",
+			options: Options{BackupNames: []string{"neo4j-2024-03-08T10-00-00.backup"}},
+			want:    []string{"neo4j-2024-03-08T10-00-00.backup"},
+		},
+		{
+			name:    "missing named backup",
+			options: Options{BackupNames: []string{"neo4j-2024-01-01T10-00-00.backup"}},
+			wantErr: true,
+		},
+		{
+			name:    "missing database",
+			options: Options{Databases: []string{"movies"}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backups, err := SelectBackups(files, tt.options)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			var names []string
+			for _, backup := range backups {
+				names = append(names, backup.FileName)
+			}
+			assert.Equal(t, tt.want, names)
+		})
+	}
+}
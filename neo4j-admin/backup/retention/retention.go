@@ -0,0 +1,276 @@
+package retention
+
+import (
+	"fmt"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/chain"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/common"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/manifest"
+	"k8s.io/utils/strings/slices"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Policy decides which backup files are to be kept
+// A backup is kept if it is selected by any of the keep rules and is not older than MaxAge
+// The latest backup of a database is always kept
+type Policy struct {
+	KeepLast    int
+	KeepDaily   int
+	KeepWeekly  int
+	KeepMonthly int
+	MaxAge      time.Duration
+}
+
+// IsEnabled returns true if at least one of the policy rules is set
+func (p Policy) IsEnabled() bool {
+	return p.hasKeepRules() || p.MaxAge > 0
+}
+
+func (p Policy) hasKeepRules() bool {
+	return p.KeepLast > 0 || p.KeepDaily > 0 || p.KeepWeekly > 0 || p.KeepMonthly > 0
+}
+
+// PolicyFromEnv returns the retention policy configured via the RETENTION_* env variables
+func PolicyFromEnv() (Policy, error) {
+	var policy Policy
+	var err error
+	if policy.KeepLast, err = getCount("RETENTION_KEEP_LAST"); err != nil {
+		return Policy{}, err
+	}
+	if policy.KeepDaily, err = getCount("RETENTION_KEEP_DAILY"); err != nil {
+		return Policy{}, err
+	}
+	if policy.KeepWeekly, err = getCount("RETENTION_KEEP_WEEKLY"); err != nil {
+		return Policy{}, err
+	}
+	if policy.KeepMonthly, err = getCount("RETENTION_KEEP_MONTHLY"); err != nil {
+		return Policy{}, err
+	}
+	if policy.MaxAge, err = ParseDuration(os.Getenv("RETENTION_MAX_AGE")); err != nil {
+		return Policy{}, fmt.Errorf("invalid RETENTION_MAX_AGE %s \n Here's why: %v", os.Getenv("RETENTION_MAX_AGE"), err)
+	}
+	return policy, nil
+}
+
+func getCount(envName string) (int, error) {
+	value := strings.TrimSpace(os.Getenv(envName))
+	if value == "" {
+		return 0, nil
+	}
+	count, err := strconv.Atoi(value)
+	if err != nil || count < 0 {
+		return 0, fmt.Errorf("invalid %s %s. Value should be a non negative number", envName, value)
+	}
+	return count, nil
+}
+
+// ParseDuration parses a go duration string additionally supporting days as unit. Negative durations are rejected
+// Ex: 30d , 36h , 1d12h
+func ParseDuration(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, nil
+	}
+	var days int
+	if index := strings.Index(value, "d"); index != -1 {
+		var err error
+		days, err = strconv.Atoi(value[:index])
+		if err != nil || days < 0 {
+			return 0, fmt.Errorf("invalid number of days in %s", value)
+		}
+		value = value[index+1:]
+	}
+	var duration time.Duration
+	if value != "" {
+		var err error
+		duration, err = time.ParseDuration(value)
+		if err != nil {
+			return 0, err
+		}
+		if duration < 0 {
+			return 0, fmt.Errorf("negative duration %s", value)
+		}
+	}
+	return time.Duration(days)*24*time.Hour + duration, nil
+}
+
+// FilesToDelete returns the names of the backup files which are not retained by the policy along with their consistency
+// check reports and backup manifests. Only backup files of the given databases are considered. "*" considers all databases
+// The parents of a retained differential backup , as recorded in the file metadata , are retained as well since the
+// differential backup cannot be restored without its chain
+// manifests are the backup manifests read from the bucket by file name. They record which reports and backup files were
+// written by the same run , reports and manifests missing from them are kept
+func FilesToDelete(files []common.FileInfo, manifests map[string]*manifest.Manifest, databases []string, policy Policy, now time.Time) []string {
+	if !policy.IsEnabled() {
+		return nil
+	}
+	backupsPerDatabase := make(map[string][]common.Backup)
+	parents := make(map[string]string)
+	for _, file := range files {
+		backup, ok := common.ParseBackupFileName(file.Name)
+		if !ok {
+			continue
+		}
+		if !slices.Contains(databases, "*") && !slices.Contains(databases, backup.Database) {
+			continue
+		}
+		backupsPerDatabase[backup.Database] = append(backupsPerDatabase[backup.Database], backup)
+		if link := chain.LinkFromMetadata(file.Name, file.Metadata); link.Parent != "" {
+			parents[file.Name] = link.Parent
+		}
+	}
+
+	var fileNames []string
+	deleted := make(map[string]bool)
+	for _, backups := range backupsPerDatabase {
+		toDelete := backupsToDelete(backups, policy, now)
+		for _, backup := range keepParents(backups, toDelete, parents) {
+			fileNames = append(fileNames, backup.FileName)
+			deleted[backup.FileName] = true
+		}
+	}
+	present := make(map[string]bool, len(files))
+	for _, file := range files {
+		present[file.Name] = true
+	}
+	fileNames = append(fileNames, reportsToDelete(manifests, present, deleted)...)
+	fileNames = append(fileNames, manifestsToDelete(manifests, present, deleted)...)
+	sort.Strings(fileNames)
+	return fileNames
+}
+
+// reportsToDelete returns the consistency check reports of the deleted backups. A report is named after the time of the
+// consistency check so it is matched with the backup file of the same database recorded in the manifest of its run
+func reportsToDelete(manifests map[string]*manifest.Manifest, present map[string]bool, deleted map[string]bool) []string {
+	var fileNames []string
+	for _, m := range manifests {
+		for _, check := range m.ConsistencyChecks {
+			if check.Report == nil || !present[check.Report.Name] {
+				continue
+			}
+			for _, artifact := range m.Artifacts {
+				if artifact.Database == check.Database && deleted[artifact.Name] {
+					fileNames = append(fileNames, check.Report.Name)
+					break
+				}
+			}
+		}
+	}
+	return fileNames
+}
+
+// manifestsToDelete returns the manifests none of whose backup files is left in the bucket once the deleted backups are deleted
+// ex: the manifest of a run whose backups were deleted by an earlier run
+func manifestsToDelete(manifests map[string]*manifest.Manifest, present map[string]bool, deleted map[string]bool) []string {
+	var fileNames []string
+	for fileName, m := range manifests {
+		if len(m.Artifacts) == 0 {
+			continue
+		}
+		retained := false
+		for _, artifact := range m.Artifacts {
+			if present[artifact.Name] && !deleted[artifact.Name] {
+				retained = true
+				break
+			}
+		}
+		if !retained {
+			fileNames = append(fileNames, fileName)
+		}
+	}
+	return fileNames
+}
+
+// keepParents returns the backups to delete without the parents of the retained backups
+func keepParents(backups []common.Backup, toDelete []common.Backup, parents map[string]string) []common.Backup {
+	deleted := make(map[string]bool)
+	for _, backup := range toDelete {
+		deleted[backup.FileName] = true
+	}
+	for _, backup := range backups {
+		if deleted[backup.FileName] {
+			continue
+		}
+		for parent := parents[backup.FileName]; deleted[parent]; parent = parents[parent] {
+			delete(deleted, parent)
+		}
+	}
+	var remaining []common.Backup
+	for _, backup := range toDelete {
+		if deleted[backup.FileName] {
+			remaining = append(remaining, backup)
+		}
+	}
+	return remaining
+}
+
+// backupsToDelete applies the policy on the backups of a single database
+func backupsToDelete(backups []common.Backup, policy Policy, now time.Time) []common.Backup {
+	// newest first
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].Timestamp.After(backups[j].Timestamp)
+	})
+
+	keep := make([]bool, len(backups))
+	if policy.hasKeepRules() {
+		for i := 0; i < len(backups) && i < policy.KeepLast; i++ {
+			keep[i] = true
+		}
+		keepGenerations(backups, keep, policy.KeepDaily, func(t time.Time) string {
+			return t.Format("2006-01-02")
+		})
+		keepGenerations(backups, keep, policy.KeepWeekly, func(t time.Time) string {
+			year, week := t.ISOWeek()
+			return fmt.Sprintf("%d-%d", year, week)
+		})
+		keepGenerations(backups, keep, policy.KeepMonthly, func(t time.Time) string {
+			return t.Format("2006-01")
+		})
+	} else {
+		for i := range keep {
+			keep[i] = true
+		}
+	}
+	if policy.MaxAge > 0 {
+		for i, backup := range backups {
+			if now.Sub(backup.Timestamp) > policy.MaxAge {
+				keep[i] = false
+			}
+		}
+	}
+	// never delete the latest backup of a database
+	if len(keep) > 0 {
+		keep[0] = true
+	}
+
+	var toDelete []common.Backup
+	for i, backup := range backups {
+		if !keep[i] {
+			toDelete = append(toDelete, backup)
+		}
+	}
+	return toDelete
+}
+
+// keepGenerations marks the newest backup of each of the latest count periods as kept
+// backups must be sorted newest first
+func keepGenerations(backups []common.Backup, keep []bool, count int, period func(time.Time) string) {
+	if count <= 0 {
+		return
+	}
+	seen := make(map[string]bool)
+	for i, backup := range backups {
+		key := period(backup.Timestamp)
+		if seen[key] {
+			continue
+		}
+		if len(seen) == count {
+			return
+		}
+		seen[key] = true
+		keep[i] = true
+	}
+}
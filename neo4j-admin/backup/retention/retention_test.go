@@ -0,0 +1,281 @@
+package retention
+
+import (
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/common"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/manifest"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestParseDuration(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		value    string
+		want     time.Duration
+		wantErr  bool
+		testName string
+	}{
+		{testName: "empty", value: "", want: 0},
+		{testName: "days", value: "30d", want: 30 * 24 * time.Hour},
+		{testName: "hours", value: "36h", want: 36 * time.Hour},
+		{testName: "days and hours", value: "1d12h", want: 36 * time.Hour},
+		{testName: "invalid", value: "xd", wantErr: true},
+		{testName: "negative days", value: "-1d", wantErr: true},
+		{testName: "negative hours", value: "-36h", wantErr: true},
+		{testName: "days and negative hours", value: "1d-12h", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			got, err := ParseDuration(tt.value)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestFilesToDelete(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	files := []common.FileInfo{
+		{Name: "neo4j-2024-03-10T10-00-00.backup"},
+		{Name: "neo4j-2024-03-10T02-00-00.backup"},
+		{Name: "neo4j-2024-03-09T02-00-00.backup"},
+		{Name: "neo4j-2024-03-08T02-00-00.backup"},
+		{Name: "neo4j-2024-02-20T02-00-00.backup"},
+		{Name: "neo4j-2024-01-20T02-00-00.backup"},
+		{Name: "system-2024-03-10T10-00-00.backup"},
+		{Name: "system-2024-01-20T02-00-00.backup"},
+		{Name: "neo4j-2024-03-10T10-00-00.backup.report.tar.gz"},
+	}
+
+	tests := []struct {
+		name      string
+		policy    Policy
+		databases []string
+		want      []string
+	}{
+		{
+			name:      "disabled policy",
+			policy:    Policy{},
+			databases: []string{"*"},
+			want:      nil,
+		},
+		{
+			name:      "keep last two",
+			policy:    Policy{KeepLast: 2},
+			databases: []string{"neo4j"},
+			want: []string{
+				"neo4j-2024-01-20T02-00-00.backup",
+				"neo4j-2024-02-20T02-00-00.backup",
+				"neo4j-2024-03-08T02-00-00.backup",
+				"neo4j-2024-03-09T02-00-00.backup",
+			},
+		},
+		{
+			name:      "keep daily",
+			policy:    Policy{KeepDaily: 2},
+			databases: []string{"neo4j"},
+			want: []string{
+				"neo4j-2024-01-20T02-00-00.backup",
+				"neo4j-2024-02-20T02-00-00.backup",
+				"neo4j-2024-03-08T02-00-00.backup",
+				"neo4j-2024-03-10T02-00-00.backup",
+			},
+		},
+		{
+			name:      "keep last and monthly",
+			policy:    Policy{KeepLast: 1, KeepMonthly: 3},
+			databases: []string{"neo4j"},
+			want: []string{
+				"neo4j-2024-03-08T02-00-00.backup",
+				"neo4j-2024-03-09T02-00-00.backup",
+				"neo4j-2024-03-10T02-00-00.backup",
+			},
+		},
+		{
+			name:      "max age for all databases",
+			policy:    Policy{MaxAge: 30 * 24 * time.Hour},
+			databases: []string{"*"},
+			want: []string{
+				"neo4j-2024-01-20T02-00-00.backup",
+				"system-2024-01-20T02-00-00.backup",
+			},
+		},
+		{
+			name:      "max age never deletes the latest backup",
+			policy:    Policy{MaxAge: time.Hour},
+			databases: []string{"system"},
+			want: []string{
+				"system-2024-01-20T02-00-00.backup",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, FilesToDelete(files, nil, tt.databases, tt.policy, now))
+		})
+	}
+}
+
+func TestFilesToDeleteReportsAndManifests(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	run := func(artifacts []string, reports map[string]string) *manifest.Manifest {
+		m := &manifest.Manifest{}
+		for _, fileName := range artifacts {
+			backup, _ := common.ParseBackupFileName(fileName)
+			m.Artifacts = append(m.Artifacts, manifest.Artifact{Name: fileName, Database: backup.Database})
+		}
+		for database, report := range reports {
+			m.ConsistencyChecks = append(m.ConsistencyChecks, manifest.ConsistencyCheck{Database: database, Report: &manifest.Artifact{Name: report}})
+		}
+		return m
+	}
+	manifests := map[string]*manifest.Manifest{
+		// the consistency check of neo4j finished after the backups of the next run were taken so the report is named after them
+		"backup-manifest-2024-03-08T02-00-00.json": run(
+			[]string{"neo4j-2024-03-08T02-00-01.backup", "system-2024-03-08T02-00-09.backup"},
+			map[string]string{"neo4j": "neo4j-2024-03-09T02-30-00.backup.report.tar.gz"},
+		),
+		"backup-manifest-2024-03-09T02-00-00.json": run(
+			[]string{"neo4j-2024-03-09T02-00-01.backup", "movies-2024-03-09T02-00-03.backup"},
+			map[string]string{"movies": "movies-2024-03-09T02-01-00.backup.report.tar.gz"},
+		),
+		"backup-manifest-2024-03-10T02-00-00.json": run(
+			[]string{"neo4j-2024-03-10T02-00-01.backup", "system-2024-03-10T02-00-09.backup"},
+			map[string]string{"neo4j": "neo4j-2024-03-10T02-04-40.backup.report.tar.gz"},
+		),
+		// manifest of a run whose backups were deleted by an earlier run
+		"backup-manifest-2024-03-01T02-00-00.json": run([]string{"neo4j-2024-03-01T02-00-01.backup"}, nil),
+	}
+	files := []common.FileInfo{
+		{Name: "backup-manifest-2024-03-01T02-00-00.json"},
+		{Name: "backup-manifest-2024-03-08T02-00-00.json"},
+		{Name: "neo4j-2024-03-08T02-00-01.backup"},
+		{Name: "system-2024-03-08T02-00-09.backup"},
+		{Name: "backup-manifest-2024-03-09T02-00-00.json"},
+		{Name: "neo4j-2024-03-09T02-00-01.backup"},
+		{Name: "neo4j-2024-03-09T02-30-00.backup.report.tar.gz"},
+		{Name: "movies-2024-03-09T02-00-03.backup"},
+		{Name: "movies-2024-03-09T02-01-00.backup.report.tar.gz"},
+		{Name: "backup-manifest-2024-03-10T02-00-00.json"},
+		{Name: "neo4j-2024-03-10T02-00-01.backup"},
+		{Name: "neo4j-2024-03-10T02-04-40.backup.report.tar.gz"},
+		{Name: "system-2024-03-10T02-00-09.backup"},
+		// report and manifest of a run whose manifest could not be read
+		{Name: "neo4j-2024-03-07T02-04-40.backup.report.tar.gz"},
+		{Name: "backup-manifest-2024-03-07T02-00-00.json"},
+	}
+
+	tests := []struct {
+		name      string
+		policy    Policy
+		databases []string
+		want      []string
+	}{
+		{
+			// the report sorts after the retained 03-09 backup of neo4j but belongs to the deleted 03-08 one
+			name:      "report named after a later backup",
+			policy:    Policy{KeepLast: 2},
+			databases: []string{"*"},
+			want: []string{
+				"backup-manifest-2024-03-01T02-00-00.json",
+				"neo4j-2024-03-08T02-00-01.backup",
+				"neo4j-2024-03-09T02-30-00.backup.report.tar.gz",
+			},
+		},
+		{
+			name:      "all the backups of a run are deleted",
+			policy:    Policy{KeepLast: 1},
+			databases: []string{"*"},
+			want: []string{
+				"backup-manifest-2024-03-01T02-00-00.json",
+				"backup-manifest-2024-03-08T02-00-00.json",
+				"neo4j-2024-03-08T02-00-01.backup",
+				"neo4j-2024-03-09T02-00-01.backup",
+				"neo4j-2024-03-09T02-30-00.backup.report.tar.gz",
+				"system-2024-03-08T02-00-09.backup",
+			},
+		},
+		{
+			// the manifest of 03-08 still describes the system backup which is not considered
+			name:      "backups of other databases are retained",
+			policy:    Policy{KeepLast: 1},
+			databases: []string{"neo4j"},
+			want: []string{
+				"backup-manifest-2024-03-01T02-00-00.json",
+				"neo4j-2024-03-08T02-00-01.backup",
+				"neo4j-2024-03-09T02-00-01.backup",
+				"neo4j-2024-03-09T02-30-00.backup.report.tar.gz",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, FilesToDelete(files, manifests, tt.databases, tt.policy, now))
+		})
+	}
+}
+
+func TestFilesToDeleteKeepsChains(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	// two chains : 03-06 <- 03-07 <- 03-08 and 03-09 <- 03-10
+	link := func(fileName string, parent string) common.FileInfo {
+		if parent == "" {
+			return common.FileInfo{Name: fileName, Metadata: map[string]string{"backup_type": "FULL", "backup_chain": fileName}}
+		}
+		return common.FileInfo{Name: fileName, Metadata: map[string]string{"backup_type": "DIFF", "backup_parent": parent}}
+	}
+	files := []common.FileInfo{
+		link("neo4j-2024-03-06T02-00-00.backup", ""),
+		link("neo4j-2024-03-07T02-00-00.backup", "neo4j-2024-03-06T02-00-00.backup"),
+		link("neo4j-2024-03-08T02-00-00.backup", "neo4j-2024-03-07T02-00-00.backup"),
+		link("neo4j-2024-03-09T02-00-00.backup", ""),
+		link("neo4j-2024-03-10T02-00-00.backup", "neo4j-2024-03-09T02-00-00.backup"),
+	}
+
+	tests := []struct {
+		name   string
+		policy Policy
+		want   []string
+	}{
+		{
+			name:   "keep last keeps the full backup of the latest chain",
+			policy: Policy{KeepLast: 1},
+			want: []string{
+				"neo4j-2024-03-06T02-00-00.backup",
+				"neo4j-2024-03-07T02-00-00.backup",
+				"neo4j-2024-03-08T02-00-00.backup",
+			},
+		},
+		{
+			name:   "keep daily keeps the whole chain of an older retained backup",
+			policy: Policy{KeepDaily: 3},
+			want:   nil,
+		},
+		{
+			name:   "max age does not break a retained chain",
+			policy: Policy{MaxAge: 24 * time.Hour},
+			want: []string{
+				"neo4j-2024-03-06T02-00-00.backup",
+				"neo4j-2024-03-07T02-00-00.backup",
+				"neo4j-2024-03-08T02-00-00.backup",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, FilesToDelete(files, nil, []string{"neo4j"}, tt.policy, now))
+		})
+	}
+}
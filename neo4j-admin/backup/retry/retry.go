@@ -0,0 +1,148 @@
+package retry
+
+import (
+	"errors"
+	"fmt"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/metrics"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Policy decides how many times and how often a failed operation is retried
+// The backoff doubles after every attempt up to MaxBackoff and is randomised by +/- Jitter (a fraction of the backoff)
+type Policy struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Jitter         float64
+}
+
+// DefaultPolicy is used for the values which are not set via the RETRY_* env variables
+var DefaultPolicy = Policy{
+	MaxAttempts:    3,
+	InitialBackoff: 5 * time.Second,
+	MaxBackoff:     2 * time.Minute,
+	Jitter:         0.2,
+}
+
+// sleep is time.Sleep. It is replaced in tests
+var sleep = time.Sleep
+
+// PolicyFromEnv returns the retry policy configured via RETRY_MAX_ATTEMPTS , RETRY_INITIAL_BACKOFF , RETRY_MAX_BACKOFF and RETRY_JITTER
+func PolicyFromEnv() (Policy, error) {
+	policy := DefaultPolicy
+	if value := strings.TrimSpace(os.Getenv("RETRY_MAX_ATTEMPTS")); value != "" {
+		attempts, err := strconv.Atoi(value)
+		if err != nil || attempts < 1 {
+			return Policy{}, fmt.Errorf("invalid RETRY_MAX_ATTEMPTS %s. Value should be a positive number", value)
+		}
+		policy.MaxAttempts = attempts
+	}
+	if value := strings.TrimSpace(os.Getenv("RETRY_INITIAL_BACKOFF")); value != "" {
+		backoff, err := time.ParseDuration(value)
+		if err != nil || backoff < 0 {
+			return Policy{}, fmt.Errorf("invalid RETRY_INITIAL_BACKOFF %s. Value should be a duration ex: 5s", value)
+		}
+		policy.InitialBackoff = backoff
+	}
+	if value := strings.TrimSpace(os.Getenv("RETRY_MAX_BACKOFF")); value != "" {
+		backoff, err := time.ParseDuration(value)
+		if err != nil || backoff < 0 {
+			return Policy{}, fmt.Errorf("invalid RETRY_MAX_BACKOFF %s. Value should be a duration ex: 2m", value)
+		}
+		policy.MaxBackoff = backoff
+	}
+	if value := strings.TrimSpace(os.Getenv("RETRY_JITTER")); value != "" {
+		jitter, err := strconv.ParseFloat(value, 64)
+		if err != nil || jitter < 0 || jitter > 1 {
+			return Policy{}, fmt.Errorf("invalid RETRY_JITTER %s. Value should be between 0 and 1", value)
+		}
+		policy.Jitter = jitter
+	}
+	return policy, nil
+}
+
+// Backoff returns the time to wait after the given failed attempt (starting at 1)
+func (p Policy) Backoff(attempt int) time.Duration {
+	backoff := p.InitialBackoff
+	for i := 1; i < attempt; i++ {
+		if p.MaxBackoff > 0 && backoff >= p.MaxBackoff {
+			break
+		}
+		backoff *= 2
+	}
+	if p.MaxBackoff > 0 && backoff > p.MaxBackoff {
+		backoff = p.MaxBackoff
+	}
+	if p.Jitter > 0 {
+		backoff += time.Duration((rand.Float64()*2 - 1) * p.Jitter * float64(backoff))
+	}
+	return backoff
+}
+
+// permanentError marks an error which should not be retried whatever the classification
+type permanentError struct {
+	err error
+}
+
+func (p *permanentError) Error() string {
+	return p.err.Error()
+}
+
+func (p *permanentError) Unwrap() error {
+	return p.err
+}
+
+// Permanent marks the error as not retryable ex: invalid configuration or a missing local file
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// IsRetryableStatusCode returns true for the http status codes of transient failures i.e. timeouts , throttling and server errors
+func IsRetryableStatusCode(statusCode int) bool {
+	return statusCode == http.StatusRequestTimeout || statusCode == http.StatusTooManyRequests || statusCode >= 500
+}
+
+// Always classifies every error as retryable
+func Always(error) bool {
+	return true
+}
+
+// Do runs fn until it succeeds , fails with an error which is not retryable or the policy runs out of attempts
+// The retry policy is read from the RETRY_* env variables. operation names the step in the logs and metrics ex: upload
+func Do(operation string, isRetryable func(error) bool, fn func() error) error {
+	policy, err := PolicyFromEnv()
+	if err != nil {
+		return err
+	}
+	return DoWithPolicy(operation, policy, isRetryable, fn)
+}
+
+// DoWithPolicy is Do with the provided retry policy
+func DoWithPolicy(operation string, policy Policy, isRetryable func(error) bool, fn func() error) error {
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = fn(); err == nil {
+			return nil
+		}
+		var permanent *permanentError
+		if attempt >= policy.MaxAttempts || errors.As(err, &permanent) || !isRetryable(err) {
+			if attempt > 1 {
+				slog.Error("Operation failed after retries", "operation", operation, "attempts", attempt, "error", err)
+			}
+			return err
+		}
+		backoff := policy.Backoff(attempt)
+		slog.Warn("Operation failed , retrying", "operation", operation, "attempt", attempt, "max_attempts", policy.MaxAttempts, "backoff", backoff.String(), "error", err)
+		metrics.RecordRetry(operation)
+		sleep(backoff)
+	}
+}
@@ -0,0 +1,115 @@
+package retry
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// stubSleep replaces sleep and records the requested backoffs
+func stubSleep(t *testing.T) *[]time.Duration {
+	var backoffs []time.Duration
+	sleep = func(duration time.Duration) {
+		backoffs = append(backoffs, duration)
+	}
+	t.Cleanup(func() {
+		sleep = time.Sleep
+	})
+	return &backoffs
+}
+
+func TestDoWithPolicy(t *testing.T) {
+	policy := Policy{MaxAttempts: 3, InitialBackoff: time.Second, MaxBackoff: time.Minute}
+	transient := errors.New("connection reset by peer")
+
+	tests := []struct {
+		name             string
+		failures         int
+		err              error
+		isRetryable      func(error) bool
+		expectedAttempts int
+		expectedBackoffs []time.Duration
+		expectError      bool
+	}{
+		{name: "succeeds at once", failures: 0, err: transient, isRetryable: Always, expectedAttempts: 1},
+		{name: "succeeds after retries", failures: 2, err: transient, isRetryable: Always, expectedAttempts: 3, expectedBackoffs: []time.Duration{time.Second, 2 * time.Second}},
+		{name: "runs out of attempts", failures: 5, err: transient, isRetryable: Always, expectedAttempts: 3, expectedBackoffs: []time.Duration{time.Second, 2 * time.Second}, expectError: true},
+		{name: "not retryable", failures: 5, err: transient, isRetryable: func(error) bool { return false }, expectedAttempts: 1, expectError: true},
+		{name: "permanent", failures: 5, err: Permanent(transient), isRetryable: Always, expectedAttempts: 1, expectError: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			backoffs := stubSleep(t)
+			attempts := 0
+			err := DoWithPolicy("upload", policy, test.isRetryable, func() error {
+				attempts++
+				if attempts <= test.failures {
+					return test.err
+				}
+				return nil
+			})
+			assert.Equal(t, test.expectError, err != nil)
+			if test.expectError {
+				assert.ErrorIs(t, err, transient)
+			}
+			assert.Equal(t, test.expectedAttempts, attempts)
+			assert.Equal(t, test.expectedBackoffs, *backoffs)
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	policy := Policy{InitialBackoff: 5 * time.Second, MaxBackoff: 30 * time.Second}
+	assert.Equal(t, 5*time.Second, policy.Backoff(1))
+	assert.Equal(t, 10*time.Second, policy.Backoff(2))
+	assert.Equal(t, 20*time.Second, policy.Backoff(3))
+	assert.Equal(t, 30*time.Second, policy.Backoff(4))
+	assert.Equal(t, 30*time.Second, policy.Backoff(100))
+
+	policy.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		backoff := policy.Backoff(2)
+		assert.True(t, backoff >= 5*time.Second && backoff <= 15*time.Second, backoff.String())
+	}
+}
+
+func TestPolicyFromEnv(t *testing.T) {
+	t.Setenv("RETRY_MAX_ATTEMPTS", "")
+	t.Setenv("RETRY_INITIAL_BACKOFF", "")
+	t.Setenv("RETRY_MAX_BACKOFF", "")
+	t.Setenv("RETRY_JITTER", "")
+	policy, err := PolicyFromEnv()
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultPolicy, policy)
+
+	t.Setenv("RETRY_MAX_ATTEMPTS", "5")
+	t.Setenv("RETRY_INITIAL_BACKOFF", "1s")
+	t.Setenv("RETRY_MAX_BACKOFF", "1m")
+	t.Setenv("RETRY_JITTER", "0")
+	policy, err = PolicyFromEnv()
+	assert.NoError(t, err)
+	assert.Equal(t, Policy{MaxAttempts: 5, InitialBackoff: time.Second, MaxBackoff: time.Minute}, policy)
+
+	for key, value := range map[string]string{
+		"RETRY_MAX_ATTEMPTS":    "0",
+		"RETRY_INITIAL_BACKOFF": "5",
+		"RETRY_MAX_BACKOFF":     "-1m",
+		"RETRY_JITTER":          "2",
+	} {
+		t.Run(key, func(t *testing.T) {
+			t.Setenv(key, value)
+			_, err := PolicyFromEnv()
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestIsRetryableStatusCode(t *testing.T) {
+	assert.True(t, IsRetryableStatusCode(http.StatusRequestTimeout))
+	assert.True(t, IsRetryableStatusCode(http.StatusTooManyRequests))
+	assert.True(t, IsRetryableStatusCode(http.StatusServiceUnavailable))
+	assert.False(t, IsRetryableStatusCode(http.StatusNotFound))
+	assert.False(t, IsRetryableStatusCode(http.StatusForbidden))
+}
@@ -86,3 +86,12 @@ limits:
   {{- end }}
 
 {{- end -}}
+
+{{/* serviceAccountName of the backup job. A service account is created for the discovery when serviceAccountName is empty */}}
+{{- define "neo4j.backup.serviceAccountName" -}}
+    {{- if .Values.serviceAccountName -}}
+        {{- .Values.serviceAccountName -}}
+    {{- else if .Values.backup.discovery.enabled -}}
+        {{- printf "%s-discovery" (include "neo4j.fullname" .) -}}
+    {{- end -}}
+{{- end -}}
@@ -41,7 +41,7 @@
 
 {{/* checks if serviceAccountName is provided or not  when secretName is missing */}}
 {{- define "neo4j.backup.checkServiceAccountName" -}}
-    {{- if and (empty .Values.serviceAccountName) (empty .Values.backup.secretName) (not (empty .Values.backup.cloudProvider)) -}}
+    {{- if and (empty .Values.serviceAccountName) (empty .Values.backup.secretName) (not (empty .Values.backup.cloudProvider)) (ne .Values.backup.cloudProvider "filesystem") -}}
         {{ fail (printf "Please provide either secretName or serviceAccountName. Both cannot be empty. Please set only one of them via --set backup.secretName or --set serviceAccountName") }}
     {{- end -}}
 {{- end -}}
@@ -55,7 +55,141 @@
     {{- end -}}
 {{- end -}}
 
+{{/* checks if destinationVolume is provided when cloudProvider is filesystem */}}
+{{- define "neo4j.backup.checkDestinationVolume" -}}
+    {{- if and (eq .Values.backup.cloudProvider "filesystem") (empty .Values.destinationVolume) -}}
+        {{ fail (printf "Empty destinationVolume. Please set destinationVolume when cloudProvider is filesystem") }}
+    {{- end -}}
+{{- end -}}
+
+{{/* checks if secretKeyName is provided when the encryption key secret is set */}}
+{{- define "neo4j.backup.checkEncryption" -}}
+    {{- if and .Values.backup.encryption.secretName (empty .Values.backup.encryption.secretKeyName) -}}
+        {{ fail (printf "Empty encryption secretKeyName. Please set secretKeyName via --set backup.encryption.secretKeyName") }}
+    {{- end -}}
+{{- end -}}
+
+{{- define "neo4j.backup.checkUploadPerDatabase" -}}
+    {{- if and .Values.backup.uploadPerDatabase (empty (.Values.backup.cloudProvider | trim)) -}}
+        {{ fail (printf "Uploading per database requires a cloudProvider. Please set cloudProvider via --set backup.cloudProvider or disable uploadPerDatabase") }}
+    {{- end -}}
+{{- end -}}
+
+{{- define "neo4j.backup.checkChainCache" -}}
+    {{- $chainCache := .Values.backup.chainCache | default "none" | trim -}}
+    {{- if not (has $chainCache (list "none" "local" "download")) -}}
+        {{ fail (printf "Invalid chainCache %s. Please set chainCache to one of none , local or download" $chainCache) }}
+    {{- end -}}
+    {{- if and (eq $chainCache "download") (empty (.Values.backup.cloudProvider | trim)) -}}
+        {{ fail (printf "Downloading the backup chain requires a cloudProvider. Please set cloudProvider via --set backup.cloudProvider or use chainCache local") }}
+    {{- end -}}
+{{- end -}}
+
+{{- define "neo4j.backup.checkDiscovery" -}}
+    {{- if .Values.backup.discovery.enabled -}}
+        {{- if empty (.Values.backup.discovery.neo4jName | trim) -}}
+            {{ fail (printf "Empty discovery neo4jName. Please set neo4jName via --set backup.discovery.neo4jName") }}
+        {{- end -}}
+        {{- if or (.Values.backup.databaseAdminServiceName | default "" | trim) (.Values.backup.databaseAdminServiceIP | default "" | trim) -}}
+            {{ fail (printf "Please set either discovery.enabled or databaseAdminServiceName / databaseAdminServiceIP. Cannot use both") }}
+        {{- end -}}
+    {{- end -}}
+{{- end -}}
+
+{{- define "neo4j.backup.checkBackupEndpoints" -}}
+    {{- if (.Values.backup.databaseBackupEndpoints | default "" | trim) -}}
+        {{- if or .Values.backup.discovery.enabled (.Values.backup.databaseAdminServiceName | default "" | trim) (.Values.backup.databaseAdminServiceIP | default "" | trim) -}}
+            {{ fail (printf "Please set either databaseBackupEndpoints or discovery.enabled / databaseAdminServiceName / databaseAdminServiceIP. Cannot use both") }}
+        {{- end -}}
+    {{- end -}}
+{{- end -}}
+
+{{/* the drill restores the backups present in the bucket so no backup server is needed */}}
+{{- define "neo4j.backup.checkDrill" -}}
+    {{- if and .Values.drill.enabled (empty (.Values.backup.cloudProvider | trim)) -}}
+        {{ fail (printf "The backup drill verifies the backups present in the bucket and requires a cloudProvider. Please set cloudProvider via --set backup.cloudProvider") }}
+    {{- end -}}
+{{- end -}}
+
+{{/* the restore restores the backups present in the bucket , or in /backups without cloudProvider , so no backup server is needed */}}
+{{- define "neo4j.backup.checkRestore" -}}
+    {{- if .Values.restore.enabled -}}
+        {{- if .Values.drill.enabled -}}
+            {{ fail (printf "Please set either restore.enabled or drill.enabled. Cannot use both") }}
+        {{- end -}}
+        {{- if and (empty (.Values.backup.database | default "" | trim)) (empty (.Values.restore.backupNames | default "" | trim)) -}}
+            {{ fail (printf "Empty database and restore backupNames. Please set the databases to restore via --set backup.database or --set restore.backupNames") }}
+        {{- end -}}
+        {{- $pointInTime := .Values.restore.pointInTime | default "" | trim -}}
+        {{- if and $pointInTime (not (regexMatch "^[0-9]{4}-[0-9]{2}-[0-9]{2}T[0-9]{2}[-:][0-9]{2}[-:][0-9]{2}(Z|[+-][0-9]{2}:[0-9]{2})?$" $pointInTime)) -}}
+            {{ fail (printf "Invalid restore pointInTime %s. Please set pointInTime in RFC3339 (ex: 2024-03-10T10:00:00Z) or backup timestamp (ex: 2024-03-10T10-00-00) format" $pointInTime) }}
+        {{- end -}}
+        {{- range $name, $path := pick .Values.restore "toPathData" "toPathTxn" "tempPath" -}}
+            {{- $path = $path | default "" | trim -}}
+            {{- if and $path (not (hasPrefix "/" $path)) -}}
+                {{ fail (printf "Invalid restore %s %s. Please set %s to an absolute path" $name $path $name) }}
+            {{- end -}}
+        {{- end -}}
+    {{- end -}}
+{{- end -}}
+
+{{- define "neo4j.backup.checkConsistencyCheckFailureThreshold" -}}
+    {{- $threshold := .Values.consistencyCheck.failureThreshold | default "" | toString | trim -}}
+    {{- if not (regexMatch "^[0-9]*$" $threshold) -}}
+        {{ fail (printf "Invalid consistencyCheck failureThreshold %s. Please set failureThreshold to a number of errors or 0 to never fail the job" $threshold) }}
+    {{- end -}}
+{{- end -}}
+
+{{/* the webhook urls are credentials so they are only read from the secret set in notifications.webhookSecretName */}}
+{{- define "neo4j.backup.checkNotifications" -}}
+    {{- range $name := list "webhookUrl" "slackWebhookUrl" -}}
+        {{- if index $.Values.notifications $name -}}
+            {{ fail (printf "notifications.%s is not supported. Please store the url under the key %s of the secret set via --set notifications.webhookSecretName" $name $name) }}
+        {{- end -}}
+    {{- end -}}
+{{- end -}}
+
+{{- define "neo4j.backup.checkHooks" -}}
+    {{- range $name, $hook := pick .Values.hooks "preBackup" "postUpload" -}}
+        {{- $failurePolicy := $hook.failurePolicy | default "fail" | trim -}}
+        {{- if not (has $failurePolicy (list "fail" "ignore")) -}}
+            {{ fail (printf "Invalid hooks %s failurePolicy %s. Please set failurePolicy to fail or ignore" $name $failurePolicy) }}
+        {{- end -}}
+        {{- $url := $hook.url | default "" | trim -}}
+        {{- if and $url (not (regexMatch "^https?://" $url)) -}}
+            {{ fail (printf "Invalid hooks %s url %s. Please set url to a http or https url" $name $url) }}
+        {{- end -}}
+    {{- end -}}
+    {{- $url := .Values.hooks.onFailure.url | default "" | trim -}}
+    {{- if and $url (not (regexMatch "^https?://" $url)) -}}
+        {{ fail (printf "Invalid hooks onFailure url %s. Please set url to a http or https url" $url) }}
+    {{- end -}}
+{{- end -}}
+
+{{/* the replicas are copies of the files uploaded to the primary bucket */}}
+{{- define "neo4j.backup.checkReplicas" -}}
+    {{- if and .Values.backup.replicas (empty (.Values.backup.cloudProvider | trim)) -}}
+        {{ fail (printf "Backup replication copies the files uploaded to the primary bucket and requires a cloudProvider. Please set cloudProvider via --set backup.cloudProvider") }}
+    {{- end -}}
+    {{- range $index, $replica := .Values.backup.replicas -}}
+        {{- $cloudProvider := $replica.cloudProvider | default "" | trim -}}
+        {{- if not (has $cloudProvider (list "aws" "gcp" "azure" "filesystem")) -}}
+            {{ fail (printf "Invalid cloudProvider %s for replica %d. Please set cloudProvider to one of aws , gcp , azure or filesystem" $cloudProvider $index) }}
+        {{- end -}}
+        {{- if empty ($replica.bucketName | default "" | trim) -}}
+            {{ fail (printf "Empty bucketName for replica %d. Please set bucketName" $index) }}
+        {{- end -}}
+        {{- if and $replica.secretName (empty $replica.secretKeyName) -}}
+            {{ fail (printf "Empty secretKeyName for replica %d. Please set secretKeyName along with secretName" $index) }}
+        {{- end -}}
+        {{- if and (eq $cloudProvider "filesystem") (empty $replica.volume) -}}
+            {{ fail (printf "Empty volume for the filesystem replica %d. Please set the volume the files are copied to" $index) }}
+        {{- end -}}
+    {{- end -}}
+{{- end -}}
+
 {{- define "neo4j.backup.checkDatabaseIPAndServiceName" -}}
+  {{- if not (or .Values.drill.enabled .Values.restore.enabled .Values.backup.discovery.enabled (.Values.backup.databaseBackupEndpoints | default "" | trim)) -}}
 
     {{- if and (kindIs "invalid" .Values.backup.databaseAdminServiceName) (kindIs "invalid" .Values.backup.databaseAdminServiceIP) -}}
         {{- fail (printf "Missing fields. Please set databaseAdminServiceName via --set backup.databaseAdminServiceName or databaseAdminServiceIP via --set backup.databaseAdminServiceIP")}}
@@ -69,4 +203,5 @@
         {{- fail (printf "Please set databaseAdminServiceName via --set backup.databaseAdminServiceName or databaseAdminServiceIP via --set backup.databaseAdminServiceIP. Cannot use both")}}
     {{- end -}}
 
+  {{- end -}}
 {{- end -}}
@@ -3,32 +3,40 @@ package operations
 import (
 	"fmt"
 	"log"
+	"net"
 	"os"
-	"os/exec"
-	"strings"
+	"reverse-proxy/probe"
 )
 
 // CheckConnectivity checks if there is connectivity with the provided kubernetes service or not
+// The ports 7474 and 7687 are always checked with a tcp dial. The bolt handshake (PROBE_BOLT_PORT) and the http discovery
+// (PROBE_HTTP_PORT) are optional like for the backup job and ensure neo4j , and not just any process , serves the ports
 func CheckConnectivity(hostname string) error {
-	ports := []string{"7474", "7687"}
-	for _, port := range ports {
-		hostPort := fmt.Sprintf("%s:%s", hostname, port)
-		output, err := exec.Command("nc", "-vz", "-w", "3", hostname, port).CombinedOutput()
+	timeout, err := probe.TimeoutFromEnv()
+	if err != nil {
+		return err
+	}
+	for _, port := range []string{"7474", "7687"} {
+		hostPort := net.JoinHostPort(hostname, port)
+		if err = probe.TCP(hostPort, timeout); err != nil {
+			return err
+		}
+		log.Printf("Connectivity established with Service %s!!", hostPort)
+	}
+	if port := os.Getenv("PROBE_BOLT_PORT"); port != "" {
+		version, err := probe.Bolt(net.JoinHostPort(hostname, port), timeout)
 		if err != nil {
-			return fmt.Errorf("connectivity cannot be established with %s \n output = %s \n err = %v",
-				hostPort,
-				string(output),
-				err)
+			return err
 		}
-		outputString := strings.ToLower(string(output))
-		if !strings.Contains(outputString, "open") && !strings.Contains(outputString, "succeeded") {
-			return fmt.Errorf("connectivity cannot be established with %s. Missing 'open' in output \n output = %s",
-				hostPort,
-				string(output))
+		log.Printf("Bolt handshake with %s succeeded. Bolt version %s", net.JoinHostPort(hostname, port), version)
+	}
+	if port := os.Getenv("PROBE_HTTP_PORT"); port != "" {
+		version, err := probe.HTTP(net.JoinHostPort(hostname, port), timeout)
+		if err != nil {
+			return err
 		}
-		log.Printf("Connectivity established with Service %s!!", hostPort)
+		log.Printf("Http discovery of %s succeeded. Neo4j version %s", net.JoinHostPort(hostname, port), version)
 	}
-
 	return nil
 }
 